@@ -1,10 +1,18 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/SobolevTim/t-graphql/internal/config"
@@ -16,15 +24,28 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// shutdownTimeout — время на завершение текущих запросов при остановке
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	// Загружаем конфигурацию
 	cfg := config.LoadConfig()
 
+	// SIGINT и SIGTERM отменяют контекст и запускают остановку сервера
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Инициализируем хранилище
-	store, err := store.NewStore(cfg)
+	storage, err := store.NewStore(cfg)
 	if err != nil {
 		log.Fatalf("Error initializing store: %v", err)
 	}
+	// Закрытие хранилища сохраняет снимок и сбрасывает журнал на диск
+	defer func() {
+		if err := closeStore(storage); err != nil {
+			log.Printf("Error closing store: %v", err)
+		}
+	}()
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
 
@@ -32,13 +53,64 @@ func main() {
 	r.SetTrustedProxies([]string{"127.0.0.1", "192.168.1.1"})
 
 	// Создаем сервисы для работы с постами и комментариями
-	postService := service.NewPostService(store)
-	commentService := service.NewCommentService(store)
-	subscriptionService := service.NewSubscriptionService(store)
+	pageSize := service.WithMaxPageSize(cfg.MaxPageSize)
+	postService := service.NewPostService(storage, pageSize, service.WithTrashRetention(cfg.TrashRetention))
+	commentService := service.NewCommentService(storage, pageSize,
+		service.WithMaxDepth(cfg.MaxCommentDepth), service.WithEditWindow(cfg.CommentEditWindow),
+		service.WithModerators(cfg.Moderators...))
+	subscriptionService := service.NewSubscriptionService(storage)
+	searchService := service.NewSearchService(storage, pageSize)
+	nodeService := service.NewNodeService(storage, pageSize)
+	persistedQueryService := service.NewPersistedQueryService(storage)
+
+	// Манифест разрешённых операций сохраняется в хранилище,
+	// поэтому список общий для всех экземпляров API
+	if cfg.PersistedQueriesManifest != "" {
+		data, err := os.ReadFile(cfg.PersistedQueriesManifest)
+		if err != nil {
+			exitWithStore(storage, "Error reading persisted queries manifest: %v", err)
+		}
+		count, err := persistedQueryService.RegisterManifest(ctx, data)
+		if err != nil {
+			exitWithStore(storage, "Error registering persisted queries manifest: %v", err)
+		}
+		log.Printf("Registered %d persisted queries", count)
+	}
+
+	// Посты, пролежавшие в корзине дольше срока хранения, удаляются окончательно
+	if cfg.TrashPurgeInterval > 0 {
+		go postService.RunPurge(ctx, cfg.TrashPurgeInterval)
+	}
 
 	// Создаём резолверы
-	resolver := resolvers.NewResolver(postService, commentService, subscriptionService)
-	srv := handler.New(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
+	resolver := resolvers.NewResolver(postService, commentService, subscriptionService, searchService, nodeService)
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  resolver,
+		Complexity: resolvers.NewComplexity(),
+	}))
+	// Коды ошибок в extensions.code, без внутренних подробностей
+	srv.SetErrorPresenter(resolvers.ErrorPresenter)
+
+	// Слишком глубокие и дорогие операции отклоняются до выполнения
+	if cfg.MaxQueryDepth > 0 {
+		srv.Use(resolvers.DepthLimit(cfg.MaxQueryDepth))
+	}
+	if cfg.MaxQueryComplexity > 0 {
+		srv.Use(extension.FixedComplexityLimit(cfg.MaxQueryComplexity))
+	}
+
+	// Операции по хешу: в строгом режиме только из списка разрешённых,
+	// иначе по протоколу APQ с регистрацией новых операций в кэше
+	switch {
+	case cfg.OperationAllowlist:
+		allowlist := resolvers.OperationAllowlist{Queries: persistedQueryService}
+		if cfg.APQCacheSize > 0 {
+			allowlist.Cache = lru.New[string](cfg.APQCacheSize)
+		}
+		srv.Use(allowlist)
+	case cfg.APQCacheSize > 0:
+		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](cfg.APQCacheSize)})
+	}
 
 	// Добавляем транспорты для обработки GraphQL запросов
 	srv.AddTransport(transport.POST{})
@@ -51,14 +123,67 @@ func main() {
 	})
 
 	// Регистрируем эндпоинт для GraphQL (POST и WebSocket запросы)
-	r.Any("/graphql", gin.WrapH(srv))
+	// Загрузчики объединяют чтение комментариев в пределах одного запроса,
+	// пользователь запроса берётся из заголовка, выставленного прокси
+	r.Any("/graphql", gin.WrapH(resolvers.UserMiddleware(resolvers.LoadersMiddleware(commentService)(srv))))
+
+	// Состояние общего соединения для подписок PostgreSQL
+	if pg, ok := storage.(*store.Service); ok {
+		r.GET("/health/listener", func(c *gin.Context) {
+			stats := pg.ListenerStats()
+			status := http.StatusOK
+			if !stats.Connected {
+				status = http.StatusServiceUnavailable
+			}
+			c.JSON(status, stats)
+		})
+	}
 
 	// Добавляем Playground для тестирования запросов
 	r.GET("/", gin.WrapH(playground.Handler("GraphQL playground", "/graphql")))
 
 	// Запуск API-сервера
-	log.Println("GraphQL API running at http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{Addr: ":8080", Handler: r}
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Println("GraphQL API running at http://localhost:8080")
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Failed to start server: %v", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+}
+
+// closeStore закрывает хранилище, если у реализации есть Close
+func closeStore(storage store.Store) error {
+	switch s := storage.(type) {
+	case interface{ Close() error }:
+		return s.Close()
+	case interface{ Close() }:
+		s.Close()
+	}
+	return nil
+}
+
+// exitWithStore завершает процесс с ошибкой, предварительно закрыв хранилище:
+// os.Exit не выполняет отложенные вызовы
+func exitWithStore(storage store.Store, format string, args ...any) {
+	log.Printf(format, args...)
+	if err := closeStore(storage); err != nil {
+		log.Printf("Error closing store: %v", err)
 	}
+	os.Exit(1)
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/config"
+	"github.com/SobolevTim/t-graphql/internal/migrate"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `Usage: migrate [-database URL] <command>
+
+Commands:
+  up              применить все новые миграции
+  down [-steps N] откатить N последних миграций (по умолчанию одну, 0 — все)
+  status          показать состояние миграций
+`
+
+func main() {
+	// Загружаем конфигурацию
+	cfg := config.LoadConfig()
+
+	databaseURL := flag.String("database", cfg.DatabaseURL, "URL базы данных PostgreSQL")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	// Подключаемся к базе данных
+	pool, err := pgxpool.New(ctx, *databaseURL)
+	if err != nil {
+		log.Fatalf("could not connect to db: %v", err)
+	}
+	defer pool.Close()
+
+	migrator, err := migrate.NewPostgres(pool)
+	if err != nil {
+		log.Fatalf("could not load migrations: %v", err)
+	}
+
+	switch command := flag.Arg(0); command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatalf("migration failed: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", applied)
+	case "down":
+		downFlags := flag.NewFlagSet("down", flag.ExitOnError)
+		steps := downFlags.Int("steps", 1, "количество откатываемых миграций, 0 — все")
+		downFlags.Parse(flag.Args()[1:])
+
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			log.Fatalf("rollback failed: %v", err)
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatalf("could not get status: %v", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,144 @@
+// Package dataloader объединяет загрузку записей по ключам, запрошенных
+// резолверами почти одновременно, в один пакетный вызов хранилища
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWait     = 2 * time.Millisecond
+	defaultMaxBatch = 100
+)
+
+// FetchFunc загружает значения для ключей keys одним вызовом
+// Для ключа, которого нет в результате, Load возвращает нулевое значение
+type FetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader копит ключи, запрошенные через Load, и загружает их пакетом,
+// когда с первого запроса пакета прошло время wait или набралось maxBatch ключей.
+// Результаты кэшируются на время жизни загрузчика, поэтому загрузчик
+// создаётся на один запрос GraphQL
+type Loader[K comparable, V any] struct {
+	fetch    FetchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V] // Загруженные и загружаемые ключи
+	batch *batch[K, V]     // Пакет, который ещё копит ключи
+}
+
+// result — значение ключа; done закрывается, когда оно загружено
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// batch — ключи одной пакетной загрузки
+type batch[K comparable, V any] struct {
+	ctx     context.Context // Контекст первого Load пакета
+	keys    []K
+	results []*result[V]
+}
+
+// Option настраивает загрузчик
+type Option func(*options)
+
+type options struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+// WithWait задаёт, сколько пакет копит ключи
+func WithWait(wait time.Duration) Option {
+	return func(o *options) {
+		o.wait = wait
+	}
+}
+
+// WithMaxBatch ограничивает число ключей в пакете
+func WithMaxBatch(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxBatch = size
+		}
+	}
+}
+
+// New создаёт загрузчик, который загружает ключи функцией fetch
+func New[K comparable, V any](fetch FetchFunc[K, V], opts ...Option) *Loader[K, V] {
+	o := options{wait: defaultWait, maxBatch: defaultMaxBatch}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     o.wait,
+		maxBatch: o.maxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load возвращает значение ключа key, загружая его вместе с другими
+// ключами пакета. Повторный Load того же ключа берёт значение из кэша
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(ctx, key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// enqueue добавляет ключ в текущий пакет. Вызывается под блокировкой
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K, r *result[V]) {
+	if l.batch == nil {
+		b := &batch[K, V]{ctx: ctx}
+		l.batch = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+	b := l.batch
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if len(b.keys) >= l.maxBatch {
+		// Полный пакет загружается сразу, таймер его уже не найдёт
+		l.batch = nil
+		go l.load(b)
+	}
+}
+
+// dispatch загружает пакет b по таймеру, если он ещё не загружен
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.batch != b {
+		l.mu.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mu.Unlock()
+	l.load(b)
+}
+
+// load загружает ключи пакета и раздаёт результаты
+func (l *Loader[K, V]) load(b *batch[K, V]) {
+	values, err := l.fetch(b.ctx, b.keys)
+	for i, key := range b.keys {
+		r := b.results[i]
+		r.value, r.err = values[key], err
+		close(r.done)
+	}
+}
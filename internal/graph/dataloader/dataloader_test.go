@@ -0,0 +1,100 @@
+package dataloader_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/dataloader"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder запоминает пакеты, с которыми вызывалась загрузка
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+}
+
+func (r *recorder) fetch(ctx context.Context, keys []int) (map[int]string, error) {
+	r.mu.Lock()
+	r.batches = append(r.batches, append([]int(nil), keys...))
+	r.mu.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	values := make(map[int]string, len(keys))
+	for _, key := range keys {
+		if key >= 0 {
+			values[key] = string(rune('a' + key))
+		}
+	}
+	return values, nil
+}
+
+// loadAll загружает ключи параллельно, как это делают резолверы элементов списка
+func loadAll(t *testing.T, loader *dataloader.Loader[int, string], keys ...int) []string {
+	t.Helper()
+	values := make([]string, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := loader.Load(context.Background(), key)
+			assert.NoError(t, err)
+			values[i] = value
+		}()
+	}
+	wg.Wait()
+	return values
+}
+
+func TestLoader_Batches(t *testing.T) {
+	r := &recorder{}
+	loader := dataloader.New(r.fetch, dataloader.WithWait(10*time.Millisecond))
+
+	values := loadAll(t, loader, 0, 1, 2, 1, -1)
+	assert.Equal(t, []string{"a", "b", "c", "b", ""}, values)
+	require.Len(t, r.batches, 1)
+	assert.ElementsMatch(t, []int{0, 1, 2, -1}, r.batches[0])
+
+	// Загруженные ключи берутся из кэша
+	values = loadAll(t, loader, 2, 3)
+	assert.Equal(t, []string{"c", "d"}, values)
+	require.Len(t, r.batches, 2)
+	assert.Equal(t, []int{3}, r.batches[1])
+}
+
+func TestLoader_MaxBatch(t *testing.T) {
+	r := &recorder{}
+	loader := dataloader.New(r.fetch, dataloader.WithWait(time.Hour), dataloader.WithMaxBatch(2))
+
+	values := loadAll(t, loader, 0, 1, 2, 3)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, values)
+	require.Len(t, r.batches, 2)
+	for _, batch := range r.batches {
+		assert.Len(t, batch, 2)
+	}
+}
+
+func TestLoader_Error(t *testing.T) {
+	r := &recorder{err: errors.New("store is down")}
+	loader := dataloader.New(r.fetch)
+
+	_, err := loader.Load(context.Background(), 1)
+	assert.EqualError(t, err, "store is down")
+}
+
+func TestLoader_ContextCanceled(t *testing.T) {
+	r := &recorder{}
+	loader := dataloader.New(r.fetch, dataloader.WithWait(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := loader.Load(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,22 @@
+package model
+
+// PostConnection — страница постов
+// Общее число постов считается отдельным резолвером, только если его запросили
+type PostConnection struct {
+	Edges    []*PostEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+
+	// Фильтр, по которому считается totalCount
+	Filter  *PostFilter `json:"-"`
+	Deleted bool        `json:"-"` // Страница постов из корзины
+}
+
+// CommentConnection — страница комментариев к посту или ответов на комментарий
+type CommentConnection struct {
+	Edges    []*CommentEdge `json:"edges"`
+	PageInfo *PageInfo      `json:"pageInfo"`
+
+	// Выборка, по которой считается totalCount
+	PostID   string  `json:"-"`
+	ParentID *string `json:"-"`
+}
@@ -2,6 +2,21 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type Node interface {
+	IsNode()
+	GetID() string
+}
+
+type SearchResult interface {
+	IsSearchResult()
+}
+
 type AddCommentInput struct {
 	PostID   string  `json:"postID"`
 	ParentID *string `json:"parentID,omitempty"`
@@ -10,13 +25,38 @@ type AddCommentInput struct {
 }
 
 type Comment struct {
-	ID        string     `json:"id"`
-	PostID    string     `json:"postID"`
-	ParentID  *string    `json:"parentID,omitempty"`
-	Content   string     `json:"content"`
-	Author    string     `json:"author"`
-	CreatedAt string     `json:"createdAt"`
-	Replies   []*Comment `json:"replies"`
+	ID              string               `json:"id"`
+	PostID          string               `json:"postID"`
+	ParentID        *string              `json:"parentID,omitempty"`
+	Content         string               `json:"content"`
+	Author          string               `json:"author"`
+	CreatedAt       string               `json:"createdAt"`
+	Depth           int                  `json:"depth"`
+	RootID          string               `json:"rootID"`
+	ReplyCount      int                  `json:"replyCount"`
+	DescendantCount int                  `json:"descendantCount"`
+	EditedAt        *string              `json:"editedAt,omitempty"`
+	IsDeleted       bool                 `json:"isDeleted"`
+	DeletedAt       *string              `json:"deletedAt,omitempty"`
+	DeleteReason    *CommentDeleteReason `json:"deleteReason,omitempty"`
+	Edits           []*CommentEdit       `json:"edits"`
+	Replies         *CommentConnection   `json:"replies"`
+}
+
+func (Comment) IsNode()            {}
+func (this Comment) GetID() string { return this.ID }
+
+func (Comment) IsSearchResult() {}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type CommentEdit struct {
+	Number   int    `json:"number"`
+	Content  string `json:"content"`
+	EditedAt string `json:"editedAt"`
 }
 
 type CreatePostInput struct {
@@ -26,21 +66,269 @@ type CreatePostInput struct {
 	AllowComments *bool  `json:"allowComments,omitempty"`
 }
 
+type DeleteCommentPayload struct {
+	ID      string   `json:"id"`
+	Comment *Comment `json:"comment,omitempty"`
+}
+
+type DiffLine struct {
+	Kind DiffKind `json:"kind"`
+	Text string   `json:"text"`
+}
+
 type Mutation struct {
 }
 
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
+}
+
 type Post struct {
-	ID            string     `json:"id"`
-	Title         string     `json:"title"`
-	Content       string     `json:"content"`
-	Author        string     `json:"author"`
-	CreatedAt     string     `json:"createdAt"`
-	AllowComments bool       `json:"allowComments"`
-	Comments      []*Comment `json:"comments"`
+	ID                   string             `json:"id"`
+	Title                string             `json:"title"`
+	Content              string             `json:"content"`
+	Author               string             `json:"author"`
+	CreatedAt            string             `json:"createdAt"`
+	UpdatedAt            *string            `json:"updatedAt,omitempty"`
+	DeletedAt            *string            `json:"deletedAt,omitempty"`
+	AllowComments        bool               `json:"allowComments"`
+	CommentCount         int                `json:"commentCount"`
+	TopLevelCommentCount int                `json:"topLevelCommentCount"`
+	Comments             *CommentConnection `json:"comments"`
+	Related              []*Post            `json:"related"`
+	CommentTree          []*Comment         `json:"commentTree"`
+	Revisions            []*PostRevision    `json:"revisions"`
+	RevisionDiff         *RevisionDiff      `json:"revisionDiff"`
+}
+
+func (Post) IsNode()            {}
+func (this Post) GetID() string { return this.ID }
+
+func (Post) IsSearchResult() {}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+type PostFilter struct {
+	Author        *string `json:"author,omitempty"`
+	CreatedAfter  *string `json:"createdAfter,omitempty"`
+	CreatedBefore *string `json:"createdBefore,omitempty"`
+	AllowComments *bool   `json:"allowComments,omitempty"`
+}
+
+type PostRevision struct {
+	Number   int    `json:"number"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Editor   string `json:"editor"`
+	EditedAt string `json:"editedAt"`
 }
 
 type Query struct {
 }
 
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []*DiffLine `json:"title"`
+	Content []*DiffLine `json:"content"`
+}
+
+type SearchConnection struct {
+	Edges    []*SearchEdge `json:"edges"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+}
+
+type SearchEdge struct {
+	Cursor  string       `json:"cursor"`
+	Node    SearchResult `json:"node"`
+	Score   float64      `json:"score"`
+	Snippet string       `json:"snippet"`
+}
+
 type Subscription struct {
 }
+
+type UpdatePostInput struct {
+	ID      string  `json:"id"`
+	Title   *string `json:"title,omitempty"`
+	Content *string `json:"content,omitempty"`
+	Editor  string  `json:"editor"`
+}
+
+type CommentDeleteReason string
+
+const (
+	CommentDeleteReasonAuthor   CommentDeleteReason = "AUTHOR"
+	CommentDeleteReasonSpam     CommentDeleteReason = "SPAM"
+	CommentDeleteReasonAbuse    CommentDeleteReason = "ABUSE"
+	CommentDeleteReasonOffTopic CommentDeleteReason = "OFF_TOPIC"
+)
+
+var AllCommentDeleteReason = []CommentDeleteReason{
+	CommentDeleteReasonAuthor,
+	CommentDeleteReasonSpam,
+	CommentDeleteReasonAbuse,
+	CommentDeleteReasonOffTopic,
+}
+
+func (e CommentDeleteReason) IsValid() bool {
+	switch e {
+	case CommentDeleteReasonAuthor, CommentDeleteReasonSpam, CommentDeleteReasonAbuse, CommentDeleteReasonOffTopic:
+		return true
+	}
+	return false
+}
+
+func (e CommentDeleteReason) String() string {
+	return string(e)
+}
+
+func (e *CommentDeleteReason) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = CommentDeleteReason(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid CommentDeleteReason", str)
+	}
+	return nil
+}
+
+func (e CommentDeleteReason) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type DiffKind string
+
+const (
+	DiffKindEqual  DiffKind = "EQUAL"
+	DiffKindInsert DiffKind = "INSERT"
+	DiffKindDelete DiffKind = "DELETE"
+)
+
+var AllDiffKind = []DiffKind{
+	DiffKindEqual,
+	DiffKindInsert,
+	DiffKindDelete,
+}
+
+func (e DiffKind) IsValid() bool {
+	switch e {
+	case DiffKindEqual, DiffKindInsert, DiffKindDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffKind) String() string {
+	return string(e)
+}
+
+func (e *DiffKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffKind", str)
+	}
+	return nil
+}
+
+func (e DiffKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostOrder string
+
+const (
+	PostOrderNewest        PostOrder = "NEWEST"
+	PostOrderOldest        PostOrder = "OLDEST"
+	PostOrderMostCommented PostOrder = "MOST_COMMENTED"
+)
+
+var AllPostOrder = []PostOrder{
+	PostOrderNewest,
+	PostOrderOldest,
+	PostOrderMostCommented,
+}
+
+func (e PostOrder) IsValid() bool {
+	switch e {
+	case PostOrderNewest, PostOrderOldest, PostOrderMostCommented:
+		return true
+	}
+	return false
+}
+
+func (e PostOrder) String() string {
+	return string(e)
+}
+
+func (e *PostOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrder", str)
+	}
+	return nil
+}
+
+func (e PostOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type SearchKind string
+
+const (
+	SearchKindPost    SearchKind = "POST"
+	SearchKindComment SearchKind = "COMMENT"
+)
+
+var AllSearchKind = []SearchKind{
+	SearchKindPost,
+	SearchKindComment,
+}
+
+func (e SearchKind) IsValid() bool {
+	switch e {
+	case SearchKindPost, SearchKindComment:
+		return true
+	}
+	return false
+}
+
+func (e SearchKind) String() string {
+	return string(e)
+}
+
+func (e *SearchKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SearchKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SearchKind", str)
+	}
+	return nil
+}
+
+func (e SearchKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,62 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// DeleteComment удаляет комментарий.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string, reason model.CommentDeleteReason) (*model.DeleteCommentPayload, error) {
+	localID, err := service.DecodeID(service.NodeComment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	kept, err := r.CommentService.DeleteComment(ctx, localID, deleteReason(reason))
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &model.DeleteCommentPayload{ID: service.EncodeGlobalID(service.NodeComment, localID)}
+	if kept != nil {
+		payload.Comment = newComment(kept)
+	}
+	return payload, nil
+}
+
+// deleteReason переводит причину удаления из GraphQL в причину хранилища
+func deleteReason(reason model.CommentDeleteReason) string {
+	switch reason {
+	case model.CommentDeleteReasonAuthor:
+		return store.DeletedByAuthor
+	case model.CommentDeleteReasonSpam:
+		return store.DeletedAsSpam
+	case model.CommentDeleteReasonAbuse:
+		return store.DeletedAsAbuse
+	case model.CommentDeleteReasonOffTopic:
+		return store.DeletedAsOffTopic
+	default:
+		return string(reason)
+	}
+}
+
+// newDeleteReason переводит причину удаления хранилища в ответ GraphQL
+func newDeleteReason(reason string) *model.CommentDeleteReason {
+	var r model.CommentDeleteReason
+	switch reason {
+	case store.DeletedByAuthor:
+		r = model.CommentDeleteReasonAuthor
+	case store.DeletedAsSpam:
+		r = model.CommentDeleteReasonSpam
+	case store.DeletedAsAbuse:
+		r = model.CommentDeleteReasonAbuse
+	case store.DeletedAsOffTopic:
+		r = model.CommentDeleteReasonOffTopic
+	default:
+		return nil
+	}
+	return &r
+}
@@ -0,0 +1,101 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteComment_Tombstone(t *testing.T) {
+	c := newClient()
+	postID := createPost(t, c)
+	parentID := addComment(t, c, postID, nil)
+	replyID := addComment(t, c, postID, &parentID)
+
+	type payload struct {
+		ID      string
+		Comment *struct {
+			Content      string
+			Author       string
+			IsDeleted    bool
+			DeletedAt    *string
+			DeleteReason *string
+			ReplyCount   int
+		}
+	}
+	const mutation = `mutation($id: ID!, $reason: CommentDeleteReason!) {
+		deleteComment(id: $id, reason: $reason) {
+			id comment { content author isDeleted deletedAt deleteReason replyCount }
+		}
+	}`
+
+	// У комментария есть ответ: он остаётся в ветке без текста и автора
+	var deleted struct{ DeleteComment payload }
+	c.MustPost(mutation, &deleted, client.Var("id", parentID),
+		client.Var("reason", "AUTHOR"), asUser("a"))
+	assert.Equal(t, parentID, deleted.DeleteComment.ID)
+	require.NotNil(t, deleted.DeleteComment.Comment)
+	tombstone := deleted.DeleteComment.Comment
+	assert.Equal(t, "[deleted]", tombstone.Content)
+	assert.Equal(t, "[deleted]", tombstone.Author)
+	assert.True(t, tombstone.IsDeleted)
+	assert.NotNil(t, tombstone.DeletedAt)
+	require.NotNil(t, tombstone.DeleteReason)
+	assert.Equal(t, "AUTHOR", *tombstone.DeleteReason)
+	assert.Equal(t, 1, tombstone.ReplyCount)
+
+	// Ответ без своих ответов удаляется целиком, а с ним и пустая ветка
+	deleted = struct{ DeleteComment payload }{}
+	c.MustPost(mutation, &deleted, client.Var("id", replyID),
+		client.Var("reason", "AUTHOR"), asUser("a"))
+	assert.Equal(t, replyID, deleted.DeleteComment.ID)
+	assert.Nil(t, deleted.DeleteComment.Comment)
+
+	var resp struct {
+		Post struct {
+			CommentCount int
+			Comments     struct {
+				Edges []struct{ Node struct{ ID string } }
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { commentCount comments { edges { node { id } } } } }`,
+		&resp, client.Var("id", postID))
+	assert.Zero(t, resp.Post.CommentCount)
+	assert.Empty(t, resp.Post.Comments.Edges)
+}
+
+func TestDeleteComment_NotAuthor(t *testing.T) {
+	c := newClient()
+	id := addComment(t, c, createPost(t, c), nil)
+
+	resp, err := c.RawPost(`mutation($id: ID!) { deleteComment(id: $id, reason: AUTHOR) { id } }`,
+		client.Var("id", id), asUser("b"))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "only the author can delete the comment", errs[0].Message)
+	assert.Equal(t, "FORBIDDEN", errs[0].Extensions["code"])
+
+	// Причина модератора не позволяет удалить чужой комментарий
+	resp, err = c.RawPost(`mutation($id: ID!) { deleteComment(id: $id, reason: SPAM) { id } }`,
+		client.Var("id", id), asUser("b"))
+	require.NoError(t, err)
+
+	errs = responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "only moderators can delete the comment for this reason", errs[0].Message)
+	assert.Equal(t, "FORBIDDEN", errs[0].Extensions["code"])
+
+	// Без заголовка пользователя удалить комментарий нельзя
+	resp, err = c.RawPost(`mutation($id: ID!) { deleteComment(id: $id, reason: AUTHOR) { id } }`,
+		client.Var("id", id))
+	require.NoError(t, err)
+
+	errs = responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "UNAUTHENTICATED", errs[0].Extensions["code"])
+}
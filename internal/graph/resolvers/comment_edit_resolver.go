@@ -0,0 +1,45 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+)
+
+// EditComment изменяет текст комментария.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, content string) (*model.Comment, error) {
+	id, err := service.DecodeID(service.NodeComment, id)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := r.CommentService.EditComment(ctx, id, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Публикуем изменённый комментарий для подписчиков
+	r.SubscriptionService.PublishEdited(ctx, comment)
+
+	return newComment(comment), nil
+}
+
+// Edits возвращает прежние версии текста комментария.
+func (r *commentResolver) Edits(ctx context.Context, obj *model.Comment) ([]*model.CommentEdit, error) {
+	edits, err := r.loaders(ctx).edits.Load(ctx, localID(service.NodeComment, obj.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.CommentEdit, 0, len(edits))
+	for _, edit := range edits {
+		result = append(result, &model.CommentEdit{
+			Number:   edit.Number,
+			Content:  edit.Content,
+			EditedAt: edit.EditedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
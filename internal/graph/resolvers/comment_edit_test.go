@@ -0,0 +1,94 @@
+package resolvers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditComment_Edits(t *testing.T) {
+	storage := store.NewMemoryStore()
+	c := newStoreClient(storage)
+
+	postID := createPost(t, c)
+	id := addComment(t, c, postID, nil)
+
+	localPostID, err := service.DecodeID(service.NodePost, postID)
+	require.NoError(t, err)
+	events, unsubscribe := storage.Subscribe(context.Background(), localPostID)
+	defer unsubscribe()
+
+	var edited struct {
+		EditComment struct {
+			Content  string
+			EditedAt *string
+		}
+	}
+	c.MustPost(`mutation($id: ID!) { editComment(id: $id, content: "second") { content editedAt } }`,
+		&edited, client.Var("id", id), asUser("a"))
+	assert.Equal(t, "second", edited.EditComment.Content)
+	assert.NotNil(t, edited.EditComment.EditedAt)
+
+	// Подписчики поста получают событие об изменении
+	select {
+	case event := <-events:
+		assert.Equal(t, store.EventCommentEdited, event.Type)
+		require.NotNil(t, event.Comment)
+		assert.Equal(t, "second", event.Comment.Content)
+	case <-time.After(time.Second):
+		t.Fatal("comment edited event was not published")
+	}
+
+	var resp struct {
+		Post struct {
+			Comments struct {
+				Edges []struct {
+					Node struct {
+						Content string
+						Edits   []struct {
+							Number  int
+							Content string
+						}
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { comments { edges { node { content edits { number content } } } } } }`,
+		&resp, client.Var("id", postID))
+	require.Len(t, resp.Post.Comments.Edges, 1)
+	node := resp.Post.Comments.Edges[0].Node
+	assert.Equal(t, "second", node.Content)
+	require.Len(t, node.Edits, 1)
+	assert.Equal(t, 1, node.Edits[0].Number)
+	assert.Equal(t, "comment", node.Edits[0].Content)
+}
+
+func TestEditComment_NotAuthor(t *testing.T) {
+	c := newClient()
+	id := addComment(t, c, createPost(t, c), nil)
+
+	const mutation = `mutation($id: ID!) { editComment(id: $id, content: "second") { id } }`
+	resp, err := c.RawPost(mutation, client.Var("id", id), asUser("b"))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "only the author can edit the comment", errs[0].Message)
+	assert.Equal(t, "FORBIDDEN", errs[0].Extensions["code"])
+
+	// Без заголовка пользователя изменить комментарий нельзя
+	resp, err = c.RawPost(mutation, client.Var("id", id))
+	require.NoError(t, err)
+
+	errs = responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "user is not authenticated", errs[0].Message)
+	assert.Equal(t, "UNAUTHENTICATED", errs[0].Extensions["code"])
+}
@@ -2,80 +2,99 @@ package resolvers
 
 import (
 	"context"
-	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
 	"github.com/SobolevTim/t-graphql/internal/store"
 )
 
-// Comments возвращает комментарии к посту.
-func (r *postResolver) Comments(ctx context.Context, obj *model.Post, page, pageSize *int) ([]*model.Comment, error) {
-	comments, err := r.CommentService.GetCommentsByPostID(obj.ID, *page, *pageSize)
+// Comments возвращает страницу комментариев верхнего уровня к посту.
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
+	postID := localID(service.NodePost, obj.ID)
+	page, err := r.loaders(ctx).comments.load(ctx, postID, pageArgs(first, after, last, before))
 	if err != nil {
 		return nil, err
 	}
+	return newCommentConnection(page, postID, nil), nil
+}
 
-	var gqlComments []*model.Comment
-	for _, comment := range comments {
-		gqlComments = append(gqlComments, &model.Comment{
-			ID:        comment.ID,
-			PostID:    comment.PostID,
-			ParentID:  comment.ParentID,
-			Content:   comment.Content,
-			Author:    comment.Author,
-			CreatedAt: comment.CreatedAt.Format(time.RFC3339),
-		})
+// Replies возвращает страницу ответов (вложенных комментариев) на комментарий.
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
+	commentID := localID(service.NodeComment, obj.ID)
+	page, err := r.loaders(ctx).replies.load(ctx, commentID, pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
 	}
+	return newCommentConnection(page, localID(service.NodePost, obj.PostID), &commentID), nil
+}
 
-	return gqlComments, nil
+// CommentTree возвращает комментарии к посту вместе с ответами
+// в порядке обхода в глубину, дерево собирается клиентом по parentID.
+func (r *postResolver) CommentTree(ctx context.Context, obj *model.Post, maxDepth *int, limitPerLevel *int) ([]*model.Comment, error) {
+	tree, err := r.CommentService.CommentTree(ctx, localID(service.NodePost, obj.ID), nil, maxDepth, limitPerLevel)
+	if err != nil {
+		return nil, err
+	}
+	return newCommentList(tree), nil
 }
 
-// Replies возвращает ответы (вложенные комментарии) для конкретного комментария.
-func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, page, pageSize *int) ([]*model.Comment, error) {
-	// Получаем ответы для комментария с родительским идентификатором obj.ID.
-	// Если метод GetCommentsByPostIDAndParentID ожидает указатели на int, передаём их.
-	replies, err := r.CommentService.GetCommentsByPostIDAndParentID(obj.PostID, &obj.ID, *page, *pageSize)
+// CommentThread возвращает ветку ответов на комментарий rootID вместе с ним.
+func (r *queryResolver) CommentThread(ctx context.Context, postID string, rootID string, maxDepth *int, limitPerLevel *int) ([]*model.Comment, error) {
+	postID, err := service.DecodeID(service.NodePost, postID)
+	if err != nil {
+		return nil, err
+	}
+	rootID, err = service.DecodeID(service.NodeComment, rootID)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := r.CommentService.CommentTree(ctx, postID, &rootID, maxDepth, limitPerLevel)
 	if err != nil {
 		return nil, err
 	}
+	return newCommentList(tree), nil
+}
 
-	var gqlReplies []*model.Comment
-	for _, reply := range replies {
-		gqlReplies = append(gqlReplies, &model.Comment{
-			ID:        reply.ID,
-			PostID:    reply.PostID,
-			ParentID:  reply.ParentID,
-			Content:   reply.Content,
-			Author:    reply.Author,
-			CreatedAt: reply.CreatedAt.Format(time.RFC3339),
-		})
+// newCommentList переводит комментарии хранилища в ответ GraphQL
+func newCommentList(comments []*store.Comment) []*model.Comment {
+	list := make([]*model.Comment, 0, len(comments))
+	for _, comment := range comments {
+		list = append(list, newComment(comment))
 	}
-	return gqlReplies, nil
+	return list
 }
 
 // AddComment создаёт комментарий.
 func (r *mutationResolver) AddComment(ctx context.Context, input model.AddCommentInput) (*model.Comment, error) {
-	comment, err := r.CommentService.AddComment(input.PostID, input.Content, input.Author, input.ParentID)
+	postID, err := service.DecodeID(service.NodePost, input.PostID)
+	if err != nil {
+		return nil, err
+	}
+	var parentID *string
+	if input.ParentID != nil {
+		id, err := service.DecodeID(service.NodeComment, *input.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		parentID = &id
+	}
+
+	comment, err := r.CommentService.AddComment(ctx, postID, input.Content, input.Author, parentID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Публикуем новый комментарий для подписчиков
-	r.SubscriptionService.Publish(&store.Comment{
+	r.SubscriptionService.Publish(ctx, &store.Comment{
 		ID:        comment.ID,
 		PostID:    comment.PostID,
 		ParentID:  comment.ParentID,
 		Content:   comment.Content,
 		Author:    comment.Author,
 		CreatedAt: comment.CreatedAt,
+		Depth:     comment.Depth,
+		RootID:    comment.RootID,
 	})
 
-	return &model.Comment{
-		ID:        comment.ID,
-		PostID:    comment.PostID,
-		ParentID:  comment.ParentID,
-		Content:   comment.Content,
-		Author:    comment.Author,
-		CreatedAt: comment.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return newComment(comment), nil
 }
@@ -0,0 +1,56 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type treeComment struct {
+	ID       string
+	ParentID *string
+	Depth    int
+	Content  string
+}
+
+func TestCommentTree(t *testing.T) {
+	c := newClient()
+	postID := createPost(t, c)
+	root := addComment(t, c, postID, nil)
+	reply := addComment(t, c, postID, &root)
+	nested := addComment(t, c, postID, &reply)
+
+	var resp struct {
+		Post struct{ CommentTree []treeComment }
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { commentTree { id parentID depth content } } }`, &resp, client.Var("id", postID))
+	require.Len(t, resp.Post.CommentTree, 3)
+	ids := make([]string, 0, 3)
+	for _, comment := range resp.Post.CommentTree {
+		ids = append(ids, comment.ID)
+		assert.Equal(t, "comment", comment.Content)
+	}
+	assert.Equal(t, []string{root, reply, nested}, ids)
+	assert.Equal(t, reply, *resp.Post.CommentTree[2].ParentID)
+
+	var thread struct{ CommentThread []treeComment }
+	c.MustPost(`query($postID: ID!, $rootID: ID!) { commentThread(postID: $postID, rootID: $rootID, maxDepth: 0) { id depth } }`,
+		&thread, client.Var("postID", postID), client.Var("rootID", reply))
+	require.Len(t, thread.CommentThread, 1)
+	assert.Equal(t, reply, thread.CommentThread[0].ID)
+	assert.Equal(t, 1, thread.CommentThread[0].Depth)
+}
+
+func TestCommentThread_NotFound(t *testing.T) {
+	c := newClient()
+	resp, err := c.RawPost(`query($postID: ID!) { commentThread(postID: $postID, rootID: "missing") { id } }`,
+		client.Var("postID", createPost(t, c)))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "failed to get comment tree: comment not found", errs[0].Message)
+	assert.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+}
@@ -0,0 +1,103 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// pageArgs собирает аргументы пагинации поля
+func pageArgs(first *int, after *string, last *int, before *string) service.PageArgs {
+	return service.PageArgs{First: first, After: after, Last: last, Before: before}
+}
+
+// postFilter переводит фильтр постов из запроса в фильтр сервиса
+func postFilter(filter *model.PostFilter) service.PostFilter {
+	if filter == nil {
+		return service.PostFilter{}
+	}
+	return service.PostFilter{
+		Author:        filter.Author,
+		CreatedAfter:  filter.CreatedAfter,
+		CreatedBefore: filter.CreatedBefore,
+		AllowComments: filter.AllowComments,
+	}
+}
+
+// postOrder переводит порядок постов из запроса в порядок хранилища
+func postOrder(order *model.PostOrder) store.PostOrder {
+	if order == nil {
+		return store.PostsNewest
+	}
+	switch *order {
+	case model.PostOrderOldest:
+		return store.PostsOldest
+	case model.PostOrderMostCommented:
+		return store.PostsMostCommented
+	default:
+		return store.PostsNewest
+	}
+}
+
+// newPageInfo описывает страницу с курсорами первой и последней записи
+func newPageInfo[T interface{ Cursor() store.Cursor }](page *store.Page[T]) *model.PageInfo {
+	info := &model.PageInfo{
+		HasNextPage:     page.HasNextPage,
+		HasPreviousPage: page.HasPreviousPage,
+	}
+	if n := len(page.Items); n > 0 {
+		start := service.EncodeCursor(page.Items[0].Cursor())
+		end := service.EncodeCursor(page.Items[n-1].Cursor())
+		info.StartCursor = &start
+		info.EndCursor = &end
+	}
+	return info
+}
+
+// newPostConnection переводит страницу постов в ответ GraphQL
+// filter запоминается для подсчёта totalCount
+func newPostConnection(page *store.Page[*store.Post], filter *model.PostFilter) *model.PostConnection {
+	edges := make([]*model.PostEdge, 0, len(page.Items))
+	for _, post := range page.Items {
+		edges = append(edges, &model.PostEdge{
+			Cursor: service.EncodeCursor(post.Cursor()),
+			Node:   newPost(post),
+		})
+	}
+	return &model.PostConnection{Edges: edges, PageInfo: newPageInfo(page), Filter: filter}
+}
+
+// newCommentConnection переводит страницу комментариев в ответ GraphQL
+// postID и parentID запоминаются для подсчёта totalCount
+func newCommentConnection(page *store.Page[*store.Comment], postID string, parentID *string) *model.CommentConnection {
+	edges := make([]*model.CommentEdge, 0, len(page.Items))
+	for _, comment := range page.Items {
+		edges = append(edges, &model.CommentEdge{
+			Cursor: service.EncodeCursor(comment.Cursor()),
+			Node:   newComment(comment),
+		})
+	}
+	return &model.CommentConnection{
+		Edges:    edges,
+		PageInfo: newPageInfo(page),
+		PostID:   postID,
+		ParentID: parentID,
+	}
+}
+
+// TotalCount возвращает число постов, подходящих под фильтр.
+func (r *postConnectionResolver) TotalCount(ctx context.Context, obj *model.PostConnection) (int, error) {
+	f := postFilter(obj.Filter)
+	f.Deleted = obj.Deleted
+	return r.PostService.CountPosts(ctx, f)
+}
+
+// TotalCount возвращает общее число комментариев в выборке.
+func (r *commentConnectionResolver) TotalCount(ctx context.Context, obj *model.CommentConnection) (int, error) {
+	if obj.ParentID == nil {
+		return r.loaders(ctx).commentCount.Load(ctx, obj.PostID)
+	}
+	return r.loaders(ctx).replyCount.Load(ctx, *obj.ParentID)
+}
@@ -0,0 +1,174 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type postsResponse struct {
+	Posts struct {
+		Edges []struct {
+			Cursor string
+			Node   struct{ Title string }
+		}
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   *string
+		}
+		TotalCount int
+	}
+}
+
+func TestPosts_Connection(t *testing.T) {
+	c := newClient()
+	for _, title := range []string{"first", "second", "third"} {
+		var created struct{ CreatePost struct{ ID string } }
+		c.MustPost(`mutation($title: String!) { createPost(input: {title: $title, content: "c", author: "a"}) { id } }`,
+			&created, client.Var("title", title))
+	}
+
+	const query = `query($first: Int, $after: String) {
+		posts(first: $first, after: $after) {
+			edges { cursor node { title } }
+			pageInfo { hasNextPage endCursor }
+			totalCount
+		}
+	}`
+
+	var resp postsResponse
+	c.MustPost(query, &resp, client.Var("first", 2))
+	require.Len(t, resp.Posts.Edges, 2)
+	assert.Equal(t, "third", resp.Posts.Edges[0].Node.Title)
+	assert.Equal(t, "second", resp.Posts.Edges[1].Node.Title)
+	assert.True(t, resp.Posts.PageInfo.HasNextPage)
+	assert.Equal(t, 3, resp.Posts.TotalCount)
+	require.NotNil(t, resp.Posts.PageInfo.EndCursor)
+	assert.Equal(t, resp.Posts.Edges[1].Cursor, *resp.Posts.PageInfo.EndCursor)
+
+	var next postsResponse
+	c.MustPost(query, &next, client.Var("first", 2), client.Var("after", *resp.Posts.PageInfo.EndCursor))
+	require.Len(t, next.Posts.Edges, 1)
+	assert.Equal(t, "first", next.Posts.Edges[0].Node.Title)
+	assert.False(t, next.Posts.PageInfo.HasNextPage)
+}
+
+func TestPosts_DefaultArguments(t *testing.T) {
+	c := newClient()
+
+	var resp postsResponse
+	err := c.Post(`{ posts { edges { cursor } pageInfo { hasNextPage endCursor } totalCount } }`, &resp)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Posts.Edges)
+	assert.False(t, resp.Posts.PageInfo.HasNextPage)
+	assert.Nil(t, resp.Posts.PageInfo.EndCursor)
+}
+
+func TestPosts_PageSizeLimit(t *testing.T) {
+	c := newClient()
+
+	resp, err := c.RawPost(`{ posts(first: 1000) { totalCount } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "first must not exceed 100", errs[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
+
+func TestComments_RepliesConnection(t *testing.T) {
+	c := newClient()
+
+	var post struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "t", content: "c", author: "a"}) { id } }`, &post)
+
+	var root struct{ AddComment struct{ ID string } }
+	c.MustPost(`mutation($postID: ID!) { addComment(input: {postID: $postID, content: "root", author: "a"}) { id } }`,
+		&root, client.Var("postID", post.CreatePost.ID))
+	for _, content := range []string{"r1", "r2"} {
+		var reply struct{ AddComment struct{ ID string } }
+		c.MustPost(`mutation($postID: ID!, $parentID: ID, $content: String!) { addComment(input: {postID: $postID, parentID: $parentID, content: $content, author: "a"}) { id } }`,
+			&reply, client.Var("postID", post.CreatePost.ID), client.Var("parentID", root.AddComment.ID), client.Var("content", content))
+	}
+
+	var resp struct {
+		Post struct {
+			Comments struct {
+				TotalCount int
+				Edges      []struct {
+					Node struct {
+						Content string
+						Replies struct {
+							TotalCount int
+							Edges      []struct{ Node struct{ Content string } }
+							PageInfo   struct{ HasPreviousPage bool }
+						}
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) {
+		post(id: $id) {
+			comments {
+				totalCount
+				edges { node { content replies(last: 1) { totalCount edges { node { content } } pageInfo { hasPreviousPage } } } }
+			}
+		}
+	}`, &resp, client.Var("id", post.CreatePost.ID))
+
+	comments := resp.Post.Comments
+	assert.Equal(t, 1, comments.TotalCount)
+	require.Len(t, comments.Edges, 1)
+	replies := comments.Edges[0].Node.Replies
+	assert.Equal(t, 2, replies.TotalCount)
+	require.Len(t, replies.Edges, 1)
+	assert.Equal(t, "r1", replies.Edges[0].Node.Content)
+	assert.True(t, replies.PageInfo.HasPreviousPage)
+}
+
+func TestPosts_FilterAndOrder(t *testing.T) {
+	c := newClient()
+	var postIDs []string
+	for _, author := range []string{"alice", "bob", "alice"} {
+		var created struct{ CreatePost struct{ ID string } }
+		c.MustPost(`mutation($author: String!) { createPost(input: {title: "t", content: "c", author: $author}) { id } }`,
+			&created, client.Var("author", author))
+		postIDs = append(postIDs, created.CreatePost.ID)
+	}
+	var comment struct{ AddComment struct{ ID string } }
+	c.MustPost(`mutation($postID: ID!) { addComment(input: {postID: $postID, content: "c", author: "a"}) { id } }`,
+		&comment, client.Var("postID", postIDs[0]))
+
+	var resp struct {
+		Posts struct {
+			Edges      []struct{ Node struct{ ID string } }
+			TotalCount int
+		}
+	}
+	c.MustPost(`{
+		posts(filter: {author: "alice"}, orderBy: MOST_COMMENTED) {
+			edges { node { id } }
+			totalCount
+		}
+	}`, &resp)
+
+	require.Len(t, resp.Posts.Edges, 2)
+	assert.Equal(t, postIDs[0], resp.Posts.Edges[0].Node.ID)
+	assert.Equal(t, postIDs[2], resp.Posts.Edges[1].Node.ID)
+	assert.Equal(t, 2, resp.Posts.TotalCount)
+}
+
+func TestPosts_InvalidFilter(t *testing.T) {
+	c := newClient()
+
+	resp, err := c.RawPost(`{ posts(filter: {createdAfter: "yesterday"}) { totalCount } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "createdAfter must be an RFC3339 time", errs[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
@@ -0,0 +1,74 @@
+package resolvers
+
+import (
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// newPost переводит пост хранилища в ответ GraphQL
+func newPost(post *store.Post) *model.Post {
+	var updatedAt, deletedAt *string
+	if post.UpdatedAt != nil {
+		t := post.UpdatedAt.Format(time.RFC3339)
+		updatedAt = &t
+	}
+	if post.DeletedAt != nil {
+		t := post.DeletedAt.Format(time.RFC3339)
+		deletedAt = &t
+	}
+	return &model.Post{
+		ID:                   service.EncodeGlobalID(service.NodePost, post.ID),
+		Title:                post.Title,
+		Content:              post.Content,
+		Author:               post.Author,
+		AllowComments:        post.AllowComments,
+		CreatedAt:            post.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:            updatedAt,
+		DeletedAt:            deletedAt,
+		CommentCount:         post.CommentCount,
+		TopLevelCommentCount: post.TopLevelCommentCount,
+	}
+}
+
+// newComment переводит комментарий хранилища в ответ GraphQL
+func newComment(comment *store.Comment) *model.Comment {
+	var parentID, editedAt, deletedAt *string
+	if comment.ParentID != nil {
+		id := service.EncodeGlobalID(service.NodeComment, *comment.ParentID)
+		parentID = &id
+	}
+	if comment.EditedAt != nil {
+		t := comment.EditedAt.Format(time.RFC3339)
+		editedAt = &t
+	}
+	if comment.DeletedAt != nil {
+		t := comment.DeletedAt.Format(time.RFC3339)
+		deletedAt = &t
+	}
+	return &model.Comment{
+		ID:              service.EncodeGlobalID(service.NodeComment, comment.ID),
+		PostID:          service.EncodeGlobalID(service.NodePost, comment.PostID),
+		ParentID:        parentID,
+		Content:         comment.Content,
+		Author:          comment.Author,
+		CreatedAt:       comment.CreatedAt.Format(time.RFC3339),
+		Depth:           comment.Depth,
+		RootID:          service.EncodeGlobalID(service.NodeComment, comment.RootID),
+		ReplyCount:      comment.ReplyCount,
+		DescendantCount: comment.DescendantCount,
+		EditedAt:        editedAt,
+		IsDeleted:       comment.DeletedAt != nil,
+		DeletedAt:       deletedAt,
+		DeleteReason:    newDeleteReason(comment.DeleteReason),
+	}
+}
+
+// localID возвращает ID записи хранилища по глобальному ID из ответа GraphQL
+func localID(t service.NodeType, id string) string {
+	// ID в ответах кодирует newPost и newComment, тип в них всегда верный
+	local, _ := service.DecodeID(t, id)
+	return local
+}
@@ -0,0 +1,52 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounters(t *testing.T) {
+	c := newClient()
+	var post struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "t", content: "c", author: "a"}) { id } }`, &post)
+	postID := post.CreatePost.ID
+
+	add := func(parentID *string) string {
+		var resp struct{ AddComment struct{ ID string } }
+		c.MustPost(`mutation($postID: ID!, $parentID: ID) { addComment(input: {postID: $postID, parentID: $parentID, content: "c", author: "b"}) { id } }`,
+			&resp, client.Var("postID", postID), client.Var("parentID", parentID))
+		return resp.AddComment.ID
+	}
+	root := add(nil)
+	reply := add(&root)
+	add(&reply)
+	add(&root)
+
+	var resp struct {
+		Post struct {
+			CommentCount         int
+			TopLevelCommentCount int
+			Comments             struct {
+				Edges []struct {
+					Node struct {
+						ReplyCount      int
+						DescendantCount int
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) {
+		commentCount topLevelCommentCount
+		comments { edges { node { replyCount descendantCount } } }
+	} }`, &resp, client.Var("id", postID))
+
+	assert.Equal(t, 4, resp.Post.CommentCount)
+	assert.Equal(t, 1, resp.Post.TopLevelCommentCount)
+	if assert.Len(t, resp.Post.Comments.Edges, 1) {
+		assert.Equal(t, 2, resp.Post.Comments.Edges[0].Node.ReplyCount)
+		assert.Equal(t, 3, resp.Post.Comments.Edges[0].Node.DescendantCount)
+	}
+}
@@ -0,0 +1,37 @@
+package resolvers
+
+import (
+	"context"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// internalErrorMessage — сообщение для клиента вместо внутренней ошибки
+const internalErrorMessage = "internal server error"
+
+// ErrorPresenter переводит ошибки резолверов в ответ GraphQL
+// Код ошибки сервиса передаётся в extensions.code. Текст внутренних
+// ошибок (например, ошибок базы данных) клиенту не показывается, только пишется в лог
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+
+	// Ошибки разбора и проверки запроса формирует сам gqlgen
+	if gqlErr.Unwrap() == nil {
+		return gqlErr
+	}
+
+	code := service.ErrorCode(err)
+	if code == service.CodeInternal {
+		log.Printf("internal error at %s: %v", gqlErr.Path, err)
+		gqlErr.Message = internalErrorMessage
+	}
+
+	if gqlErr.Extensions == nil {
+		gqlErr.Extensions = make(map[string]interface{})
+	}
+	gqlErr.Extensions["code"] = code
+	return gqlErr
+}
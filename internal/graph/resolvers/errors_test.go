@@ -0,0 +1,115 @@
+package resolvers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/SobolevTim/t-graphql/internal/graph/generated"
+	"github.com/SobolevTim/t-graphql/internal/graph/resolvers"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// newClient создаёт клиента GraphQL поверх in-memory хранилища
+func newClient() *client.Client {
+	return newStoreClient(store.NewMemoryStore())
+}
+
+// newStoreClient создаёт клиента GraphQL поверх хранилища storage
+// с расширениями сервера exts
+func newStoreClient(storage store.Store, exts ...graphql.HandlerExtension) *client.Client {
+	commentService := service.NewCommentService(storage)
+	resolver := resolvers.NewResolver(
+		service.NewPostService(storage),
+		commentService,
+		service.NewSubscriptionService(storage),
+		service.NewSearchService(storage),
+		service.NewNodeService(storage),
+	)
+	srv := handler.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  resolver,
+		Complexity: resolvers.NewComplexity(),
+	}))
+	srv.AddTransport(transport.POST{})
+	srv.SetErrorPresenter(resolvers.ErrorPresenter)
+	for _, ext := range exts {
+		srv.Use(ext)
+	}
+	return client.New(resolvers.UserMiddleware(resolvers.LoadersMiddleware(commentService)(srv)))
+}
+
+// asUser выполняет запрос от имени пользователя user
+func asUser(user string) client.Option {
+	return client.AddHeader(resolvers.UserHeader, user)
+}
+
+// createPost создаёт пост автора "a" и возвращает его ID
+func createPost(t *testing.T, c *client.Client) string {
+	t.Helper()
+	var resp struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "t", content: "c", author: "a"}) { id } }`, &resp)
+	return resp.CreatePost.ID
+}
+
+// addComment добавляет к посту postID комментарий автора "a" с текстом
+// "comment" (ответ на parentID, если он задан) и возвращает его ID
+func addComment(t *testing.T, c *client.Client, postID string, parentID *string) string {
+	t.Helper()
+	var resp struct{ AddComment struct{ ID string } }
+	c.MustPost(`mutation($postID: ID!, $parentID: ID) { addComment(input: {postID: $postID, parentID: $parentID, content: "comment", author: "a"}) { id } }`,
+		&resp, client.Var("postID", postID), client.Var("parentID", parentID))
+	return resp.AddComment.ID
+}
+
+// responseErrors разбирает ошибки из ответа GraphQL
+func responseErrors(t *testing.T, resp *client.Response) gqlerror.List {
+	var errs gqlerror.List
+	require.NoError(t, json.Unmarshal(resp.Errors, &errs))
+	return errs
+}
+
+func TestErrorPresenter_NotFound(t *testing.T) {
+	c := newClient()
+
+	resp, err := c.RawPost(`{ post(id: "missing") { id } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "failed to get post: post not found", errs[0].Message)
+	assert.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+}
+
+func TestErrorPresenter_InvalidInput(t *testing.T) {
+	c := newClient()
+
+	resp, err := c.RawPost(`mutation { createPost(input: {title: "", content: "c", author: "a"}) { id } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "title is required", errs[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
+
+func TestErrorPresenter_HidesInternalErrors(t *testing.T) {
+	gqlErr := resolvers.ErrorPresenter(context.Background(), errors.New("could not get post: connection refused"))
+	assert.Equal(t, "internal server error", gqlErr.Message)
+	assert.Equal(t, service.CodeInternal, gqlErr.Extensions["code"])
+}
+
+func TestErrorPresenter_KeepsGraphQLErrors(t *testing.T) {
+	original := gqlerror.Errorf("cannot parse input")
+	gqlErr := resolvers.ErrorPresenter(context.Background(), original)
+	assert.Equal(t, "cannot parse input", gqlErr.Message)
+	assert.NotContains(t, gqlErr.Extensions, "code")
+}
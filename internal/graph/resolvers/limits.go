@@ -0,0 +1,168 @@
+package resolvers
+
+import (
+	"context"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/SobolevTim/t-graphql/internal/graph/generated"
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Коды ошибок для операций, превысивших ограничения
+const (
+	CodeDepthLimitExceeded      = "DEPTH_LIMIT_EXCEEDED"
+	CodeComplexityLimitExceeded = "COMPLEXITY_LIMIT_EXCEEDED" // Код из extension.ComplexityLimit
+)
+
+// Размеры выборок по умолчанию, как в сервисах
+const (
+	defaultPageSize      = 10
+	defaultRelatedSize   = 5
+	defaultTreeDepth     = 5
+	defaultLimitPerLevel = 10
+)
+
+// DepthLimit отклоняет операции, в которых поля вложены глубже limit
+func DepthLimit(limit int) graphql.HandlerExtension {
+	return depthLimit{limit: limit}
+}
+
+type depthLimit struct {
+	limit int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = depthLimit{}
+
+func (depthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (depthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d depthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	depth := selectionDepth(opCtx.Operation.SelectionSet)
+	if depth > d.limit {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.limit)
+		errcode.Set(err, CodeDepthLimitExceeded)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth возвращает наибольшую вложенность полей в выборке
+// Фрагменты глубину не увеличивают, циклы в них отклоняет проверка запроса
+func selectionDepth(set ast.SelectionSet) int {
+	depth := 0
+	for _, selection := range set {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			d = 1 + selectionDepth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			d = selectionDepth(s.Definition.SelectionSet)
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet)
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
+
+// NewComplexity возвращает стоимость полей для extension.ComplexityLimit
+// Стоимость списка — стоимость одного элемента, умноженная на число
+// запрошенных элементов (first, last, limitPerLevel или размер по умолчанию)
+func NewComplexity() generated.ComplexityRoot {
+	var c generated.ComplexityRoot
+
+	c.Query.Posts = func(child int, _ *model.PostFilter, _ *model.PostOrder, first *int, _ *string, last *int, _ *string) int {
+		return listCost(child, pageSize(first, last))
+	}
+	c.Query.DeletedPosts = func(child int, _ *model.PostFilter, _ *model.PostOrder, first *int, _ *string, last *int, _ *string) int {
+		return listCost(child, pageSize(first, last))
+	}
+	c.Query.Search = func(child int, _ string, _ []model.SearchKind, first *int, _ *string) int {
+		return listCost(child, pageSize(first, nil))
+	}
+	c.Query.Nodes = func(child int, ids []string) int {
+		return listCost(child, len(ids))
+	}
+	c.Query.CommentThread = func(child int, _, _ string, maxDepth, limitPerLevel *int) int {
+		return treeCost(child, maxDepth, limitPerLevel)
+	}
+	c.Post.Comments = func(child int, first *int, _ *string, last *int, _ *string) int {
+		return listCost(child, pageSize(first, last))
+	}
+	c.Post.Related = func(child int, first *int) int {
+		return listCost(child, valueOr(first, defaultRelatedSize))
+	}
+	c.Post.CommentTree = func(child int, maxDepth, limitPerLevel *int) int {
+		return treeCost(child, maxDepth, limitPerLevel)
+	}
+	c.Comment.Replies = func(child int, first *int, _ *string, last *int, _ *string) int {
+		return listCost(child, pageSize(first, last))
+	}
+	return c
+}
+
+// pageSize возвращает число элементов страницы, запрошенное first или last
+func pageSize(first, last *int) int {
+	if last != nil {
+		return *last
+	}
+	return valueOr(first, defaultPageSize)
+}
+
+// treeCost оценивает стоимость ветки комментариев: limitPerLevel корневых
+// комментариев и до limitPerLevel ответов у каждого на maxDepth уровнях,
+// то есть сумму limitPerLevel^k узлов для k от 1 до maxDepth+1
+func treeCost(child int, maxDepth, limitPerLevel *int) int {
+	levels := valueOr(maxDepth, defaultTreeDepth) + 1
+	limit := max(valueOr(limitPerLevel, defaultLimitPerLevel), 1)
+	nodes, level := 0, 1
+	for range levels {
+		level = mulCost(level, limit)
+		nodes = addCost(nodes, level)
+	}
+	return listCost(child, nodes)
+}
+
+// mulCost умножает стоимости без переполнения
+func mulCost(a, b int) int {
+	if b > 0 && a > math.MaxInt/b {
+		return math.MaxInt
+	}
+	return a * b
+}
+
+// addCost складывает стоимости без переполнения
+func addCost(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// listCost возвращает стоимость n элементов стоимостью child без переполнения
+func listCost(child, n int) int {
+	n = max(n, 1)
+	if child > (math.MaxInt-1)/n {
+		return math.MaxInt
+	}
+	return 1 + child*n
+}
+
+func valueOr(value *int, defaultValue int) int {
+	if value == nil {
+		return defaultValue
+	}
+	return *value
+}
@@ -0,0 +1,118 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/SobolevTim/t-graphql/internal/graph/resolvers"
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLimitedClient создаёт клиента с ограничениями глубины и стоимости операций
+func newLimitedClient(depth, complexity int) *client.Client {
+	return newStoreClient(store.NewMemoryStore(),
+		resolvers.DepthLimit(depth), extension.FixedComplexityLimit(complexity))
+}
+
+func TestLimits_AllowsTypicalQuery(t *testing.T) {
+	c := newLimitedClient(15, 5000)
+
+	var resp struct {
+		Posts struct {
+			Edges []struct {
+				Node struct {
+					Comments struct {
+						Edges []struct {
+							Node struct {
+								Replies struct{ TotalCount int }
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`{ posts(first: 10) { edges { node { comments(first: 10) { edges { node { replies(first: 10) { totalCount } } } } } } } }`, &resp)
+}
+
+func TestLimits_Depth(t *testing.T) {
+	c := newLimitedClient(5, 5000)
+
+	resp, err := c.RawPost(`{ posts { edges { node { comments { edges { node { id } } } } } } }`)
+	require.NoError(t, err)
+	assert.Nil(t, resp.Data)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "operation has depth 7, which exceeds the limit of 5", errs[0].Message)
+	assert.Equal(t, resolvers.CodeDepthLimitExceeded, errs[0].Extensions["code"])
+}
+
+func TestLimits_DepthCountsFragments(t *testing.T) {
+	c := newLimitedClient(3, 5000)
+
+	resp, err := c.RawPost(`{ posts { ...Posts } } fragment Posts on PostConnection { edges { node { id } } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "operation has depth 4, which exceeds the limit of 3", errs[0].Message)
+}
+
+func TestLimits_Complexity(t *testing.T) {
+	c := newLimitedClient(15, 5000)
+
+	// Стоимость растёт с размером страниц на каждом уровне
+	resp, err := c.RawPost(`{ posts(first: 100) { edges { node { comments(first: 100) { edges { node { id } } } } } } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "operation has complexity 30301, which exceeds the limit of 5000", errs[0].Message)
+	assert.Equal(t, resolvers.CodeComplexityLimitExceeded, errs[0].Extensions["code"])
+
+	// Та же операция с небольшими страницами укладывается в ограничение
+	var ok struct {
+		Posts struct {
+			Edges []struct {
+				Node struct {
+					Comments struct {
+						Edges []struct{ Node struct{ ID string } }
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`{ posts(first: 5) { edges { node { comments(first: 5) { edges { node { id } } } } } } }`, &ok)
+}
+
+func TestLimits_CommentTreeComplexity(t *testing.T) {
+	c := newLimitedClient(15, 5000)
+
+	postID := createPost(t, c)
+
+	// У каждого комментария ветки до limitPerLevel ответов на каждом уровне
+	var tree struct {
+		Post struct{ CommentTree []struct{ ID string } }
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) { commentTree(maxDepth: 2, limitPerLevel: 10) { id } } }`,
+		&tree, client.Var("id", postID))
+
+	resp, err := c.RawPost(`{ post(id: "x") { commentTree(maxDepth: 3, limitPerLevel: 10) { id } } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "operation has complexity 11112, which exceeds the limit of 5000", errs[0].Message)
+
+	// Глубокое дерево не переполняет стоимость
+	resp, err = c.RawPost(`{ post(id: "x") { commentTree(maxDepth: 20, limitPerLevel: 1000) { id } } }`)
+	require.NoError(t, err)
+
+	errs = responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, resolvers.CodeComplexityLimitExceeded, errs[0].Extensions["code"])
+}
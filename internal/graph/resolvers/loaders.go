@@ -0,0 +1,144 @@
+package resolvers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/dataloader"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+type loadersKey struct{}
+
+// pageKey — сравнимая копия аргументов пагинации для ключа загрузчика
+type pageKey struct {
+	first, last         int
+	after, before       string
+	hasFirst, hasLast   bool
+	hasAfter, hasBefore bool
+}
+
+func newPageKey(args service.PageArgs) pageKey {
+	var k pageKey
+	if args.First != nil {
+		k.first, k.hasFirst = *args.First, true
+	}
+	if args.Last != nil {
+		k.last, k.hasLast = *args.Last, true
+	}
+	if args.After != nil {
+		k.after, k.hasAfter = *args.After, true
+	}
+	if args.Before != nil {
+		k.before, k.hasBefore = *args.Before, true
+	}
+	return k
+}
+
+func (k pageKey) args() service.PageArgs {
+	var args service.PageArgs
+	if k.hasFirst {
+		args.First = &k.first
+	}
+	if k.hasLast {
+		args.Last = &k.last
+	}
+	if k.hasAfter {
+		args.After = &k.after
+	}
+	if k.hasBefore {
+		args.Before = &k.before
+	}
+	return args
+}
+
+type commentPage = *store.Page[*store.Comment]
+
+// listPages загружает страницы args сразу для нескольких записей
+type listPages func(ctx context.Context, ids []string, args service.PageArgs) (map[string]commentPage, error)
+
+// pageLoaders — загрузчики страниц, по одному на набор аргументов пагинации
+// Ошибка в аргументах одного поля не затрагивает поля с другими аргументами
+type pageLoaders struct {
+	list listPages
+
+	mu      sync.Mutex
+	loaders map[pageKey]*dataloader.Loader[string, commentPage]
+}
+
+func newPageLoaders(list listPages) *pageLoaders {
+	return &pageLoaders{list: list, loaders: make(map[pageKey]*dataloader.Loader[string, commentPage])}
+}
+
+// load возвращает страницу записи id, для записи без комментариев — пустую
+func (p *pageLoaders) load(ctx context.Context, id string, args service.PageArgs) (commentPage, error) {
+	key := newPageKey(args)
+	p.mu.Lock()
+	loader, ok := p.loaders[key]
+	if !ok {
+		loader = dataloader.New(func(ctx context.Context, ids []string) (map[string]commentPage, error) {
+			return p.list(ctx, ids, key.args())
+		})
+		p.loaders[key] = loader
+	}
+	p.mu.Unlock()
+
+	page, err := loader.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if page == nil {
+		page = &store.Page[*store.Comment]{}
+	}
+	return page, nil
+}
+
+// Loaders — загрузчики одного запроса GraphQL
+// Страницы комментариев и ответов, запрошенные для разных постов и комментариев
+// с одинаковыми аргументами, загружаются одним вызовом хранилища, как и правки комментариев
+type Loaders struct {
+	comments     *pageLoaders
+	replies      *pageLoaders
+	commentCount *dataloader.Loader[string, int]
+	replyCount   *dataloader.Loader[string, int]
+	edits        *dataloader.Loader[string, []*store.CommentEdit]
+}
+
+// NewLoaders создаёт загрузчики поверх сервиса комментариев
+func NewLoaders(comments *service.CommentService) *Loaders {
+	return &Loaders{
+		comments:     newPageLoaders(comments.ListCommentsByPosts),
+		replies:      newPageLoaders(comments.ListRepliesByParents),
+		commentCount: dataloader.New(comments.CountCommentsByPosts),
+		replyCount:   dataloader.New(comments.CountRepliesByParents),
+		edits:        dataloader.New(comments.ListEditsByComments),
+	}
+}
+
+// LoadersMiddleware добавляет в контекст каждого запроса новые загрузчики
+// Для WebSocket загрузчики не добавляются: соединение живёт долго,
+// и кэш загрузчика отдавал бы подписчикам устаревшие данные
+func LoadersMiddleware(comments *service.CommentService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), loadersKey{}, NewLoaders(comments))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// loaders возвращает загрузчики запроса
+// Без middleware каждое поле получает свои загрузчики, то есть без объединения
+func (r *Resolver) loaders(ctx context.Context) *Loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*Loaders); ok {
+		return l
+	}
+	return NewLoaders(r.CommentService)
+}
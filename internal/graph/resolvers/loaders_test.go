@@ -0,0 +1,105 @@
+package resolvers_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore считает вызовы чтения комментариев
+type countingStore struct {
+	store.Store
+	single, batches atomic.Int32
+}
+
+func (s *countingStore) ListComments(ctx context.Context, postID string, parentID *string, page store.PageQuery) (*store.Page[*store.Comment], error) {
+	s.single.Add(1)
+	return s.Store.ListComments(ctx, postID, parentID, page)
+}
+
+func (s *countingStore) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	s.single.Add(1)
+	return s.Store.CountComments(ctx, postID, parentID)
+}
+
+func (s *countingStore) ListCommentsByPostIDs(ctx context.Context, postIDs []string, page store.PageQuery) (map[string]*store.Page[*store.Comment], error) {
+	s.batches.Add(1)
+	return s.Store.ListCommentsByPostIDs(ctx, postIDs, page)
+}
+
+func (s *countingStore) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page store.PageQuery) (map[string]*store.Page[*store.Comment], error) {
+	s.batches.Add(1)
+	return s.Store.ListRepliesByParentIDs(ctx, parentIDs, page)
+}
+
+func (s *countingStore) CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error) {
+	s.batches.Add(1)
+	return s.Store.CountCommentsByPostIDs(ctx, postIDs)
+}
+
+func (s *countingStore) CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	s.batches.Add(1)
+	return s.Store.CountRepliesByParentIDs(ctx, parentIDs)
+}
+
+func TestLoaders_BatchNestedComments(t *testing.T) {
+	storage := &countingStore{Store: store.NewMemoryStore()}
+	c := newStoreClient(storage)
+
+	for range 3 {
+		var post struct{ CreatePost struct{ ID string } }
+		c.MustPost(`mutation { createPost(input: {title: "t", content: "c", author: "a"}) { id } }`, &post)
+		for range 2 {
+			var comment struct{ AddComment struct{ ID string } }
+			c.MustPost(`mutation($postID: ID!) { addComment(input: {postID: $postID, content: "c", author: "b"}) { id } }`,
+				&comment, client.Var("postID", post.CreatePost.ID))
+			c.MustPost(`mutation($postID: ID!, $parentID: ID!) { addComment(input: {postID: $postID, parentID: $parentID, content: "r", author: "c"}) { id } }`,
+				&struct{ AddComment struct{ ID string } }{}, client.Var("postID", post.CreatePost.ID), client.Var("parentID", comment.AddComment.ID))
+		}
+	}
+	storage.single.Store(0)
+	storage.batches.Store(0)
+
+	var resp struct {
+		Posts struct {
+			Edges []struct {
+				Node struct {
+					Comments struct {
+						TotalCount int
+						Edges      []struct {
+							Node struct {
+								Replies struct {
+									TotalCount int
+									Edges      []struct{ Node struct{ Content string } }
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	c.MustPost(`{ posts { edges { node { comments(first: 10) {
+		totalCount
+		edges { node { replies { totalCount edges { node { content } } } } }
+	} } } } }`, &resp)
+
+	require.Len(t, resp.Posts.Edges, 3)
+	for _, post := range resp.Posts.Edges {
+		assert.Equal(t, 2, post.Node.Comments.TotalCount)
+		require.Len(t, post.Node.Comments.Edges, 2)
+		for _, comment := range post.Node.Comments.Edges {
+			assert.Equal(t, 1, comment.Node.Replies.TotalCount)
+			require.Len(t, comment.Node.Replies.Edges, 1)
+			assert.Equal(t, "r", comment.Node.Replies.Edges[0].Node.Content)
+		}
+	}
+	// По одному пакету на комментарии, ответы и их счётчики
+	assert.Zero(t, storage.single.Load())
+	assert.Equal(t, int32(4), storage.batches.Load())
+}
@@ -0,0 +1,43 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+)
+
+// Node возвращает пост или комментарий по глобальному ID.
+func (r *queryResolver) Node(ctx context.Context, id string) (model.Node, error) {
+	nodes, err := r.Nodes(ctx, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return nodes[0], nil
+}
+
+// Nodes возвращает записи по глобальным ID в порядке запроса, null для ненайденных.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	nodes, err := r.NodeService.Nodes(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.Node, len(nodes))
+	for i, node := range nodes {
+		result[i] = newNode(node)
+	}
+	return result, nil
+}
+
+// newNode переводит найденную запись в ответ GraphQL
+func newNode(node *service.Node) model.Node {
+	switch {
+	case node == nil:
+		return nil
+	case node.Post != nil:
+		return newPost(node.Post)
+	default:
+		return newComment(node.Comment)
+	}
+}
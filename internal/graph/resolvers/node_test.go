@@ -0,0 +1,60 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNode(t *testing.T) {
+	c := newClient()
+	postID := createPost(t, c)
+	commentID := addComment(t, c, postID, nil)
+	assert.NotEqual(t, postID, commentID)
+
+	var resp struct {
+		Node struct {
+			Typename string `json:"__typename"`
+			ID       string
+			Content  string
+			PostID   string
+		}
+	}
+	c.MustPost(`query($id: ID!) { node(id: $id) { __typename id ... on Comment { content postID } } }`,
+		&resp, client.Var("id", commentID))
+	assert.Equal(t, "Comment", resp.Node.Typename)
+	assert.Equal(t, commentID, resp.Node.ID)
+	assert.Equal(t, "comment", resp.Node.Content)
+	assert.Equal(t, postID, resp.Node.PostID)
+}
+
+func TestNodes(t *testing.T) {
+	c := newClient()
+	postID := createPost(t, c)
+
+	var resp struct {
+		Nodes []*struct {
+			ID    string
+			Title string
+		}
+	}
+	c.MustPost(`query($ids: [ID!]!) { nodes(ids: $ids) { id ... on Post { title } } }`,
+		&resp, client.Var("ids", []string{postID, "UG9zdDptaXNzaW5n"}))
+	require.Len(t, resp.Nodes, 2)
+	assert.Equal(t, "t", resp.Nodes[0].Title)
+	assert.Nil(t, resp.Nodes[1])
+}
+
+func TestNode_WrongType(t *testing.T) {
+	c := newClient()
+	// ID поста нельзя передать вместо ID комментария
+	resp, err := c.RawPost(`query($postID: ID!) { commentThread(postID: $postID, rootID: $postID) { id } }`,
+		client.Var("postID", createPost(t, c)))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
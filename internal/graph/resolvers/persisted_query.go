@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CodeOperationNotAllowed — код ошибки для операции не из списка разрешённых
+const CodeOperationNotAllowed = "OPERATION_NOT_ALLOWED"
+
+// OperationAllowlist выполняет только операции из списка разрешённых (строгий режим)
+// Операцию можно прислать текстом или, по протоколу APQ, только хешем
+// в extensions.persistedQuery.sha256Hash
+type OperationAllowlist struct {
+	Queries *service.PersistedQueryService
+	Cache   graphql.Cache[string] // Уже найденные операции по хешу; nil — без кэша
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationParameterMutator
+} = OperationAllowlist{}
+
+func (OperationAllowlist) ExtensionName() string {
+	return "OperationAllowlist"
+}
+
+func (a OperationAllowlist) Validate(graphql.ExecutableSchema) error {
+	if a.Queries == nil {
+		return errors.New("OperationAllowlist.Queries can not be nil")
+	}
+	return nil
+}
+
+func (a OperationAllowlist) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	var hash string
+	if persisted, ok := params.Extensions["persistedQuery"].(map[string]any); ok {
+		hash, _ = persisted["sha256Hash"].(string)
+	}
+	if params.Query != "" {
+		queryHash := service.QueryHash(params.Query)
+		if hash != "" && hash != queryHash {
+			return gqlerror.Errorf("provided APQ hash does not match query")
+		}
+		hash = queryHash
+	}
+	if hash == "" {
+		return notAllowed()
+	}
+
+	query, err := a.query(ctx, hash)
+	if service.ErrorCode(err) == service.CodeNotFound {
+		return notAllowed()
+	}
+	if err != nil {
+		log.Printf("could not check operation allowlist: %v", err)
+		gqlErr := gqlerror.Errorf(internalErrorMessage)
+		errcode.Set(gqlErr, string(service.CodeInternal))
+		return gqlErr
+	}
+	params.Query = query
+	return nil
+}
+
+// query возвращает текст разрешённой операции из кэша или хранилища
+func (a OperationAllowlist) query(ctx context.Context, hash string) (string, error) {
+	if a.Cache != nil {
+		if query, ok := a.Cache.Get(ctx, hash); ok {
+			return query, nil
+		}
+	}
+	query, err := a.Queries.Query(ctx, hash)
+	if err != nil {
+		return "", err
+	}
+	if a.Cache != nil {
+		a.Cache.Add(ctx, hash, query)
+	}
+	return query, nil
+}
+
+// notAllowed создаёт ошибку для операции не из списка разрешённых
+func notAllowed() *gqlerror.Error {
+	err := gqlerror.Errorf("operation is not in the allowlist")
+	errcode.Set(err, CodeOperationNotAllowed)
+	return err
+}
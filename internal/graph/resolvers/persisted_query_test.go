@@ -0,0 +1,84 @@
+package resolvers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/SobolevTim/t-graphql/internal/graph/resolvers"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const postsQuery = `{ posts { totalCount } }`
+
+// persistedQuery возвращает расширение запроса с хешем операции по протоколу APQ
+func persistedQuery(query string) client.Option {
+	return client.Extensions(map[string]any{
+		"persistedQuery": map[string]any{"version": 1, "sha256Hash": service.QueryHash(query)},
+	})
+}
+
+// newAllowlistClient создаёт клиента в строгом режиме с операцией postsQuery в списке разрешённых
+func newAllowlistClient(t *testing.T) *client.Client {
+	storage := store.NewMemoryStore()
+	err := storage.SavePersistedQueries(context.Background(), []*store.PersistedQuery{
+		{Hash: service.QueryHash(postsQuery), Name: "Posts", Query: postsQuery},
+	})
+	require.NoError(t, err)
+
+	allowlist := resolvers.OperationAllowlist{
+		Queries: service.NewPersistedQueryService(storage),
+		Cache:   lru.New[string](10),
+	}
+	return newStoreClient(storage, allowlist)
+}
+
+func TestAutomaticPersistedQuery(t *testing.T) {
+	c := newStoreClient(store.NewMemoryStore(), extension.AutomaticPersistedQuery{Cache: lru.New[string](10)})
+
+	// Незнакомый хеш: клиент должен повторить запрос вместе с текстом операции
+	resp, err := c.RawPost("", persistedQuery(postsQuery))
+	require.NoError(t, err)
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "PersistedQueryNotFound", errs[0].Message)
+
+	var posts struct{ Posts struct{ TotalCount int } }
+	c.MustPost(postsQuery, &posts, persistedQuery(postsQuery))
+
+	// Теперь операцию можно выполнить по одному хешу
+	c.MustPost("", &posts, persistedQuery(postsQuery))
+}
+
+func TestOperationAllowlist(t *testing.T) {
+	c := newAllowlistClient(t)
+
+	var posts struct{ Posts struct{ TotalCount int } }
+	c.MustPost(postsQuery, &posts)
+	c.MustPost("", &posts, persistedQuery(postsQuery))
+}
+
+func TestOperationAllowlist_Rejects(t *testing.T) {
+	c := newAllowlistClient(t)
+	other := `{ posts { edges { cursor } } }`
+
+	for name, send := range map[string]func() (*client.Response, error){
+		"Query": func() (*client.Response, error) { return c.RawPost(other) },
+		"Hash":  func() (*client.Response, error) { return c.RawPost("", persistedQuery(other)) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := send()
+			require.NoError(t, err)
+
+			errs := responseErrors(t, resp)
+			require.Len(t, errs, 1)
+			assert.Equal(t, "operation is not in the allowlist", errs[0].Message)
+			assert.Equal(t, resolvers.CodeOperationNotAllowed, errs[0].Extensions["code"])
+		})
+	}
+}
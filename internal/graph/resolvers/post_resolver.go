@@ -2,79 +2,90 @@ package resolvers
 
 import (
 	"context"
-	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
 )
 
 // CreatePost создаёт новый пост.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput) (*model.Post, error) {
-	post, err := r.PostService.CreatePost(input.Title, input.Content, input.Author, *input.AllowComments)
+	post, err := r.PostService.CreatePost(ctx, input.Title, input.Content, input.Author, *input.AllowComments)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.Post{
-		ID:            post.ID,
-		Title:         post.Title,
-		Content:       post.Content,
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return newPost(post), nil
 }
 
 // UpdatePostCommentsPermission обновляет разрешение на комментарии.
 func (r *mutationResolver) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*model.Post, error) {
-	post, err := r.PostService.UpdatePostCommentsPermission(postID, allowComments)
+	postID, err := service.DecodeID(service.NodePost, postID)
 	if err != nil {
 		return nil, err
 	}
 
-	return &model.Post{
-		ID:            post.ID,
-		Title:         post.Title,
-		Content:       post.Content,
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	post, err := r.PostService.UpdatePostCommentsPermission(ctx, postID, allowComments)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPost(post), nil
+}
+
+// UpdatePost изменяет заголовок и текст поста.
+func (r *mutationResolver) UpdatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	id, err := service.DecodeID(service.NodePost, input.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.PostService.UpdatePost(ctx, id, service.PostUpdate{
+		Title:   input.Title,
+		Content: input.Content,
+		Editor:  input.Editor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newPost(post), nil
 }
 
 // Post возвращает пост по ID.
 func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error) {
-	post, err := r.PostService.GetPostByID(id)
+	id, err := service.DecodeID(service.NodePost, id)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.PostService.GetPostByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// GraphQL сам вызовет r.Replies, когда запросят вложенные комментарии
-	return &model.Post{
-		ID:            post.ID,
-		Title:         post.Title,
-		Content:       post.Content,
-		Author:        post.Author,
-		AllowComments: post.AllowComments,
-		CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-	}, nil
+	return newPost(post), nil
 }
 
-// Posts возвращает все посты.
-func (r *queryResolver) Posts(ctx context.Context, page *int, pageSize *int) ([]*model.Post, error) {
-	posts, err := r.PostService.GetPosts(*page, *pageSize)
+// Posts возвращает страницу постов по фильтру в заданном порядке.
+func (r *queryResolver) Posts(ctx context.Context, filter *model.PostFilter, orderBy *model.PostOrder, first *int, after *string, last *int, before *string) (*model.PostConnection, error) {
+	page, err := r.PostService.ListPosts(ctx, postFilter(filter), postOrder(orderBy), pageArgs(first, after, last, before))
 	if err != nil {
 		return nil, err
 	}
+	return newPostConnection(page, filter), nil
+}
 
-	var gqlPosts []*model.Post
-	for _, post := range posts {
-		gqlPosts = append(gqlPosts, &model.Post{
-			ID:            post.ID,
-			Title:         post.Title,
-			Content:       post.Content,
-			Author:        post.Author,
-			AllowComments: post.AllowComments,
-			CreatedAt:     post.CreatedAt.Format(time.RFC3339),
-		})
+// Related возвращает посты, похожие на данный.
+func (r *postResolver) Related(ctx context.Context, obj *model.Post, first *int) ([]*model.Post, error) {
+	related, err := r.PostService.RelatedPosts(ctx, localID(service.NodePost, obj.ID), first)
+	if err != nil {
+		return nil, err
 	}
 
-	return gqlPosts, nil
+	posts := make([]*model.Post, 0, len(related))
+	for _, rp := range related {
+		posts = append(posts, newPost(rp.Post))
+	}
+	return posts, nil
 }
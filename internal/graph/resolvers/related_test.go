@@ -0,0 +1,36 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPost_Related(t *testing.T) {
+	c := newClient()
+	create := func(title, content string) string {
+		var created struct{ CreatePost struct{ ID string } }
+		c.MustPost(`mutation($title: String!, $content: String!) { createPost(input: {title: $title, content: $content, author: "a"}) { id } }`,
+			&created, client.Var("title", title), client.Var("content", content))
+		return created.CreatePost.ID
+	}
+	first := create("GraphQL cursor pagination", "Relay connections with opaque cursors")
+	create("Sourdough bread", "Flour, water and salt")
+	second := create("Cursor pagination in GraphQL", "Opaque cursors for Relay connections")
+
+	var resp struct {
+		Post struct {
+			Related []struct{ ID string }
+		}
+	}
+	// Сходство сохраняется в обе стороны
+	c.MustPost(`query($id: ID!) { post(id: $id) { related(first: 3) { id } } }`, &resp, client.Var("id", first))
+	require.Len(t, resp.Post.Related, 1)
+	assert.Equal(t, second, resp.Post.Related[0].ID)
+
+	c.MustPost(`query($id: ID!) { post(id: $id) { related { id } } }`, &resp, client.Var("id", second))
+	require.Len(t, resp.Post.Related, 1)
+	assert.Equal(t, first, resp.Post.Related[0].ID)
+}
@@ -10,26 +10,40 @@ type Resolver struct {
 	PostService         *service.PostService
 	CommentService      *service.CommentService
 	SubscriptionService *service.SubscriptionService
+	SearchService       *service.SearchService
+	NodeService         *service.NodeService
 }
 
 // NewResolver — конструктор резолвера.
-func NewResolver(postService *service.PostService, commentService *service.CommentService, subscriptionService *service.SubscriptionService) *Resolver {
+func NewResolver(postService *service.PostService, commentService *service.CommentService, subscriptionService *service.SubscriptionService, searchService *service.SearchService, nodeService *service.NodeService) *Resolver {
 	return &Resolver{
 		PostService:         postService,
 		CommentService:      commentService,
 		SubscriptionService: subscriptionService,
+		SearchService:       searchService,
+		NodeService:         nodeService,
 	}
 }
 
 // Comment returns generated.CommentResolver implementation.
 func (r *Resolver) Comment() generated.CommentResolver { return &commentResolver{r} }
 
+// CommentConnection returns generated.CommentConnectionResolver implementation.
+func (r *Resolver) CommentConnection() generated.CommentConnectionResolver {
+	return &commentConnectionResolver{r}
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
 // Post returns generated.PostResolver implementation.
 func (r *Resolver) Post() generated.PostResolver { return &postResolver{r} }
 
+// PostConnection returns generated.PostConnectionResolver implementation.
+func (r *Resolver) PostConnection() generated.PostConnectionResolver {
+	return &postConnectionResolver{r}
+}
+
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
@@ -37,7 +51,9 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
 
 type commentResolver struct{ *Resolver }
+type commentConnectionResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
+type postConnectionResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
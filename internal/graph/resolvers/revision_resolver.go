@@ -0,0 +1,64 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+)
+
+// Revisions возвращает прежние версии поста.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	revisions, err := r.PostService.PostRevisions(ctx, localID(service.NodePost, obj.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*model.PostRevision, 0, len(revisions))
+	for _, rev := range revisions {
+		result = append(result, &model.PostRevision{
+			Number:   rev.Number,
+			Title:    rev.Title,
+			Content:  rev.Content,
+			Editor:   rev.Editor,
+			EditedAt: rev.EditedAt.Format(time.RFC3339),
+		})
+	}
+	return result, nil
+}
+
+// RevisionDiff возвращает построчную разницу между версиями поста.
+func (r *postResolver) RevisionDiff(ctx context.Context, obj *model.Post, from int, to *int) (*model.RevisionDiff, error) {
+	diff, err := r.PostService.RevisionDiff(ctx, localID(service.NodePost, obj.ID), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.RevisionDiff{
+		From:    diff.From,
+		To:      diff.To,
+		Title:   newDiffLines(diff.Title),
+		Content: newDiffLines(diff.Content),
+	}, nil
+}
+
+// newDiffLines переводит строки разницы в ответ GraphQL
+func newDiffLines(lines []service.DiffLine) []*model.DiffLine {
+	result := make([]*model.DiffLine, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, &model.DiffLine{Kind: diffKind(line.Op), Text: line.Text})
+	}
+	return result
+}
+
+func diffKind(op service.DiffOp) model.DiffKind {
+	switch op {
+	case service.DiffInsert:
+		return model.DiffKindInsert
+	case service.DiffDelete:
+		return model.DiffKindDelete
+	default:
+		return model.DiffKindEqual
+	}
+}
@@ -0,0 +1,83 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdatePost_Revisions(t *testing.T) {
+	c := newClient()
+
+	var created struct {
+		CreatePost struct {
+			ID        string
+			UpdatedAt *string
+		}
+	}
+	c.MustPost(`mutation { createPost(input: {title: "Draft", content: "one\ntwo", author: "a"}) { id updatedAt } }`, &created)
+	id := created.CreatePost.ID
+	assert.Nil(t, created.CreatePost.UpdatedAt)
+
+	var updated struct {
+		UpdatePost struct {
+			Title     string
+			Content   string
+			UpdatedAt *string
+		}
+	}
+	c.MustPost(`mutation($id: ID!) { updatePost(input: {id: $id, content: "one\nthree", editor: "e"}) { title content updatedAt } }`,
+		&updated, client.Var("id", id))
+	assert.Equal(t, "Draft", updated.UpdatePost.Title)
+	assert.Equal(t, "one\nthree", updated.UpdatePost.Content)
+	assert.NotNil(t, updated.UpdatePost.UpdatedAt)
+
+	type diffLine struct{ Kind, Text string }
+	var resp struct {
+		Post struct {
+			Revisions []struct {
+				Number  int
+				Content string
+				Editor  string
+			}
+			RevisionDiff struct {
+				From, To int
+				Content  []diffLine
+			}
+		}
+	}
+	c.MustPost(`query($id: ID!) { post(id: $id) {
+		revisions { number content editor }
+		revisionDiff(from: 1) { from to content { kind text } }
+	} }`, &resp, client.Var("id", id))
+
+	require.Len(t, resp.Post.Revisions, 1)
+	assert.Equal(t, 1, resp.Post.Revisions[0].Number)
+	assert.Equal(t, "one\ntwo", resp.Post.Revisions[0].Content)
+	assert.Equal(t, "e", resp.Post.Revisions[0].Editor)
+
+	assert.Equal(t, 1, resp.Post.RevisionDiff.From)
+	assert.Equal(t, 2, resp.Post.RevisionDiff.To)
+	assert.Equal(t, []diffLine{
+		{Kind: "EQUAL", Text: "one"},
+		{Kind: "DELETE", Text: "two"},
+		{Kind: "INSERT", Text: "three"},
+	}, resp.Post.RevisionDiff.Content)
+}
+
+func TestUpdatePost_InvalidInput(t *testing.T) {
+	c := newClient()
+	var created struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "t", content: "c", author: "a"}) { id } }`, &created)
+
+	resp, err := c.RawPost(`mutation($id: ID!) { updatePost(input: {id: $id, editor: "e"}) { id } }`,
+		client.Var("id", created.CreatePost.ID))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "title or content is required", errs[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
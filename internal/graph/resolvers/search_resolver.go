@@ -0,0 +1,51 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// Search ищет посты и комментарии по словам запроса.
+func (r *queryResolver) Search(ctx context.Context, query string, kinds []model.SearchKind, first *int, after *string) (*model.SearchConnection, error) {
+	page, err := r.SearchService.Search(ctx, query, searchKinds(kinds), pageArgs(first, after, nil, nil))
+	if err != nil {
+		return nil, err
+	}
+	return newSearchConnection(page), nil
+}
+
+// searchKinds переводит виды записей из запроса в виды хранилища
+func searchKinds(kinds []model.SearchKind) []store.SearchKind {
+	result := make([]store.SearchKind, 0, len(kinds))
+	for _, kind := range kinds {
+		switch kind {
+		case model.SearchKindPost:
+			result = append(result, store.SearchPosts)
+		case model.SearchKindComment:
+			result = append(result, store.SearchComments)
+		}
+	}
+	return result
+}
+
+// newSearchConnection переводит страницу результатов поиска в ответ GraphQL
+func newSearchConnection(page *store.Page[*store.SearchHit]) *model.SearchConnection {
+	edges := make([]*model.SearchEdge, 0, len(page.Items))
+	for _, hit := range page.Items {
+		edge := &model.SearchEdge{
+			Cursor:  service.EncodeCursor(hit.Cursor()),
+			Score:   hit.Score,
+			Snippet: hit.Snippet,
+		}
+		if hit.Post != nil {
+			edge.Node = newPost(hit.Post)
+		} else {
+			edge.Node = newComment(hit.Comment)
+		}
+		edges = append(edges, edge)
+	}
+	return &model.SearchConnection{Edges: edges, PageInfo: newPageInfo(page)}
+}
@@ -0,0 +1,94 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchResponse struct {
+	Search struct {
+		Edges []struct {
+			Cursor  string
+			Snippet string
+			Score   float64
+			Node    struct {
+				Typename string `json:"__typename"`
+				ID       string
+				Title    string
+				Content  string
+			}
+		}
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   *string
+		}
+	}
+}
+
+const searchQuery = `query($query: String!, $kinds: [SearchKind!], $first: Int, $after: String) {
+	search(query: $query, kinds: $kinds, first: $first, after: $after) {
+		edges {
+			cursor snippet score
+			node {
+				__typename
+				... on Post { id title }
+				... on Comment { id content }
+			}
+		}
+		pageInfo { hasNextPage endCursor }
+	}
+}`
+
+func TestSearch_PostsAndComments(t *testing.T) {
+	c := newClient()
+	var post struct{ CreatePost struct{ ID string } }
+	c.MustPost(`mutation { createPost(input: {title: "GraphQL search", content: "Ranked results", author: "a"}) { id } }`, &post)
+	var comment struct{ AddComment struct{ ID string } }
+	c.MustPost(`mutation($postID: ID!) { addComment(input: {postID: $postID, content: "Search works", author: "b"}) { id } }`,
+		&comment, client.Var("postID", post.CreatePost.ID))
+
+	var resp searchResponse
+	c.MustPost(searchQuery, &resp, client.Var("query", "search"))
+	require.Len(t, resp.Search.Edges, 2)
+	first, second := resp.Search.Edges[0], resp.Search.Edges[1]
+	assert.Equal(t, "Post", first.Node.Typename)
+	assert.Equal(t, post.CreatePost.ID, first.Node.ID)
+	assert.Equal(t, "GraphQL <b>search</b> Ranked results", first.Snippet)
+	assert.Equal(t, "Comment", second.Node.Typename)
+	assert.Equal(t, "Search works", second.Node.Content)
+	assert.Equal(t, "<b>Search</b> works", second.Snippet)
+	assert.Greater(t, first.Score, second.Score)
+
+	var page searchResponse
+	c.MustPost(searchQuery, &page, client.Var("query", "search"), client.Var("first", 1))
+	require.Len(t, page.Search.Edges, 1)
+	assert.True(t, page.Search.PageInfo.HasNextPage)
+	require.NotNil(t, page.Search.PageInfo.EndCursor)
+
+	var next searchResponse
+	c.MustPost(searchQuery, &next, client.Var("query", "search"), client.Var("first", 1),
+		client.Var("after", *page.Search.PageInfo.EndCursor))
+	require.Len(t, next.Search.Edges, 1)
+	assert.Equal(t, comment.AddComment.ID, next.Search.Edges[0].Node.ID)
+	assert.False(t, next.Search.PageInfo.HasNextPage)
+
+	var comments searchResponse
+	c.MustPost(searchQuery, &comments, client.Var("query", "search"), client.Var("kinds", []string{"COMMENT"}))
+	require.Len(t, comments.Search.Edges, 1)
+	assert.Equal(t, "Comment", comments.Search.Edges[0].Node.Typename)
+}
+
+func TestSearch_EmptyQuery(t *testing.T) {
+	c := newClient()
+
+	resp, err := c.RawPost(`{ search(query: " ") { edges { cursor } } }`)
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "query is required", errs[0].Message)
+	assert.Equal(t, "BAD_USER_INPUT", errs[0].Extensions["code"])
+}
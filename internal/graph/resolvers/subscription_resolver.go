@@ -2,26 +2,29 @@ package resolvers
 
 import (
 	"context"
-	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+	"github.com/SobolevTim/t-graphql/internal/store"
 )
 
 // CommentAdded — резолвер для подписки на новые комментарии
+// Изменённые комментарии приходят в ту же подписку, их отличает editedAt
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
-	chStore, unsubscribe := r.SubscriptionService.Subscribe(postID)
+	postID, err := service.DecodeID(service.NodePost, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	chStore, unsubscribe := r.SubscriptionService.Subscribe(ctx, postID)
 	ch := make(chan *model.Comment)
 
 	go func() {
-		for comment := range chStore {
-			ch <- &model.Comment{
-				ID:        comment.ID,
-				PostID:    comment.PostID,
-				ParentID:  comment.ParentID,
-				Content:   comment.Content,
-				Author:    comment.Author,
-				CreatedAt: comment.CreatedAt.Format(time.RFC3339),
+		for event := range chStore {
+			if event.Type != store.EventCommentAdded && event.Type != store.EventCommentEdited {
+				continue
 			}
+			ch <- newComment(event.Comment)
 		}
 		close(ch)
 	}()
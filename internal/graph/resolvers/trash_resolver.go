@@ -0,0 +1,52 @@
+package resolvers
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/graph/model"
+	"github.com/SobolevTim/t-graphql/internal/service"
+)
+
+// DeletePost переносит пост в корзину.
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (*model.Post, error) {
+	id, err := service.DecodeID(service.NodePost, id)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.PostService.DeletePost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPost(post), nil
+}
+
+// RestorePost возвращает пост из корзины.
+func (r *mutationResolver) RestorePost(ctx context.Context, id string) (*model.Post, error) {
+	id, err := service.DecodeID(service.NodePost, id)
+	if err != nil {
+		return nil, err
+	}
+
+	post, err := r.PostService.RestorePost(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPost(post), nil
+}
+
+// DeletedPosts возвращает страницу постов из корзины.
+func (r *queryResolver) DeletedPosts(ctx context.Context, filter *model.PostFilter, orderBy *model.PostOrder, first *int, after *string, last *int, before *string) (*model.PostConnection, error) {
+	f := postFilter(filter)
+	f.Deleted = true
+	page, err := r.PostService.ListPosts(ctx, f, postOrder(orderBy), pageArgs(first, after, last, before))
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newPostConnection(page, filter)
+	conn.Deleted = true
+	return conn, nil
+}
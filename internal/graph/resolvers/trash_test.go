@@ -0,0 +1,72 @@
+package resolvers_test
+
+import (
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeletePost_Trash(t *testing.T) {
+	c := newClient()
+	id := createPost(t, c)
+
+	var deleted struct {
+		DeletePost struct {
+			ID        string
+			DeletedAt *string
+		}
+	}
+	c.MustPost(`mutation($id: ID!) { deletePost(id: $id) { id deletedAt } }`, &deleted, client.Var("id", id))
+	assert.Equal(t, id, deleted.DeletePost.ID)
+	assert.NotNil(t, deleted.DeletePost.DeletedAt)
+
+	resp, err := c.RawPost(`query($id: ID!) { post(id: $id) { id } }`, client.Var("id", id))
+	require.NoError(t, err)
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+
+	type connection struct {
+		Edges []struct {
+			Node struct{ ID string }
+		}
+		TotalCount int
+	}
+	var lists struct {
+		Posts        connection
+		DeletedPosts connection
+	}
+	c.MustPost(`{
+		posts { edges { node { id } } totalCount }
+		deletedPosts { edges { node { id } } totalCount }
+	}`, &lists)
+	assert.Empty(t, lists.Posts.Edges)
+	assert.Zero(t, lists.Posts.TotalCount)
+	require.Len(t, lists.DeletedPosts.Edges, 1)
+	assert.Equal(t, id, lists.DeletedPosts.Edges[0].Node.ID)
+	assert.Equal(t, 1, lists.DeletedPosts.TotalCount)
+
+	var restored struct {
+		RestorePost struct{ DeletedAt *string }
+	}
+	c.MustPost(`mutation($id: ID!) { restorePost(id: $id) { deletedAt } }`, &restored, client.Var("id", id))
+	assert.Nil(t, restored.RestorePost.DeletedAt)
+
+	var post struct{ Post struct{ ID string } }
+	c.MustPost(`query($id: ID!) { post(id: $id) { id } }`, &post, client.Var("id", id))
+	assert.Equal(t, id, post.Post.ID)
+}
+
+func TestRestorePost_NotDeleted(t *testing.T) {
+	c := newClient()
+	resp, err := c.RawPost(`mutation($id: ID!) { restorePost(id: $id) { id } }`,
+		client.Var("id", createPost(t, c)))
+	require.NoError(t, err)
+
+	errs := responseErrors(t, resp)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "failed to restore post: post not found", errs[0].Message)
+	assert.Equal(t, "NOT_FOUND", errs[0].Extensions["code"])
+}
@@ -0,0 +1,24 @@
+package resolvers
+
+import (
+	"net/http"
+
+	"github.com/SobolevTim/t-graphql/internal/service"
+)
+
+// UserHeader — заголовок с именем пользователя запроса
+// API не проверяет его подлинность: заголовок должен выставлять прокси
+// после аутентификации, отбрасывая значение, присланное клиентом
+const UserHeader = "X-User"
+
+// UserMiddleware добавляет в контекст запроса пользователя из заголовка UserHeader
+// Для WebSocket заголовок берётся из запроса на подключение
+// и действует для всех операций соединения
+func UserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := r.Header.Get(UserHeader); user != "" {
+			r = r.WithContext(service.WithUser(r.Context(), user))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
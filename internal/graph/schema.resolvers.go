@@ -12,11 +12,21 @@ import (
 	"github.com/SobolevTim/t-graphql/internal/graph/model"
 )
 
+// Edits is the resolver for the edits field.
+func (r *commentResolver) Edits(ctx context.Context, obj *model.Comment) ([]*model.CommentEdit, error) {
+	panic(fmt.Errorf("not implemented: Edits - edits"))
+}
+
 // Replies is the resolver for the replies field.
-func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, page *int, pageSize *int) ([]*model.Comment, error) {
+func (r *commentResolver) Replies(ctx context.Context, obj *model.Comment, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
 	panic(fmt.Errorf("not implemented: Replies - replies"))
 }
 
+// TotalCount is the resolver for the totalCount field.
+func (r *commentConnectionResolver) TotalCount(ctx context.Context, obj *model.CommentConnection) (int, error) {
+	panic(fmt.Errorf("not implemented: TotalCount - totalCount"))
+}
+
 // CreatePost is the resolver for the createPost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.CreatePostInput) (*model.Post, error) {
 	panic(fmt.Errorf("not implemented: CreatePost - createPost"))
@@ -27,18 +37,68 @@ func (r *mutationResolver) UpdatePostCommentsPermission(ctx context.Context, pos
 	panic(fmt.Errorf("not implemented: UpdatePostCommentsPermission - updatePostCommentsPermission"))
 }
 
+// UpdatePost is the resolver for the updatePost field.
+func (r *mutationResolver) UpdatePost(ctx context.Context, input model.UpdatePostInput) (*model.Post, error) {
+	panic(fmt.Errorf("not implemented: UpdatePost - updatePost"))
+}
+
+// DeletePost is the resolver for the deletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, id string) (*model.Post, error) {
+	panic(fmt.Errorf("not implemented: DeletePost - deletePost"))
+}
+
+// RestorePost is the resolver for the restorePost field.
+func (r *mutationResolver) RestorePost(ctx context.Context, id string) (*model.Post, error) {
+	panic(fmt.Errorf("not implemented: RestorePost - restorePost"))
+}
+
 // AddComment is the resolver for the addComment field.
 func (r *mutationResolver) AddComment(ctx context.Context, input model.AddCommentInput) (*model.Comment, error) {
 	panic(fmt.Errorf("not implemented: AddComment - addComment"))
 }
 
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, content string) (*model.Comment, error) {
+	panic(fmt.Errorf("not implemented: EditComment - editComment"))
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string, reason model.CommentDeleteReason) (*model.DeleteCommentPayload, error) {
+	panic(fmt.Errorf("not implemented: DeleteComment - deleteComment"))
+}
+
 // Comments is the resolver for the comments field.
-func (r *postResolver) Comments(ctx context.Context, obj *model.Post, page *int, pageSize *int) ([]*model.Comment, error) {
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string) (*model.CommentConnection, error) {
 	panic(fmt.Errorf("not implemented: Comments - comments"))
 }
 
+// Related is the resolver for the related field.
+func (r *postResolver) Related(ctx context.Context, obj *model.Post, first *int) ([]*model.Post, error) {
+	panic(fmt.Errorf("not implemented: Related - related"))
+}
+
+// CommentTree is the resolver for the commentTree field.
+func (r *postResolver) CommentTree(ctx context.Context, obj *model.Post, maxDepth *int, limitPerLevel *int) ([]*model.Comment, error) {
+	panic(fmt.Errorf("not implemented: CommentTree - commentTree"))
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post) ([]*model.PostRevision, error) {
+	panic(fmt.Errorf("not implemented: Revisions - revisions"))
+}
+
+// RevisionDiff is the resolver for the revisionDiff field.
+func (r *postResolver) RevisionDiff(ctx context.Context, obj *model.Post, from int, to *int) (*model.RevisionDiff, error) {
+	panic(fmt.Errorf("not implemented: RevisionDiff - revisionDiff"))
+}
+
+// TotalCount is the resolver for the totalCount field.
+func (r *postConnectionResolver) TotalCount(ctx context.Context, obj *model.PostConnection) (int, error) {
+	panic(fmt.Errorf("not implemented: TotalCount - totalCount"))
+}
+
 // Posts is the resolver for the posts field.
-func (r *queryResolver) Posts(ctx context.Context, page *int, pageSize *int) ([]*model.Post, error) {
+func (r *queryResolver) Posts(ctx context.Context, filter *model.PostFilter, orderBy *model.PostOrder, first *int, after *string, last *int, before *string) (*model.PostConnection, error) {
 	panic(fmt.Errorf("not implemented: Posts - posts"))
 }
 
@@ -47,6 +107,31 @@ func (r *queryResolver) Post(ctx context.Context, id string) (*model.Post, error
 	panic(fmt.Errorf("not implemented: Post - post"))
 }
 
+// Search is the resolver for the search field.
+func (r *queryResolver) Search(ctx context.Context, query string, kinds []model.SearchKind, first *int, after *string) (*model.SearchConnection, error) {
+	panic(fmt.Errorf("not implemented: Search - search"))
+}
+
+// CommentThread is the resolver for the commentThread field.
+func (r *queryResolver) CommentThread(ctx context.Context, postID string, rootID string, maxDepth *int, limitPerLevel *int) ([]*model.Comment, error) {
+	panic(fmt.Errorf("not implemented: CommentThread - commentThread"))
+}
+
+// Node is the resolver for the node field.
+func (r *queryResolver) Node(ctx context.Context, id string) (model.Node, error) {
+	panic(fmt.Errorf("not implemented: Node - node"))
+}
+
+// Nodes is the resolver for the nodes field.
+func (r *queryResolver) Nodes(ctx context.Context, ids []string) ([]model.Node, error) {
+	panic(fmt.Errorf("not implemented: Nodes - nodes"))
+}
+
+// DeletedPosts is the resolver for the deletedPosts field.
+func (r *queryResolver) DeletedPosts(ctx context.Context, filter *model.PostFilter, orderBy *model.PostOrder, first *int, after *string, last *int, before *string) (*model.PostConnection, error) {
+	panic(fmt.Errorf("not implemented: DeletedPosts - deletedPosts"))
+}
+
 // CommentAdded is the resolver for the commentAdded field.
 func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string) (<-chan *model.Comment, error) {
 	panic(fmt.Errorf("not implemented: CommentAdded - commentAdded"))
@@ -55,12 +140,22 @@ func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID string)
 // Comment returns generated.CommentResolver implementation.
 func (r *Resolver) Comment() generated.CommentResolver { return &commentResolver{r} }
 
+// CommentConnection returns generated.CommentConnectionResolver implementation.
+func (r *Resolver) CommentConnection() generated.CommentConnectionResolver {
+	return &commentConnectionResolver{r}
+}
+
 // Mutation returns generated.MutationResolver implementation.
 func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
 
 // Post returns generated.PostResolver implementation.
 func (r *Resolver) Post() generated.PostResolver { return &postResolver{r} }
 
+// PostConnection returns generated.PostConnectionResolver implementation.
+func (r *Resolver) PostConnection() generated.PostConnectionResolver {
+	return &postConnectionResolver{r}
+}
+
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
@@ -68,7 +163,9 @@ func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
 
 type commentResolver struct{ *Resolver }
+type commentConnectionResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type postResolver struct{ *Resolver }
+type postConnectionResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,213 @@
+// Package migrate применяет версионированные миграции схемы базы данных
+// Миграции встроены в бинарник и лежат в migrations/<СУБД>/ в виде пар
+// файлов NNNN_name.up.sql и NNNN_name.down.sql
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration — одна версия схемы
+type Migration struct {
+	Version int64  // Номер версии, определяет порядок применения
+	Name    string // Имя из названия файла
+	Up      string // SQL для применения
+	Down    string // SQL для отката
+}
+
+// Status — состояние одной миграции
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Driver выполняет миграции на конкретной СУБД
+type Driver interface {
+	// Lock не даёт нескольким экземплярам мигрировать одновременно
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+	// EnsureTable создаёт таблицу schema_migrations
+	EnsureTable(ctx context.Context) error
+	// Applied возвращает время применения каждой применённой версии
+	Applied(ctx context.Context) (map[int64]time.Time, error)
+	// Apply в одной транзакции выполняет SQL миграции
+	// и добавляет (up) или удаляет (down) запись о версии
+	Apply(ctx context.Context, m Migration, up bool) error
+}
+
+// Migrator применяет и откатывает миграции
+type Migrator struct {
+	driver     Driver
+	migrations []Migration
+}
+
+// New создаёт Migrator для драйвера и набора миграций
+func New(driver Driver, migrations []Migration) *Migrator {
+	return &Migrator{driver: driver, migrations: migrations}
+}
+
+// Up применяет все ещё не применённые миграции
+// Возвращает количество применённых миграций
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.driver.Apply(ctx, migration, true); err != nil {
+				return fmt.Errorf("could not apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down откатывает steps последних применённых миграций
+// steps <= 0 откатывает все миграции
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := m.locked(ctx, func(applied map[int64]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			if steps > 0 && count >= steps {
+				break
+			}
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.driver.Apply(ctx, migration, false); err != nil {
+				return fmt.Errorf("could not revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Status возвращает состояние всех известных миграций
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(applied map[int64]time.Time) error {
+		for _, migration := range m.migrations {
+			appliedAt, ok := applied[migration.Version]
+			statuses = append(statuses, Status{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				Applied:   ok,
+				AppliedAt: appliedAt,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked выполняет fn под блокировкой миграций
+func (m *Migrator) locked(ctx context.Context, fn func(applied map[int64]time.Time) error) (err error) {
+	if err := m.driver.Lock(ctx); err != nil {
+		return fmt.Errorf("could not lock migrations: %w", err)
+	}
+	defer func() {
+		if unlockErr := m.driver.Unlock(ctx); unlockErr != nil && err == nil {
+			err = fmt.Errorf("could not unlock migrations: %w", unlockErr)
+		}
+	}()
+
+	if err := m.driver.EnsureTable(ctx); err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+	applied, err := m.driver.Applied(ctx)
+	if err != nil {
+		return fmt.Errorf("could not read applied migrations: %w", err)
+	}
+	return fn(applied)
+}
+
+// Load читает миграции из каталога dir в fsys
+// Каждой версии должны соответствовать оба файла: .up.sql и .down.sql
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		version, name, up, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("migration %04d has different names: %s and %s", version, migration.Name, name)
+		}
+		if up {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// parseFileName разбирает имя вида 0001_init.up.sql
+func parseFileName(fileName string) (version int64, name string, up bool, err error) {
+	base, ok := strings.CutSuffix(fileName, ".sql")
+	if !ok {
+		return 0, "", false, fmt.Errorf("unexpected migration file %s", fileName)
+	}
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		base, up = strings.TrimSuffix(base, ".up"), true
+	case strings.HasSuffix(base, ".down"):
+		base = strings.TrimSuffix(base, ".down")
+	default:
+		return 0, "", false, fmt.Errorf("migration %s must end with .up.sql or .down.sql", fileName)
+	}
+
+	rawVersion, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", false, fmt.Errorf("migration %s must be named NNNN_name", fileName)
+	}
+	version, err = strconv.ParseInt(rawVersion, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", false, fmt.Errorf("invalid migration version in %s", fileName)
+	}
+	return version, name, up, nil
+}
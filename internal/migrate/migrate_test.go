@@ -0,0 +1,123 @@
+package migrate_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/migrate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver хранит применённые версии в памяти
+type fakeDriver struct {
+	applied map[int64]time.Time
+	log     []string
+}
+
+func newFakeDriver() *fakeDriver {
+	return &fakeDriver{applied: make(map[int64]time.Time)}
+}
+
+func (d *fakeDriver) Lock(ctx context.Context) error        { return nil }
+func (d *fakeDriver) Unlock(ctx context.Context) error      { return nil }
+func (d *fakeDriver) EnsureTable(ctx context.Context) error { return nil }
+
+func (d *fakeDriver) Applied(ctx context.Context) (map[int64]time.Time, error) {
+	applied := make(map[int64]time.Time, len(d.applied))
+	for v, at := range d.applied {
+		applied[v] = at
+	}
+	return applied, nil
+}
+
+func (d *fakeDriver) Apply(ctx context.Context, m migrate.Migration, up bool) error {
+	if up {
+		d.applied[m.Version] = time.Now()
+		d.log = append(d.log, m.Up)
+	} else {
+		delete(d.applied, m.Version)
+		d.log = append(d.log, m.Down)
+	}
+	return nil
+}
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"m/0002_second.up.sql":   {Data: []byte("up 2")},
+		"m/0002_second.down.sql": {Data: []byte("down 2")},
+		"m/0001_first.up.sql":    {Data: []byte("up 1")},
+		"m/0001_first.down.sql":  {Data: []byte("down 1")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := migrate.Load(testFS(), "m")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, migrate.Migration{Version: 1, Name: "first", Up: "up 1", Down: "down 1"}, migrations[0])
+	assert.Equal(t, migrate.Migration{Version: 2, Name: "second", Up: "up 2", Down: "down 2"}, migrations[1])
+}
+
+func TestLoad_MissingDown(t *testing.T) {
+	fsys := testFS()
+	delete(fsys, "m/0002_second.down.sql")
+
+	_, err := migrate.Load(fsys, "m")
+	assert.Error(t, err)
+}
+
+func TestLoad_InvalidName(t *testing.T) {
+	fsys := testFS()
+	fsys["m/first.up.sql"] = &fstest.MapFile{Data: []byte("up")}
+
+	_, err := migrate.Load(fsys, "m")
+	assert.Error(t, err)
+}
+
+func TestUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	migrations, err := migrate.Load(testFS(), "m")
+	require.NoError(t, err)
+	driver := newFakeDriver()
+	migrator := migrate.New(driver, migrations)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+
+	// Повторный запуск ничего не применяет
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, applied)
+
+	reverted, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.False(t, statuses[1].Applied)
+
+	reverted, err = migrator.Down(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+
+	assert.Equal(t, []string{"up 1", "up 2", "down 2", "down 1"}, driver.log)
+}
+
+func TestNewPostgres_LoadsEmbeddedMigrations(t *testing.T) {
+	migrator, err := migrate.NewPostgres(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, migrator)
+}
+
+func TestNewSQLite_LoadsEmbeddedMigrations(t *testing.T) {
+	migrator, err := migrate.NewSQLite(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, migrator)
+}
@@ -0,0 +1,24 @@
+-- Таблица постов
+CREATE TABLE IF NOT EXISTS posts (
+    id UUID PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    allow_comments BOOLEAN NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Таблица комментариев
+CREATE TABLE IF NOT EXISTS comments (
+    id UUID PRIMARY KEY,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES comments(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Базы, созданные прежним init.sql, содержат триггер уведомлений.
+-- События теперь публикует только приложение (store.Publish)
+DROP TRIGGER IF EXISTS comment_inserted ON comments;
+DROP FUNCTION IF EXISTS notify_comment();
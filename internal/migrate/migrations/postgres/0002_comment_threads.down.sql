@@ -0,0 +1,3 @@
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS root_id,
+    DROP COLUMN IF EXISTS depth;
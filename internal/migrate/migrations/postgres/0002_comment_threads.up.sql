@@ -0,0 +1,22 @@
+-- Глубина и корень ветки хранятся в комментарии,
+-- чтобы клиенты могли строить дерево без обхода родителей
+ALTER TABLE comments
+    ADD COLUMN depth INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN root_id UUID;
+
+-- Заполняем ветки уже существующих комментариев
+WITH RECURSIVE thread AS (
+    SELECT id, 0 AS depth, id AS root_id
+    FROM comments
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT c.id, t.depth + 1, t.root_id
+    FROM comments c
+    JOIN thread t ON c.parent_id = t.id
+)
+UPDATE comments
+SET depth = thread.depth, root_id = thread.root_id
+FROM thread
+WHERE comments.id = thread.id;
+
+ALTER TABLE comments ALTER COLUMN root_id SET NOT NULL;
@@ -0,0 +1,15 @@
+-- Число комментариев к посту вместе с ответами,
+-- нужно для сортировки постов по обсуждаемости
+ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts
+SET comment_count = counts.total
+FROM (SELECT post_id, COUNT(*) AS total FROM comments GROUP BY post_id) AS counts
+WHERE posts.id = counts.post_id;
+
+-- Индексы под фильтр по автору и сортировку по числу комментариев
+CREATE INDEX IF NOT EXISTS posts_author_created_at_id_idx
+    ON posts (author, created_at, id);
+
+CREATE INDEX IF NOT EXISTS posts_comment_count_created_at_id_idx
+    ON posts (comment_count, created_at, id);
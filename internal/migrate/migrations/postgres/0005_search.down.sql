@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS comments_search_idx;
+DROP INDEX IF EXISTS posts_search_idx;
+DROP TRIGGER IF EXISTS comments_search_update ON comments;
+DROP TRIGGER IF EXISTS posts_search_update ON posts;
+DROP FUNCTION IF EXISTS comments_search_update();
+DROP FUNCTION IF EXISTS posts_search_update();
+ALTER TABLE comments DROP COLUMN IF EXISTS search;
+ALTER TABLE posts DROP COLUMN IF EXISTS search;
@@ -0,0 +1,36 @@
+-- Полнотекстовый поиск по постам и комментариям
+-- Слова заголовка весят больше слов текста. Конфигурация simple не зависит
+-- от языка: слова только приводятся к нижнему регистру
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS search tsvector;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search tsvector;
+
+CREATE OR REPLACE FUNCTION posts_search_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search := setweight(to_tsvector('simple', NEW.title), 'A') ||
+                  setweight(to_tsvector('simple', NEW.content), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION comments_search_update() RETURNS trigger AS $$
+BEGIN
+    NEW.search := setweight(to_tsvector('simple', NEW.content), 'B');
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+-- Триггеры пересчитывают вектор при создании записи и изменении текста
+CREATE TRIGGER posts_search_update
+    BEFORE INSERT OR UPDATE OF title, content ON posts
+    FOR EACH ROW EXECUTE FUNCTION posts_search_update();
+
+CREATE TRIGGER comments_search_update
+    BEFORE INSERT OR UPDATE OF content ON comments
+    FOR EACH ROW EXECUTE FUNCTION comments_search_update();
+
+-- Заполнение векторов для уже созданных записей через триггеры
+UPDATE posts SET title = title;
+UPDATE comments SET content = content;
+
+CREATE INDEX IF NOT EXISTS posts_search_idx ON posts USING GIN (search);
+CREATE INDEX IF NOT EXISTS comments_search_idx ON comments USING GIN (search);
@@ -0,0 +1,11 @@
+-- Сходство постов, вычисленное при их создании
+-- Каждая пара хранится в обе стороны, поэтому похожие посты выбираются по post_id
+CREATE TABLE IF NOT EXISTS related_posts (
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    related_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (post_id, related_id)
+);
+
+CREATE INDEX IF NOT EXISTS related_posts_post_score_idx
+    ON related_posts (post_id, score DESC);
@@ -0,0 +1,4 @@
+-- Индекс под выборку ответов по родителю без ID поста:
+-- пакетная загрузка ответов и обход дерева комментариев
+CREATE INDEX IF NOT EXISTS comments_parent_created_at_id_idx
+    ON comments (parent_id, created_at, id);
@@ -0,0 +1,28 @@
+-- Счётчики комментариев, которые обновляются вместе с созданием комментария:
+-- число комментариев верхнего уровня у поста, прямых ответов
+-- и ответов на всех уровнях ниже у комментария
+ALTER TABLE posts ADD COLUMN top_level_comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN descendant_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts
+SET top_level_comment_count = counts.total
+FROM (SELECT post_id, COUNT(*) AS total FROM comments WHERE parent_id IS NULL GROUP BY post_id) AS counts
+WHERE posts.id = counts.post_id;
+
+UPDATE comments
+SET reply_count = counts.total
+FROM (SELECT parent_id, COUNT(*) AS total FROM comments WHERE parent_id IS NOT NULL GROUP BY parent_id) AS counts
+WHERE comments.id = counts.parent_id;
+
+-- Каждая пара (предок, ответ) из всех веток
+WITH RECURSIVE chain AS (
+    SELECT parent_id AS ancestor_id FROM comments WHERE parent_id IS NOT NULL
+    UNION ALL
+    SELECT c.parent_id FROM chain JOIN comments c ON c.id = chain.ancestor_id
+    WHERE c.parent_id IS NOT NULL
+)
+UPDATE comments
+SET descendant_count = counts.total
+FROM (SELECT ancestor_id, COUNT(*) AS total FROM chain GROUP BY ancestor_id) AS counts
+WHERE comments.id = counts.ancestor_id;
@@ -0,0 +1,7 @@
+-- Список разрешённых операций GraphQL, загружаемый из манифеста
+-- Операции ищутся по SHA-256 их текста
+CREATE TABLE IF NOT EXISTS persisted_queries (
+    hash TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL
+);
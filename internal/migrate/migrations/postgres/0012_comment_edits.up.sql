@@ -0,0 +1,11 @@
+-- Изменение комментариев: время последнего изменения и прежние версии
+-- текста. Версии нумеруются с 1 для каждого комментария
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS comment_edits (
+    comment_id UUID NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    edited_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (comment_id, number)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE comments
+    DROP COLUMN IF EXISTS delete_reason,
+    DROP COLUMN IF EXISTS deleted_at;
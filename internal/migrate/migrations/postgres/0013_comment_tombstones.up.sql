@@ -0,0 +1,5 @@
+-- Удалённые комментарии с ответами остаются в ветке без текста и автора,
+-- с временем и причиной удаления
+ALTER TABLE comments
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS delete_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- Таблица постов
+-- Время хранится в наносекундах Unix (UTC), чтобы сортировка была точной
+CREATE TABLE IF NOT EXISTS posts (
+    id TEXT PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    allow_comments INTEGER NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+-- Таблица комментариев
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    parent_id TEXT REFERENCES comments(id) ON DELETE CASCADE,
+    content TEXT NOT NULL,
+    author TEXT NOT NULL,
+    created_at INTEGER NOT NULL
+);
@@ -0,0 +1,2 @@
+ALTER TABLE comments DROP COLUMN root_id;
+ALTER TABLE comments DROP COLUMN depth;
@@ -0,0 +1,7 @@
+-- Индексы под выборку страниц по ключу (created_at, id):
+-- запрос находит позицию курсора по индексу, а не пропускает записи через OFFSET
+CREATE INDEX IF NOT EXISTS posts_created_at_id_idx
+    ON posts (created_at, id);
+
+CREATE INDEX IF NOT EXISTS comments_post_parent_created_at_id_idx
+    ON comments (post_id, parent_id, created_at, id);
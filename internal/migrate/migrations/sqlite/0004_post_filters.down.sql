@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS posts_comment_count_created_at_id_idx;
+DROP INDEX IF EXISTS posts_author_created_at_id_idx;
+ALTER TABLE posts DROP COLUMN comment_count;
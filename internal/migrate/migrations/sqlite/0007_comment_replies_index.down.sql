@@ -0,0 +1 @@
+DROP INDEX IF EXISTS comments_parent_created_at_id_idx;
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN descendant_count;
+ALTER TABLE comments DROP COLUMN reply_count;
+ALTER TABLE posts DROP COLUMN top_level_comment_count;
@@ -0,0 +1,22 @@
+-- Счётчики комментариев, которые обновляются вместе с созданием комментария:
+-- число комментариев верхнего уровня у поста, прямых ответов
+-- и ответов на всех уровнях ниже у комментария
+ALTER TABLE posts ADD COLUMN top_level_comment_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE comments ADD COLUMN descendant_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE posts
+SET top_level_comment_count = (SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.parent_id IS NULL);
+
+UPDATE comments
+SET reply_count = (SELECT COUNT(*) FROM comments AS replies WHERE replies.parent_id = comments.id);
+
+-- Каждая пара (предок, ответ) из всех веток
+WITH RECURSIVE chain(ancestor_id) AS (
+    SELECT parent_id FROM comments WHERE parent_id IS NOT NULL
+    UNION ALL
+    SELECT c.parent_id FROM chain JOIN comments c ON c.id = chain.ancestor_id
+    WHERE c.parent_id IS NOT NULL
+)
+UPDATE comments
+SET descendant_count = (SELECT COUNT(*) FROM chain WHERE chain.ancestor_id = comments.id);
@@ -0,0 +1,13 @@
+-- Изменение постов: время последнего изменения и прежние версии
+-- заголовка и текста. Версии нумеруются с 1 для каждого поста
+ALTER TABLE posts ADD COLUMN updated_at INTEGER;
+
+CREATE TABLE IF NOT EXISTS post_revisions (
+    post_id TEXT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    number INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    editor TEXT NOT NULL,
+    edited_at INTEGER NOT NULL,
+    PRIMARY KEY (post_id, number)
+);
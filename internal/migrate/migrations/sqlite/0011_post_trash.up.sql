@@ -0,0 +1,7 @@
+-- Корзина постов: удалённый пост скрыт, пока его не восстановят
+-- или не удалят окончательно по истечении срока хранения
+ALTER TABLE posts ADD COLUMN deleted_at INTEGER;
+
+-- Индекс под окончательное удаление постов из корзины
+CREATE INDEX IF NOT EXISTS posts_deleted_at_idx
+    ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE comments DROP COLUMN delete_reason;
+ALTER TABLE comments DROP COLUMN deleted_at;
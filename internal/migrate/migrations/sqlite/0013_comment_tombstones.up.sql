@@ -0,0 +1,4 @@
+-- Удалённые комментарии с ответами остаются в ветке без текста и автора,
+-- с временем и причиной удаления
+ALTER TABLE comments ADD COLUMN deleted_at INTEGER;
+ALTER TABLE comments ADD COLUMN delete_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresFS embed.FS
+
+// postgresLockKey — ключ advisory-блокировки миграций
+const postgresLockKey = 7237512843
+
+// NewPostgres создаёт Migrator со встроенными миграциями PostgreSQL
+func NewPostgres(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := Load(postgresFS, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	return New(&postgresDriver{pool: pool}, migrations), nil
+}
+
+// postgresDriver выполняет миграции на одном соединении пула,
+// чтобы advisory-блокировка держалась всё время работы
+type postgresDriver struct {
+	pool *pgxpool.Pool
+	conn *pgxpool.Conn
+}
+
+func (d *postgresDriver) Lock(ctx context.Context) error {
+	conn, err := d.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, postgresLockKey); err != nil {
+		conn.Release()
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+func (d *postgresDriver) Unlock(ctx context.Context) error {
+	if d.conn == nil {
+		return errors.New("migrations are not locked")
+	}
+	defer func() {
+		d.conn.Release()
+		d.conn = nil
+	}()
+	_, err := d.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, postgresLockKey)
+	return err
+}
+
+func (d *postgresDriver) EnsureTable(ctx context.Context) error {
+	_, err := d.conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`)
+	return err
+}
+
+func (d *postgresDriver) Applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := d.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (d *postgresDriver) Apply(ctx context.Context, m Migration, up bool) error {
+	return pgx.BeginFunc(ctx, d.conn, func(tx pgx.Tx) error {
+		if up {
+			// Без аргументов pgx использует простой протокол,
+			// поэтому файл может содержать несколько выражений
+			if _, err := tx.Exec(ctx, m.Up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+			return err
+		}
+
+		if _, err := tx.Exec(ctx, m.Down); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+		return err
+	})
+}
@@ -0,0 +1,102 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteFS embed.FS
+
+// NewSQLite создаёт Migrator со встроенными миграциями SQLite
+func NewSQLite(db *sql.DB) (*Migrator, error) {
+	migrations, err := Load(sqliteFS, "migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return New(&sqliteDriver{db: db}, migrations), nil
+}
+
+// sqliteDriver выполняет миграции на одном соединении
+// Одновременную запись из нескольких процессов SQLite
+// сериализует сам: каждая миграция — отдельная транзакция
+type sqliteDriver struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+func (d *sqliteDriver) Lock(ctx context.Context) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	return nil
+}
+
+func (d *sqliteDriver) Unlock(ctx context.Context) error {
+	if d.conn == nil {
+		return errors.New("migrations are not locked")
+	}
+	err := d.conn.Close()
+	d.conn = nil
+	return err
+}
+
+func (d *sqliteDriver) EnsureTable(ctx context.Context) error {
+	_, err := d.conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at INTEGER NOT NULL
+		)`)
+	return err
+}
+
+func (d *sqliteDriver) Applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := d.conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version, appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = time.Unix(0, appliedAt)
+	}
+	return applied, rows.Err()
+}
+
+func (d *sqliteDriver) Apply(ctx context.Context, m Migration, up bool) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if up {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().UnixNano()); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// DeleteComment удаляет комментарий id с причиной reason от имени
+// пользователя запроса, см. WithUser. С причиной store.DeletedByAuthor
+// удалить комментарий может только его автор, с остальными причинами —
+// только модератор, см. WithModerators.
+// Комментарий с ответами остаётся в ветке без текста и автора;
+// возвращается он или nil, если комментарий удалён целиком
+func (s *CommentService) DeleteComment(ctx context.Context, id, reason string) (*store.Comment, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch reason {
+	case store.DeletedByAuthor, store.DeletedAsSpam, store.DeletedAsAbuse, store.DeletedAsOffTopic:
+	default:
+		return nil, invalidInput("unknown delete reason")
+	}
+
+	comment, err := s.store.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to get comment", err)
+	}
+	if comment.DeletedAt != nil {
+		return nil, invalidInput("comment is already deleted")
+	}
+	if reason == store.DeletedByAuthor && comment.Author != user {
+		return nil, forbidden("only the author can delete the comment")
+	}
+	if reason != store.DeletedByAuthor && !s.opts.moderators[user] {
+		return nil, forbidden("only moderators can delete the comment for this reason")
+	}
+
+	kept, err := s.store.DeleteComment(ctx, id, reason)
+	if err != nil {
+		return nil, storeError("failed to delete comment", err)
+	}
+	return kept, nil
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// EditComment заменяет текст комментария, сохраняя прежний в истории правок
+// Изменить комментарий может только его автор (пользователь запроса,
+// см. WithUser) и только в течение окна правки после создания.
+// Если текст не изменился, комментарий возвращается без записи новой правки
+func (s *CommentService) EditComment(ctx context.Context, id, content string) (*store.Comment, error) {
+	user, err := currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkContent(content); err != nil {
+		return nil, err
+	}
+
+	comment, err := s.store.GetCommentByID(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to get comment", err)
+	}
+	if comment.DeletedAt != nil {
+		return nil, forbidden("comment is deleted")
+	}
+	if comment.Author != user {
+		return nil, forbidden("only the author can edit the comment")
+	}
+	if s.opts.editWindow > 0 && time.Since(comment.CreatedAt) > s.opts.editWindow {
+		return nil, forbidden("edit window has expired")
+	}
+	if content == comment.Content {
+		return comment, nil
+	}
+
+	comment, err = s.store.UpdateComment(ctx, id, content)
+	if err != nil {
+		return nil, storeError("failed to edit comment", err)
+	}
+	return comment, nil
+}
+
+// ListEditsByComments возвращает правки сразу для нескольких комментариев
+func (s *CommentService) ListEditsByComments(ctx context.Context, commentIDs []string) (map[string][]*store.CommentEdit, error) {
+	edits, err := s.store.ListCommentEdits(ctx, commentIDs)
+	if err != nil {
+		return nil, storeError("failed to get comment edits", err)
+	}
+	return edits, nil
+}
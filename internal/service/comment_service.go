@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -17,35 +18,44 @@ const (
 // CommentService отвечает за работу с комментариями
 type CommentService struct {
 	store store.Store
+	opts  options
 }
 
 // NewCommentService создаёт сервис для работы с комментариями
-func NewCommentService(store store.Store) *CommentService {
-	return &CommentService{store: store}
+func NewCommentService(store store.Store, opts ...Option) *CommentService {
+	return &CommentService{store: store, opts: newOptions(opts)}
 }
 
 // CreateComment создаёт новый комментарий к посту
-func (s *CommentService) AddComment(postID, content, author string, ParentID *string) (*store.Comment, error) {
+func (s *CommentService) AddComment(ctx context.Context, postID, content, author string, ParentID *string) (*store.Comment, error) {
 	// Проверка наличия поста
-	post, err := s.store.GetPostByID(postID)
+	post, err := s.store.GetPostByID(ctx, postID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get post: %w", err)
+		return nil, storeError("failed to get post", err)
 	}
 
 	// Проверка размера комментария
-	if len(content) > defaultCommentSize {
-		return nil, errors.New("comment is too long")
+	if err := checkContent(content); err != nil {
+		return nil, err
 	}
 
 	// Проверка разрешения на комментарии
 	if !post.AllowComments {
-		return nil, errors.New("comments are not allowed")
+		return nil, forbidden("comments are not allowed")
+	}
+
+	// Проверка родительского комментария
+	if ParentID != nil {
+		if err := s.checkParent(ctx, postID, *ParentID); err != nil {
+			return nil, err
+		}
 	}
 
 	id := uuid.New().String()
 
 	// Создаём комментарий
 	comment, err := s.store.CreateComment(
+		ctx,
 		id,
 		postID,
 		ParentID,
@@ -53,30 +63,115 @@ func (s *CommentService) AddComment(postID, content, author string, ParentID *st
 		author,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create comment: %w", err)
+		return nil, storeError("failed to create comment", err)
 	}
 
 	return comment, nil
 }
 
-// GetCommentsByPostID возвращает комментарии к посту c постраничным выводом
-func (s *CommentService) GetCommentsByPostID(postID string, page, pageSize int) ([]*store.Comment, error) {
-	if page <= 0 {
-		page = defaultCommentPage
+// checkParent проверяет, что на комментарий parentID к посту postID можно ответить
+func (s *CommentService) checkParent(ctx context.Context, postID, parentID string) error {
+	parent, err := s.store.GetCommentByID(ctx, parentID)
+	if errors.Is(err, store.ErrNotFound) {
+		return invalidInput("parent comment not found")
+	}
+	if err != nil {
+		return storeError("failed to get parent comment", err)
+	}
+
+	if parent.PostID != postID {
+		return invalidInput("parent comment belongs to another post")
+	}
+	if parent.DeletedAt != nil {
+		return invalidInput("parent comment is deleted")
+	}
+	if maxDepth := s.opts.maxDepth; maxDepth > 0 && parent.Depth+1 > maxDepth {
+		return invalidInput(fmt.Sprintf("maximum reply depth of %d exceeded", maxDepth))
+	}
+	return nil
+}
+
+// ListComments возвращает страницу комментариев верхнего уровня к посту
+func (s *CommentService) ListComments(ctx context.Context, postID string, args PageArgs) (*store.Page[*store.Comment], error) {
+	return s.list(ctx, postID, nil, args)
+}
+
+// ListReplies возвращает страницу ответов на комментарий parentID
+func (s *CommentService) ListReplies(ctx context.Context, postID, parentID string, args PageArgs) (*store.Page[*store.Comment], error) {
+	return s.list(ctx, postID, &parentID, args)
+}
+
+func (s *CommentService) list(ctx context.Context, postID string, parentID *string, args PageArgs) (*store.Page[*store.Comment], error) {
+	q, err := s.opts.pageQuery(args)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.store.ListComments(ctx, postID, parentID, q)
+	if err != nil {
+		return nil, storeError("failed to get comments", err)
+	}
+	return page, nil
+}
+
+// CountComments возвращает число комментариев к посту с родителем parentID
+// parentID == nil — комментарии верхнего уровня
+func (s *CommentService) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	count, err := s.store.CountComments(ctx, postID, parentID)
+	if err != nil {
+		return 0, storeError("failed to count comments", err)
 	}
-	if pageSize <= 0 {
-		pageSize = defaultCommentPageSize
+	return count, nil
+}
+
+// checkContent проверяет текст нового или изменённого комментария
+func checkContent(content string) error {
+	if len(content) > defaultCommentSize {
+		return invalidInput("comment is too long")
 	}
-	return s.store.GetCommentsByPostID(postID, page, pageSize)
+	return nil
 }
 
-// GetCommentsByPostIDAndParentID возвращает ответы на комментарий к посту c постраничным выводом
-func (s *CommentService) GetCommentsByPostIDAndParentID(postID string, parentID *string, page, pageSize int) ([]*store.Comment, error) {
-	if page <= 0 {
-		page = defaultCommentPage
+// ListCommentsByPosts возвращает страницы комментариев верхнего уровня
+// сразу для нескольких постов, по одной странице args на пост
+func (s *CommentService) ListCommentsByPosts(ctx context.Context, postIDs []string, args PageArgs) (map[string]*store.Page[*store.Comment], error) {
+	q, err := s.opts.pageQuery(args)
+	if err != nil {
+		return nil, err
 	}
-	if pageSize <= 0 {
-		pageSize = defaultCommentPageSize
+	pages, err := s.store.ListCommentsByPostIDs(ctx, postIDs, q)
+	if err != nil {
+		return nil, storeError("failed to get comments", err)
+	}
+	return pages, nil
+}
+
+// ListRepliesByParents возвращает страницы ответов сразу для нескольких комментариев
+func (s *CommentService) ListRepliesByParents(ctx context.Context, parentIDs []string, args PageArgs) (map[string]*store.Page[*store.Comment], error) {
+	q, err := s.opts.pageQuery(args)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := s.store.ListRepliesByParentIDs(ctx, parentIDs, q)
+	if err != nil {
+		return nil, storeError("failed to get replies", err)
+	}
+	return pages, nil
+}
+
+// CountCommentsByPosts возвращает число комментариев верхнего уровня к постам
+func (s *CommentService) CountCommentsByPosts(ctx context.Context, postIDs []string) (map[string]int, error) {
+	counts, err := s.store.CountCommentsByPostIDs(ctx, postIDs)
+	if err != nil {
+		return nil, storeError("failed to count comments", err)
+	}
+	return counts, nil
+}
+
+// CountRepliesByParents возвращает число ответов на комментарии
+func (s *CommentService) CountRepliesByParents(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	counts, err := s.store.CountRepliesByParentIDs(ctx, parentIDs)
+	if err != nil {
+		return nil, storeError("failed to count replies", err)
 	}
-	return s.store.GetCommentsByPostIDAndParentID(postID, parentID, page, pageSize)
+	return counts, nil
 }
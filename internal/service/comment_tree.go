@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+const (
+	defaultTreeDepth = 5  // Сколько уровней ответов выбирается по умолчанию
+	maxTreeDepth     = 20 // Больше уровней ответов за один запрос не выбирается
+)
+
+// CommentTree возвращает ветку комментариев поста в порядке обхода в глубину:
+// комментарии верхнего уровня или, если задан rootID, комментарий rootID,
+// и до maxDepth уровней ответов, не больше limitPerLevel новейших на комментарий
+func (s *CommentService) CommentTree(ctx context.Context, postID string, rootID *string, maxDepth, limitPerLevel *int) ([]*store.Comment, error) {
+	query := store.TreeQuery{RootID: rootID, MaxDepth: defaultTreeDepth, LimitPerLevel: defaultPageSize}
+	if maxDepth != nil {
+		if *maxDepth < 0 {
+			return nil, invalidInput("maxDepth must not be negative")
+		}
+		if *maxDepth > maxTreeDepth {
+			return nil, invalidInput(fmt.Sprintf("maxDepth must not exceed %d", maxTreeDepth))
+		}
+		query.MaxDepth = *maxDepth
+	}
+	if limitPerLevel != nil {
+		if *limitPerLevel <= 0 {
+			return nil, invalidInput("limitPerLevel must be positive")
+		}
+		if *limitPerLevel > s.opts.maxPageSize {
+			return nil, invalidInput(fmt.Sprintf("limitPerLevel must not exceed %d", s.opts.maxPageSize))
+		}
+		query.LimitPerLevel = *limitPerLevel
+	}
+
+	tree, err := s.store.CommentTree(ctx, postID, query)
+	if err != nil {
+		return nil, storeError("failed to get comment tree", err)
+	}
+	return tree, nil
+}
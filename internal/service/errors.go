@@ -0,0 +1,77 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// Code — категория ошибки, которую видит клиент API
+type Code string
+
+const (
+	CodeInvalidInput    Code = "BAD_USER_INPUT"        // Некорректные входные данные
+	CodeNotFound        Code = "NOT_FOUND"             // Запись не найдена
+	CodeConflict        Code = "CONFLICT"              // Запись уже существует
+	CodeForbidden       Code = "FORBIDDEN"             // Действие запрещено
+	CodeUnauthenticated Code = "UNAUTHENTICATED"       // Пользователь запроса не определён
+	CodeInternal        Code = "INTERNAL_SERVER_ERROR" // Внутренняя ошибка, подробности не показываются клиенту
+)
+
+// Error — ошибка сервиса с кодом для клиента
+type Error struct {
+	Code    Code
+	Message string
+	Err     error // Исходная ошибка, если есть
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// invalidInput создаёт ошибку некорректных входных данных
+func invalidInput(message string) error {
+	return &Error{Code: CodeInvalidInput, Message: message}
+}
+
+// forbidden создаёт ошибку запрещённого действия
+func forbidden(message string) error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+// unauthenticated создаёт ошибку запроса без пользователя
+func unauthenticated(message string) error {
+	return &Error{Code: CodeUnauthenticated, Message: message}
+}
+
+// storeError переводит ошибку хранилища в ошибку сервиса
+// Неизвестные ошибки считаются внутренними
+func storeError(message string, err error) error {
+	code := CodeInternal
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		code = CodeNotFound
+	case errors.Is(err, store.ErrAlreadyExists):
+		code = CodeConflict
+	case errors.Is(err, store.ErrInvalidParent):
+		code = CodeInvalidInput
+	}
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// ErrorCode возвращает код ошибки сервиса или CodeInternal,
+// если err не является ошибкой сервиса
+func ErrorCode(err error) Code {
+	var svcErr *Error
+	if errors.As(err, &svcErr) {
+		return svcErr.Code
+	}
+	return CodeInternal
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// NodeType — тип записи в глобальном ID
+type NodeType string
+
+const (
+	NodePost    NodeType = "Post"
+	NodeComment NodeType = "Comment"
+)
+
+// EncodeGlobalID кодирует ID записи типа t в непрозрачный глобальный ID,
+// уникальный среди записей всех типов
+func EncodeGlobalID(t NodeType, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(string(t) + ":" + id))
+}
+
+// decodeGlobalID разбирает глобальный ID, полученный от EncodeGlobalID
+// ok == false, если id не является глобальным ID
+func decodeGlobalID(id string) (t NodeType, localID string, ok bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return "", "", false
+	}
+	kind, localID, found := strings.Cut(string(raw), ":")
+	t = NodeType(kind)
+	if !found || localID == "" || (t != NodePost && t != NodeComment) {
+		return "", "", false
+	}
+	return t, localID, true
+}
+
+// DecodeID возвращает ID записи типа t по глобальному ID
+// ID записи без типа принимается как есть, чтобы не ломать старых клиентов
+func DecodeID(t NodeType, id string) (string, error) {
+	kind, localID, ok := decodeGlobalID(id)
+	if !ok {
+		return id, nil
+	}
+	if kind != t {
+		return "", invalidInput(fmt.Sprintf("id %s is not a %s id", id, t))
+	}
+	return localID, nil
+}
+
+// Node — запись, найденная по глобальному ID: пост или комментарий
+type Node struct {
+	Post    *store.Post
+	Comment *store.Comment
+}
+
+// NodeService находит записи любого типа по глобальным ID
+type NodeService struct {
+	store store.Store
+	opts  options
+}
+
+// NewNodeService создаёт сервис поиска записей по глобальным ID
+func NewNodeService(store store.Store, opts ...Option) *NodeService {
+	return &NodeService{store: store, opts: newOptions(opts)}
+}
+
+// Nodes возвращает записи с глобальными ID ids в том же порядке
+// Для ненайденных записей в результате nil
+func (s *NodeService) Nodes(ctx context.Context, ids []string) ([]*Node, error) {
+	if len(ids) > s.opts.maxPageSize {
+		return nil, invalidInput(fmt.Sprintf("ids must not contain more than %d items", s.opts.maxPageSize))
+	}
+
+	var postIDs, commentIDs []string
+	for _, id := range ids {
+		switch t, localID, ok := decodeGlobalID(id); {
+		case !ok:
+			return nil, invalidInput(fmt.Sprintf("invalid node id %s", id))
+		case t == NodePost:
+			postIDs = append(postIDs, localID)
+		default:
+			commentIDs = append(commentIDs, localID)
+		}
+	}
+
+	// Записи каждого типа загружаются одним запросом
+	posts, err := s.store.GetPostsByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, storeError("failed to get posts", err)
+	}
+	comments, err := s.store.GetCommentsByIDs(ctx, commentIDs)
+	if err != nil {
+		return nil, storeError("failed to get comments", err)
+	}
+
+	nodes := make([]*Node, len(ids))
+	for i, id := range ids {
+		t, localID, _ := decodeGlobalID(id)
+		if post, ok := posts[localID]; ok && t == NodePost {
+			nodes[i] = &Node{Post: post}
+		}
+		if comment, ok := comments[localID]; ok && t == NodeComment {
+			nodes[i] = &Node{Comment: comment}
+		}
+	}
+	return nodes, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import "time"
+
+const (
+	defaultMaxCommentDepth = 10
+	defaultMaxPageSize     = 100
+	defaultTrashRetention  = 30 * 24 * time.Hour
+	defaultEditWindow      = 15 * time.Minute
+)
+
+// options — настройки сервисов
+type options struct {
+	maxDepth       int             // Максимальная глубина ответа; 0 у комментария верхнего уровня
+	maxPageSize    int             // Максимальный размер страницы
+	trashRetention time.Duration   // Сколько удалённый пост можно восстановить
+	editWindow     time.Duration   // Сколько после создания комментарий можно изменить
+	moderators     map[string]bool // Кто может удалять комментарии по решению модератора
+}
+
+// Option настраивает сервис
+type Option func(*options)
+
+// WithMaxDepth ограничивает глубину вложенности ответов
+// depth <= 0 снимает ограничение
+func WithMaxDepth(depth int) Option {
+	return func(o *options) {
+		o.maxDepth = depth
+	}
+}
+
+// WithMaxPageSize ограничивает размер страницы, который может запросить клиент
+func WithMaxPageSize(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxPageSize = size
+		}
+	}
+}
+
+// WithTrashRetention задаёт, сколько удалённый пост хранится в корзине
+// retention <= 0 — посты из корзины окончательно не удаляются
+func WithTrashRetention(retention time.Duration) Option {
+	return func(o *options) {
+		o.trashRetention = retention
+	}
+}
+
+// WithEditWindow задаёт, сколько после создания автор может изменить комментарий
+// window <= 0 снимает ограничение
+func WithEditWindow(window time.Duration) Option {
+	return func(o *options) {
+		o.editWindow = window
+	}
+}
+
+// WithModerators задаёт, кто может удалять чужие комментарии
+// как спам, оскорбления или не относящиеся к теме
+func WithModerators(names ...string) Option {
+	return func(o *options) {
+		o.moderators = make(map[string]bool, len(names))
+		for _, name := range names {
+			o.moderators[name] = true
+		}
+	}
+}
+
+// newOptions применяет opts к настройкам по умолчанию
+func newOptions(opts []Option) options {
+	o := options{
+		maxDepth:       defaultMaxCommentDepth,
+		maxPageSize:    defaultMaxPageSize,
+		trashRetention: defaultTrashRetention,
+		editWindow:     defaultEditWindow,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
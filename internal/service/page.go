@@ -0,0 +1,98 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// PageArgs — аргументы пагинации Relay: first/after или last/before
+type PageArgs struct {
+	First  *int
+	After  *string
+	Last   *int
+	Before *string
+}
+
+// EncodeCursor кодирует позицию записи в непрозрачный курсор
+func EncodeCursor(c store.Cursor) string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixNano(), 10) + ":" + strconv.Itoa(c.Count) + ":" +
+		strconv.FormatFloat(c.Score, 'g', -1, 64) + ":" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor разбирает курсор, полученный от EncodeCursor
+func decodeCursor(cursor string) (*store.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidInput("invalid cursor")
+	}
+	// ID идёт последним и может содержать двоеточия
+	parts := strings.SplitN(string(raw), ":", 4)
+	if len(parts) != 4 || parts[3] == "" {
+		return nil, invalidInput("invalid cursor")
+	}
+	createdAt, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, invalidInput("invalid cursor")
+	}
+	count, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, invalidInput("invalid cursor")
+	}
+	score, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil || math.IsNaN(score) || math.IsInf(score, 0) {
+		return nil, invalidInput("invalid cursor")
+	}
+	return &store.Cursor{CreatedAt: time.Unix(0, createdAt), ID: parts[3], Count: count, Score: score}, nil
+}
+
+// pageQuery проверяет аргументы пагинации и переводит их в запрос к хранилищу
+// Без first и last выдаётся первая страница размера по умолчанию
+func (o options) pageQuery(args PageArgs) (store.PageQuery, error) {
+	var q store.PageQuery
+	if args.First != nil && args.Last != nil {
+		return q, invalidInput("first and last cannot be used together")
+	}
+
+	size := func(name string, value int) (int, error) {
+		if value < 0 {
+			return 0, invalidInput(name + " must not be negative")
+		}
+		if value > o.maxPageSize {
+			return 0, invalidInput(fmt.Sprintf("%s must not exceed %d", name, o.maxPageSize))
+		}
+		return value, nil
+	}
+
+	var err error
+	switch {
+	case args.Last != nil:
+		if q.Last, err = size("last", *args.Last); err != nil {
+			return q, err
+		}
+	case args.First != nil:
+		if q.First, err = size("first", *args.First); err != nil {
+			return q, err
+		}
+	default:
+		q.First = defaultPageSize
+	}
+
+	if args.After != nil {
+		if q.After, err = decodeCursor(*args.After); err != nil {
+			return q, err
+		}
+	}
+	if args.Before != nil {
+		if q.Before, err = decodeCursor(*args.Before); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
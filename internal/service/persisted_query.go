@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// manifestFormat — формат манифеста операций Apollo
+const manifestFormat = "apollo-persisted-query-manifest"
+
+// Manifest — манифест разрешённых операций в формате Apollo persisted query manifest
+type Manifest struct {
+	Format     string              `json:"format"`
+	Version    int                 `json:"version"`
+	Operations []ManifestOperation `json:"operations"`
+}
+
+// ManifestOperation — операция манифеста, ID — SHA-256 её текста в hex
+type ManifestOperation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Body string `json:"body"`
+}
+
+// QueryHash возвращает SHA-256 текста операции в hex, как в APQ и манифесте
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+// PersistedQueryService хранит список разрешённых операций GraphQL
+type PersistedQueryService struct {
+	store store.Store
+}
+
+// NewPersistedQueryService создаёт сервис списка разрешённых операций
+func NewPersistedQueryService(store store.Store) *PersistedQueryService {
+	return &PersistedQueryService{store: store}
+}
+
+// RegisterManifest добавляет операции манифеста data в список разрешённых
+// Возвращает число операций в манифесте
+func (s *PersistedQueryService) RegisterManifest(ctx context.Context, data []byte) (int, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return 0, fmt.Errorf("could not decode manifest: %w", err)
+	}
+	if manifest.Format != manifestFormat || manifest.Version != 1 {
+		return 0, fmt.Errorf("unsupported manifest format %q version %d", manifest.Format, manifest.Version)
+	}
+
+	queries := make([]*store.PersistedQuery, 0, len(manifest.Operations))
+	for _, op := range manifest.Operations {
+		if op.Body == "" {
+			return 0, fmt.Errorf("operation %s has no body", op.ID)
+		}
+		// Клиенты отправляют хеш текста, поэтому ID должен ему соответствовать
+		if op.ID != QueryHash(op.Body) {
+			return 0, fmt.Errorf("operation %s: id does not match body hash", op.ID)
+		}
+		queries = append(queries, &store.PersistedQuery{Hash: op.ID, Name: op.Name, Query: op.Body})
+	}
+
+	if err := s.store.SavePersistedQueries(ctx, queries); err != nil {
+		return 0, storeError("failed to save persisted queries", err)
+	}
+	return len(queries), nil
+}
+
+// Query возвращает текст разрешённой операции с хешем hash
+// Если операции нет в списке, возвращается ошибка с кодом CodeNotFound
+func (s *PersistedQueryService) Query(ctx context.Context, hash string) (string, error) {
+	query, err := s.store.GetPersistedQuery(ctx, hash)
+	if errors.Is(err, store.ErrPersistedQueryNotFound) {
+		return "", &Error{Code: CodeNotFound, Message: "operation is not in the allowlist"}
+	}
+	if err != nil {
+		return "", storeError("failed to get persisted query", err)
+	}
+	return query.Query, nil
+}
@@ -1,7 +1,10 @@
 package service
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"log"
+	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/store"
 	"github.com/google/uuid"
@@ -9,29 +12,29 @@ import (
 
 const (
 	defaultPageSize      = 10
-	defaultPage          = 1
 	defaultAllowComments = true
 )
 
 type PostService struct {
 	store store.Store
+	opts  options
 }
 
-func NewPostService(store store.Store) *PostService {
-	return &PostService{store: store}
+func NewPostService(store store.Store, opts ...Option) *PostService {
+	return &PostService{store: store, opts: newOptions(opts)}
 }
 
 // CreatePost создаёт новый пост
-func (s *PostService) CreatePost(title, content, author string, allowComments bool) (*store.Post, error) {
+func (s *PostService) CreatePost(ctx context.Context, title, content, author string, allowComments bool) (*store.Post, error) {
 	// Проверка обязательных полей
 	if title == "" {
-		return nil, errors.New("title is required")
+		return nil, invalidInput("title is required")
 	}
 	if content == "" {
-		return nil, errors.New("content is required")
+		return nil, invalidInput("content is required")
 	}
 	if author == "" {
-		return nil, errors.New("author is required")
+		return nil, invalidInput("author is required")
 	}
 	if allowComments {
 		allowComments = defaultAllowComments
@@ -40,28 +43,118 @@ func (s *PostService) CreatePost(title, content, author string, allowComments bo
 	// Генерация уникального идентификатора
 	id := uuid.NewString()
 
-	return s.store.CreatePost(id, title, content, author, allowComments)
+	post, err := s.store.CreatePost(ctx, id, title, content, author, allowComments)
+	if err != nil {
+		return nil, storeError("failed to create post", err)
+	}
+
+	// Пост уже создан, поэтому ошибка поиска похожих постов его не отменяет:
+	// у поста просто не будет рекомендаций
+	if err := s.linkRelated(ctx, post); err != nil {
+		log.Printf("could not link related posts for %s: %v", post.ID, err)
+	}
+	return post, nil
 }
 
-// GetPosts возвращает список постов с пагинацией
-func (s *PostService) GetPosts(page, pageSize int) ([]*store.Post, error) {
-	// Проверка входных параметров и установка значений по умолчанию
-	if page <= 0 {
-		page = defaultPage
+// PostFilter — условия выборки постов
+// Время задаётся в формате RFC3339; незаданные условия выборку не ограничивают
+type PostFilter struct {
+	Author        *string
+	CreatedAfter  *string // Посты, созданные не раньше этого времени
+	CreatedBefore *string // Посты, созданные раньше этого времени
+	AllowComments *bool
+	Deleted       bool // Посты из корзины вместо неудалённых
+}
+
+// storeFilter проверяет фильтр и переводит его в фильтр хранилища
+func (f PostFilter) storeFilter() (store.PostFilter, error) {
+	filter := store.PostFilter{Author: f.Author, AllowComments: f.AllowComments, Deleted: f.Deleted}
+
+	parse := func(name string, value *string) (*time.Time, error) {
+		if value == nil {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, *value)
+		if err != nil {
+			return nil, invalidInput(name + " must be an RFC3339 time")
+		}
+		return &t, nil
 	}
-	if pageSize <= 0 {
-		pageSize = defaultPageSize
+
+	var err error
+	if filter.CreatedAfter, err = parse("createdAfter", f.CreatedAfter); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = parse("createdBefore", f.CreatedBefore); err != nil {
+		return filter, err
 	}
+	return filter, nil
+}
 
-	return s.store.GetPosts(page, pageSize)
+// ListPosts возвращает страницу постов по фильтру в порядке order
+func (s *PostService) ListPosts(ctx context.Context, filter PostFilter, order store.PostOrder, args PageArgs) (*store.Page[*store.Post], error) {
+	f, err := filter.storeFilter()
+	if err != nil {
+		return nil, err
+	}
+	q, err := s.opts.pageQuery(args)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.store.FindPosts(ctx, f, order, q)
+	if err != nil {
+		return nil, storeError("failed to get posts", err)
+	}
+	return page, nil
+}
+
+// CountPosts возвращает число постов по фильтру
+func (s *PostService) CountPosts(ctx context.Context, filter PostFilter) (int, error) {
+	f, err := filter.storeFilter()
+	if err != nil {
+		return 0, err
+	}
+	count, err := s.store.CountPosts(ctx, f)
+	if err != nil {
+		return 0, storeError("failed to count posts", err)
+	}
+	return count, nil
 }
 
 // GetPostByID возвращает пост по идентификатору
-func (s *PostService) GetPostByID(id string) (*store.Post, error) {
-	return s.store.GetPostByID(id)
+func (s *PostService) GetPostByID(ctx context.Context, id string) (*store.Post, error) {
+	post, err := s.store.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to get post", err)
+	}
+	return post, nil
+}
+
+// RelatedPosts возвращает до first постов, похожих на пост postID
+func (s *PostService) RelatedPosts(ctx context.Context, postID string, first *int) ([]*store.RelatedPost, error) {
+	limit := defaultRelatedSize
+	if first != nil {
+		if *first < 0 {
+			return nil, invalidInput("first must not be negative")
+		}
+		if *first > maxRelated {
+			return nil, invalidInput(fmt.Sprintf("first must not exceed %d", maxRelated))
+		}
+		limit = *first
+	}
+
+	related, err := s.store.ListRelatedPosts(ctx, postID, limit)
+	if err != nil {
+		return nil, storeError("failed to get related posts", err)
+	}
+	return related, nil
 }
 
 // UpdatePostCommentsPermission обновляет разрешение на комментарии к посту
-func (s *PostService) UpdatePostCommentsPermission(postID string, allowComments bool) (*store.Post, error) {
-	return s.store.UpdatePostCommentsPermission(postID, allowComments)
+func (s *PostService) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*store.Post, error) {
+	post, err := s.store.UpdatePostCommentsPermission(ctx, postID, allowComments)
+	if err != nil {
+		return nil, storeError("failed to update post", err)
+	}
+	return post, nil
 }
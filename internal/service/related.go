@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+const (
+	relatedCandidates  = 500 // Сколько последних постов сравнивается с новым
+	maxRelated         = 10  // Сколько похожих постов запоминается для нового поста
+	minRelatedScore    = 0.1 // Посты с меньшим сходством не считаются похожими
+	defaultRelatedSize = 5
+)
+
+// trigrams — множество триграмм слов текста, как в pg_trgm:
+// каждое слово дополняется двумя пробелами в начале и одним в конце
+type trigrams map[string]struct{}
+
+func newTrigrams(text string) trigrams {
+	set := make(trigrams)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
+
+// similarity — доля общих триграмм (коэффициент Жаккара), от 0 до 1
+func (a trigrams) similarity(b trigrams) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range a {
+		if _, ok := b[t]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// postProfile — триграммы заголовка и текста поста
+type postProfile struct {
+	title, content trigrams
+}
+
+func newPostProfile(post *store.Post) postProfile {
+	return postProfile{title: newTrigrams(post.Title), content: newTrigrams(post.Content)}
+}
+
+// similarity — сходство постов, заголовок весит вдвое больше текста
+func (p postProfile) similarity(other postProfile) float64 {
+	return (2*p.title.similarity(other.title) + p.content.similarity(other.content)) / 3
+}
+
+// linkRelated находит посты, похожие на только что созданный, и сохраняет сходство
+// Сходство двух постов не меняется со временем, поэтому вычисляется один раз
+func (s *PostService) linkRelated(ctx context.Context, post *store.Post) error {
+	page, err := s.store.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: relatedCandidates + 1})
+	if err != nil {
+		return fmt.Errorf("could not get candidates: %w", err)
+	}
+
+	profile := newPostProfile(post)
+	related := make([]store.RelatedScore, 0)
+	for _, other := range page.Items {
+		if other.ID == post.ID {
+			continue
+		}
+		if score := profile.similarity(newPostProfile(other)); score >= minRelatedScore {
+			related = append(related, store.RelatedScore{PostID: other.ID, Score: score})
+		}
+	}
+	if len(related) == 0 {
+		return nil
+	}
+
+	slices.SortStableFunc(related, func(a, b store.RelatedScore) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		default:
+			return 0
+		}
+	})
+	if len(related) > maxRelated {
+		related = related[:maxRelated]
+	}
+	return s.store.AddRelatedPosts(ctx, post.ID, related)
+}
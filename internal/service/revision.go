@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// PostUpdate — изменение поста; незаданные поля не меняются
+type PostUpdate struct {
+	Title   *string
+	Content *string
+	Editor  string // Кто изменяет пост
+}
+
+// UpdatePost изменяет заголовок и текст поста, сохраняя прежние ревизией
+// Изменение, которое ничего не меняет, ревизию не создаёт
+func (s *PostService) UpdatePost(ctx context.Context, id string, update PostUpdate) (*store.Post, error) {
+	if update.Editor == "" {
+		return nil, invalidInput("editor is required")
+	}
+	if update.Title == nil && update.Content == nil {
+		return nil, invalidInput("title or content is required")
+	}
+	if update.Title != nil && *update.Title == "" {
+		return nil, invalidInput("title must not be empty")
+	}
+	if update.Content != nil && *update.Content == "" {
+		return nil, invalidInput("content must not be empty")
+	}
+
+	post, err := s.store.GetPostByID(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to get post", err)
+	}
+	changed := store.PostUpdate{Title: post.Title, Content: post.Content, Editor: update.Editor}
+	if update.Title != nil {
+		changed.Title = *update.Title
+	}
+	if update.Content != nil {
+		changed.Content = *update.Content
+	}
+	if changed.Title == post.Title && changed.Content == post.Content {
+		return post, nil
+	}
+
+	post, err = s.store.UpdatePost(ctx, id, changed)
+	if err != nil {
+		return nil, storeError("failed to update post", err)
+	}
+	return post, nil
+}
+
+// PostRevisions возвращает прежние версии поста от старых к новым
+func (s *PostService) PostRevisions(ctx context.Context, postID string) ([]*store.PostRevision, error) {
+	revisions, err := s.store.ListPostRevisions(ctx, postID)
+	if err != nil {
+		return nil, storeError("failed to get post revisions", err)
+	}
+	return revisions, nil
+}
+
+// DiffOp — вид строки в разнице между версиями
+type DiffOp int
+
+const (
+	DiffEqual  DiffOp = iota // Строка есть в обеих версиях
+	DiffInsert               // Строка добавлена
+	DiffDelete               // Строка удалена
+)
+
+// DiffLine — строка разницы между версиями
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// RevisionDiff — построчная разница между версиями поста From и To
+type RevisionDiff struct {
+	From    int
+	To      int
+	Title   []DiffLine
+	Content []DiffLine
+}
+
+// RevisionDiff сравнивает версии поста from и to
+// Версии нумеруются как store.PostRevision.Number, без to сравнение
+// идёт с текущей версией
+func (s *PostService) RevisionDiff(ctx context.Context, postID string, from int, to *int) (*RevisionDiff, error) {
+	post, err := s.store.GetPostByID(ctx, postID)
+	if err != nil {
+		return nil, storeError("failed to get post", err)
+	}
+	revisions, err := s.PostRevisions(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	current := len(revisions) + 1
+	if to == nil {
+		to = &current
+	}
+	// version возвращает заголовок и текст версии n
+	version := func(name string, n int) (*store.PostRevision, error) {
+		if n < 1 || n > current {
+			return nil, invalidInput(fmt.Sprintf("%s must be between 1 and %d", name, current))
+		}
+		if n == current {
+			return &store.PostRevision{Title: post.Title, Content: post.Content}, nil
+		}
+		return revisions[n-1], nil
+	}
+	a, err := version("from", from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := version("to", *to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RevisionDiff{
+		From:    from,
+		To:      *to,
+		Title:   diffLines(a.Title, b.Title),
+		Content: diffLines(a.Content, b.Content),
+	}, nil
+}
+
+// maxDiffCells ограничивает таблицу наибольшей общей подпоследовательности
+// (около 2 МиБ): изменённые части больших текстов сравниваются целиком
+const maxDiffCells = 1 << 18
+
+// diffLines возвращает построчную разницу между текстами a и b
+// по наибольшей общей подпоследовательности строк. Если изменённые части
+// слишком велики для таблицы, они выдаются как удалённые и добавленные целиком
+func diffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// Общие начало и конец в таблицу не попадают
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	mx, my := x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]
+
+	diff := make([]DiffLine, 0, len(x)+len(y)-prefix-suffix)
+	for _, line := range x[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: line})
+	}
+	if len(my) > 0 && len(mx) > maxDiffCells/len(my) {
+		for _, line := range mx {
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: line})
+		}
+		for _, line := range my {
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: line})
+		}
+	} else {
+		diff = appendLCSDiff(diff, mx, my)
+	}
+	for _, line := range x[len(x)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: line})
+	}
+	return diff
+}
+
+// appendLCSDiff добавляет к diff разницу между строками mx и my
+// по наибольшей общей подпоследовательности
+func appendLCSDiff(diff []DiffLine, mx, my []string) []DiffLine {
+	// lcs[i][j] — длина общей подпоследовательности mx[i:] и my[j:]
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: mx[i]})
+			i++
+			j++
+		case j == len(my) || (i < len(mx) && lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, DiffLine{Op: DiffDelete, Text: mx[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffInsert, Text: my[j]})
+			j++
+		}
+	}
+	return diff
+}
+
+// splitLines делит текст на строки; у пустого текста строк нет
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
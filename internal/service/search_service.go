@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// maxSearchQuery — максимальная длина поискового запроса в символах
+const maxSearchQuery = 200
+
+// SearchService отвечает за полнотекстовый поиск по постам и комментариям
+type SearchService struct {
+	store store.Store
+	opts  options
+}
+
+// NewSearchService создаёт сервис поиска
+func NewSearchService(store store.Store, opts ...Option) *SearchService {
+	return &SearchService{store: store, opts: newOptions(opts)}
+}
+
+// Search возвращает страницу найденных записей вида kinds
+// Пустой kinds означает поиск по постам и комментариям
+func (s *SearchService) Search(ctx context.Context, query string, kinds []store.SearchKind, args PageArgs) (*store.Page[*store.SearchHit], error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, invalidInput("query is required")
+	}
+	if utf8.RuneCountInString(query) > maxSearchQuery {
+		return nil, invalidInput("query is too long")
+	}
+	q, err := s.opts.pageQuery(args)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := s.store.Search(ctx, store.SearchQuery{Text: query, Kinds: kinds}, q)
+	if err != nil {
+		return nil, storeError("failed to search", err)
+	}
+	return page, nil
+}
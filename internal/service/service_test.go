@@ -1,64 +1,231 @@
 package service_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/service"
 	"github.com/SobolevTim/t-graphql/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockStore struct {
 	mock.Mock
 }
 
-func (m *MockStore) CreatePost(id, title, content, author string, allowComments bool) (*store.Post, error) {
-	args := m.Called(id, title, content, author, allowComments)
+func (m *MockStore) CreatePost(ctx context.Context, id, title, content, author string, allowComments bool) (*store.Post, error) {
+	args := m.Called(ctx, id, title, content, author, allowComments)
 	return args.Get(0).(*store.Post), args.Error(1)
 }
 
-func (m *MockStore) GetPosts(page, pageSize int) ([]*store.Post, error) {
-	args := m.Called(page, pageSize)
-	return args.Get(0).([]*store.Post), args.Error(1)
+func (m *MockStore) FindPosts(ctx context.Context, filter store.PostFilter, order store.PostOrder, page store.PageQuery) (*store.Page[*store.Post], error) {
+	args := m.Called(ctx, filter, order, page)
+	return args.Get(0).(*store.Page[*store.Post]), args.Error(1)
 }
 
-func (m *MockStore) UpdatePostCommentsPermission(postID string, allowComments bool) (*store.Post, error) {
-	args := m.Called(postID, allowComments)
+func (m *MockStore) CountPosts(ctx context.Context, filter store.PostFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*store.Post, error) {
+	args := m.Called(ctx, postID, allowComments)
+	return args.Get(0).(*store.Post), args.Error(1)
+}
+
+func (m *MockStore) UpdatePost(ctx context.Context, postID string, update store.PostUpdate) (*store.Post, error) {
+	args := m.Called(ctx, postID, update)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Post), args.Error(1)
+}
+
+func (m *MockStore) ListPostRevisions(ctx context.Context, postID string) ([]*store.PostRevision, error) {
+	args := m.Called(ctx, postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*store.PostRevision), args.Error(1)
+}
+
+func (m *MockStore) DeletePost(ctx context.Context, postID string) (*store.Post, error) {
+	args := m.Called(ctx, postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Post), args.Error(1)
+}
+
+func (m *MockStore) RestorePost(ctx context.Context, postID string) (*store.Post, error) {
+	args := m.Called(ctx, postID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).(*store.Post), args.Error(1)
 }
 
-func (m *MockStore) GetPostByID(postID string) (*store.Post, error) {
-	args := m.Called(postID)
+func (m *MockStore) PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int, error) {
+	args := m.Called(ctx, deletedBefore)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) GetPostByID(ctx context.Context, postID string) (*store.Post, error) {
+	args := m.Called(ctx, postID)
 	return args.Get(0).(*store.Post), args.Error(1)
 }
 
-func (m *MockStore) CreateComment(id, postID string, parentID *string, content, author string) (*store.Comment, error) {
-	args := m.Called(id, postID, parentID, content, author)
+func (m *MockStore) CreateComment(ctx context.Context, id, postID string, parentID *string, content, author string) (*store.Comment, error) {
+	args := m.Called(ctx, id, postID, parentID, content, author)
 	return args.Get(0).(*store.Comment), args.Error(1)
 }
 
-func (m *MockStore) GetCommentsByPostID(postID string, page, pageSize int) ([]*store.Comment, error) {
-	args := m.Called(postID, page, pageSize)
-	return args.Get(0).([]*store.Comment), args.Error(1)
+func (m *MockStore) GetCommentByID(ctx context.Context, id string) (*store.Comment, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*store.Comment), args.Error(1)
+}
+
+func (m *MockStore) ListComments(ctx context.Context, postID string, parentID *string, page store.PageQuery) (*store.Page[*store.Comment], error) {
+	args := m.Called(ctx, postID, parentID, page)
+	return args.Get(0).(*store.Page[*store.Comment]), args.Error(1)
+}
+
+func (m *MockStore) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	args := m.Called(ctx, postID, parentID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockStore) ListCommentsByPostIDs(ctx context.Context, postIDs []string, page store.PageQuery) (map[string]*store.Page[*store.Comment], error) {
+	args := m.Called(ctx, postIDs, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*store.Page[*store.Comment]), args.Error(1)
+}
+
+func (m *MockStore) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page store.PageQuery) (map[string]*store.Page[*store.Comment], error) {
+	args := m.Called(ctx, parentIDs, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*store.Page[*store.Comment]), args.Error(1)
+}
+
+func (m *MockStore) CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, postIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockStore) CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	args := m.Called(ctx, parentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockStore) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*store.Post, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*store.Post), args.Error(1)
+}
+
+func (m *MockStore) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*store.Comment, error) {
+	args := m.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string]*store.Comment), args.Error(1)
 }
 
-func (m *MockStore) GetCommentsByPostIDAndParentID(postID string, parentID *string, page, pageSize int) ([]*store.Comment, error) {
-	args := m.Called(postID, parentID, page, pageSize)
+func (m *MockStore) UpdateComment(ctx context.Context, id, content string) (*store.Comment, error) {
+	args := m.Called(ctx, id, content)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Comment), args.Error(1)
+}
+
+func (m *MockStore) DeleteComment(ctx context.Context, id, reason string) (*store.Comment, error) {
+	args := m.Called(ctx, id, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Comment), args.Error(1)
+}
+
+func (m *MockStore) ListCommentEdits(ctx context.Context, commentIDs []string) (map[string][]*store.CommentEdit, error) {
+	args := m.Called(ctx, commentIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[string][]*store.CommentEdit), args.Error(1)
+}
+
+func (m *MockStore) SavePersistedQueries(ctx context.Context, queries []*store.PersistedQuery) error {
+	args := m.Called(ctx, queries)
+	return args.Error(0)
+}
+
+func (m *MockStore) GetPersistedQuery(ctx context.Context, hash string) (*store.PersistedQuery, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.PersistedQuery), args.Error(1)
+}
+
+func (m *MockStore) CommentTree(ctx context.Context, postID string, tree store.TreeQuery) ([]*store.Comment, error) {
+	args := m.Called(ctx, postID, tree)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
 	return args.Get(0).([]*store.Comment), args.Error(1)
 }
 
-func (m *MockStore) Subscribe(postID string) (<-chan *store.Comment, func()) {
-	args := m.Called(postID)
-	return args.Get(0).(<-chan *store.Comment), args.Get(1).(func())
+func (m *MockStore) AddRelatedPosts(ctx context.Context, postID string, related []store.RelatedScore) error {
+	args := m.Called(ctx, postID, related)
+	return args.Error(0)
+}
+
+func (m *MockStore) ListRelatedPosts(ctx context.Context, postID string, limit int) ([]*store.RelatedPost, error) {
+	args := m.Called(ctx, postID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*store.RelatedPost), args.Error(1)
 }
 
-func (m *MockStore) Publish(comment *store.Comment) {
-	m.Called(comment)
+func (m *MockStore) Search(ctx context.Context, query store.SearchQuery, page store.PageQuery) (*store.Page[*store.SearchHit], error) {
+	args := m.Called(ctx, query, page)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*store.Page[*store.SearchHit]), args.Error(1)
+}
+
+func (m *MockStore) Subscribe(ctx context.Context, postID string) (<-chan *store.CommentEvent, func()) {
+	args := m.Called(ctx, postID)
+	return args.Get(0).(<-chan *store.CommentEvent), args.Get(1).(func())
+}
+
+func (m *MockStore) Publish(ctx context.Context, event *store.CommentEvent) {
+	m.Called(ctx, event)
 }
 
 func TestAddComment(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
@@ -68,10 +235,11 @@ func TestAddComment(t *testing.T) {
 	parentID := "parent1"
 	commentID := "comment1"
 
-	mockStore.On("GetPostByID", postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
-	mockStore.On("CreateComment", mock.Anything, postID, &parentID, content, author).Return(&store.Comment{ID: commentID}, nil)
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, parentID).Return(&store.Comment{ID: parentID, PostID: postID}, nil)
+	mockStore.On("CreateComment", mock.Anything, mock.Anything, postID, &parentID, content, author).Return(&store.Comment{ID: commentID}, nil)
 
-	comment, err := commentService.AddComment(postID, content, author, &parentID)
+	comment, err := commentService.AddComment(ctx, postID, content, author, &parentID)
 	assert.NoError(t, err)
 	assert.NotNil(t, comment)
 	assert.Equal(t, commentID, comment.ID)
@@ -80,6 +248,7 @@ func TestAddComment(t *testing.T) {
 }
 
 func TestAddComment_PostNotFound(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
@@ -88,17 +257,20 @@ func TestAddComment_PostNotFound(t *testing.T) {
 	author := "author1"
 	parentID := "parent1"
 
-	mockStore.On("GetPostByID", postID).Return(&store.Post{}, errors.New("post not found"))
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{}, store.ErrPostNotFound)
 
-	comment, err := commentService.AddComment(postID, content, author, &parentID)
+	comment, err := commentService.AddComment(ctx, postID, content, author, &parentID)
 	assert.Error(t, err)
 	assert.Nil(t, comment)
 	assert.Equal(t, "failed to get post: post not found", err.Error())
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+	assert.ErrorIs(t, err, store.ErrNotFound)
 
 	mockStore.AssertExpectations(t)
 }
 
 func TestAddComment_CommentTooLong(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
@@ -107,17 +279,19 @@ func TestAddComment_CommentTooLong(t *testing.T) {
 	author := "author1"
 	parentID := "parent1"
 
-	mockStore.On("GetPostByID", postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
 
-	comment, err := commentService.AddComment(postID, string(content), author, &parentID)
+	comment, err := commentService.AddComment(ctx, postID, string(content), author, &parentID)
 	assert.Error(t, err)
 	assert.Nil(t, comment)
 	assert.Equal(t, "comment is too long", err.Error())
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
 
 	mockStore.AssertExpectations(t)
 }
 
 func TestAddComment_CommentsNotAllowed(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
@@ -126,56 +300,294 @@ func TestAddComment_CommentsNotAllowed(t *testing.T) {
 	author := "author1"
 	parentID := "parent1"
 
-	mockStore.On("GetPostByID", postID).Return(&store.Post{ID: postID, AllowComments: false}, nil)
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: false}, nil)
 
-	comment, err := commentService.AddComment(postID, content, author, &parentID)
+	comment, err := commentService.AddComment(ctx, postID, content, author, &parentID)
 	assert.Error(t, err)
 	assert.Nil(t, comment)
 	assert.Equal(t, "comments are not allowed", err.Error())
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestAddComment_ParentNotFound(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	postID := "post1"
+	parentID := "parent1"
+
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, parentID).Return((*store.Comment)(nil), store.ErrCommentNotFound)
+
+	comment, err := commentService.AddComment(ctx, postID, "content", "author", &parentID)
+	assert.Nil(t, comment)
+	assert.EqualError(t, err, "parent comment not found")
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
 
 	mockStore.AssertExpectations(t)
 }
 
-func TestGetCommentsByPostID(t *testing.T) {
+func TestAddComment_ParentFromAnotherPost(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
 	postID := "post1"
-	page := 1
-	pageSize := 10
-	comments := []*store.Comment{{ID: "comment1"}, {ID: "comment2"}}
+	parentID := "parent1"
 
-	mockStore.On("GetCommentsByPostID", postID, page, pageSize).Return(comments, nil)
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, parentID).Return(&store.Comment{ID: parentID, PostID: "post2"}, nil)
+
+	comment, err := commentService.AddComment(ctx, postID, "content", "author", &parentID)
+	assert.Nil(t, comment)
+	assert.EqualError(t, err, "parent comment belongs to another post")
 
-	result, err := commentService.GetCommentsByPostID(postID, page, pageSize)
+	mockStore.AssertExpectations(t)
+}
+
+func TestAddComment_MaxDepth(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore, service.WithMaxDepth(2))
+
+	postID := "post1"
+	shallowID := "shallow"
+	deepID := "deep"
+
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(&store.Post{ID: postID, AllowComments: true}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, shallowID).Return(&store.Comment{ID: shallowID, PostID: postID, Depth: 1}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, deepID).Return(&store.Comment{ID: deepID, PostID: postID, Depth: 2}, nil)
+	mockStore.On("CreateComment", mock.Anything, mock.Anything, postID, &shallowID, "content", "author").Return(&store.Comment{ID: "reply", Depth: 2}, nil)
+
+	// Ответ на глубине 2 ещё допустим
+	comment, err := commentService.AddComment(ctx, postID, "content", "author", &shallowID)
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, comments, result)
+	assert.Equal(t, 2, comment.Depth)
+
+	// Ответ на глубине 3 уже нет
+	comment, err = commentService.AddComment(ctx, postID, "content", "author", &deepID)
+	assert.Nil(t, comment)
+	assert.EqualError(t, err, "maximum reply depth of 2 exceeded")
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestListComments(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	postID := "post1"
+	first := 5
+	page := &store.Page[*store.Comment]{Items: []*store.Comment{{ID: "comment1"}}, HasNextPage: true}
+
+	mockStore.On("ListComments", mock.Anything, postID, (*string)(nil), store.PageQuery{First: first}).Return(page, nil)
+
+	result, err := commentService.ListComments(ctx, postID, service.PageArgs{First: &first})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
 
 	mockStore.AssertExpectations(t)
 }
 
-func TestGetCommentsByPostIDAndParentID(t *testing.T) {
+func TestListReplies_Cursor(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	commentService := service.NewCommentService(mockStore)
 
 	postID := "post1"
 	parentID := "parent1"
-	page := 1
-	pageSize := 10
-	comments := []*store.Comment{{ID: "comment1"}, {ID: "comment2"}}
+	cursor := store.Cursor{CreatedAt: time.Unix(0, 1700000000123456789), ID: "comment1"}
+	after := service.EncodeCursor(cursor)
+	page := &store.Page[*store.Comment]{Items: []*store.Comment{}}
 
-	mockStore.On("GetCommentsByPostIDAndParentID", postID, &parentID, page, pageSize).Return(comments, nil)
+	mockStore.On("ListComments", mock.Anything, postID, &parentID, mock.MatchedBy(func(q store.PageQuery) bool {
+		return q.First == 10 && q.After != nil && q.After.ID == cursor.ID && q.After.CreatedAt.Equal(cursor.CreatedAt)
+	})).Return(page, nil)
 
-	result, err := commentService.GetCommentsByPostIDAndParentID(postID, &parentID, page, pageSize)
+	result, err := commentService.ListReplies(ctx, postID, parentID, service.PageArgs{After: &after})
 	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, comments, result)
+	assert.Equal(t, page, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestListCommentsByPosts(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	postIDs := []string{"post1", "post2"}
+	first := 3
+	pages := map[string]*store.Page[*store.Comment]{
+		"post1": {Items: []*store.Comment{{ID: "comment1"}}},
+		"post2": {Items: []*store.Comment{}},
+	}
+	mockStore.On("ListCommentsByPostIDs", mock.Anything, postIDs, store.PageQuery{First: first}).Return(pages, nil)
+
+	result, err := commentService.ListCommentsByPosts(ctx, postIDs, service.PageArgs{First: &first})
+	assert.NoError(t, err)
+	assert.Equal(t, pages, result)
+
+	// Некорректные аргументы проверяются до обращения к хранилищу
+	last := 1
+	_, err = commentService.ListRepliesByParents(ctx, []string{"comment1"}, service.PageArgs{First: &first, Last: &last})
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestCommentTree(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	tree := []*store.Comment{{ID: "comment1"}, {ID: "reply1"}}
+	mockStore.On("CommentTree", mock.Anything, "post1", store.TreeQuery{MaxDepth: 5, LimitPerLevel: 10}).Return(tree, nil)
+
+	result, err := commentService.CommentTree(ctx, "post1", nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, result)
+
+	rootID, depth, limit := "comment1", 2, 3
+	mockStore.On("CommentTree", mock.Anything, "post1", store.TreeQuery{RootID: &rootID, MaxDepth: depth, LimitPerLevel: limit}).
+		Return(nil, store.ErrCommentNotFound)
+
+	_, err = commentService.CommentTree(ctx, "post1", &rootID, &depth, &limit)
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestCommentTree_InvalidArgs(t *testing.T) {
+	ctx := context.Background()
+	commentService := service.NewCommentService(new(MockStore))
+
+	negative, zero, deep, wide := -1, 0, 21, 101
+	for _, tc := range []struct {
+		maxDepth, limitPerLevel *int
+		message                 string
+	}{
+		{maxDepth: &negative, message: "maxDepth must not be negative"},
+		{maxDepth: &deep, message: "maxDepth must not exceed 20"},
+		{limitPerLevel: &zero, message: "limitPerLevel must be positive"},
+		{limitPerLevel: &wide, message: "limitPerLevel must not exceed 100"},
+	} {
+		_, err := commentService.CommentTree(ctx, "post1", nil, tc.maxDepth, tc.limitPerLevel)
+		assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+		assert.EqualError(t, err, tc.message)
+	}
+}
+
+func TestListPosts(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	last := 2
+	page := &store.Page[*store.Post]{Items: []*store.Post{{ID: "post1"}, {ID: "post2"}}, HasPreviousPage: true}
+
+	mockStore.On("FindPosts", mock.Anything, store.PostFilter{}, store.PostsNewest, store.PageQuery{Last: last}).Return(page, nil)
+
+	result, err := postService.ListPosts(ctx, service.PostFilter{}, store.PostsNewest, service.PageArgs{Last: &last})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestListPosts_Filter(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	author := "alice"
+	open := true
+	after := "2024-01-02T03:04:05Z"
+	createdAfter := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	page := &store.Page[*store.Post]{Items: []*store.Post{{ID: "post1"}}}
+
+	mockStore.On("FindPosts", mock.Anything, mock.MatchedBy(func(f store.PostFilter) bool {
+		return *f.Author == author && *f.AllowComments && f.CreatedAfter.Equal(createdAfter) && f.CreatedBefore == nil
+	}), store.PostsMostCommented, store.PageQuery{First: 10}).Return(page, nil)
+
+	filter := service.PostFilter{Author: &author, AllowComments: &open, CreatedAfter: &after}
+	result, err := postService.ListPosts(ctx, filter, store.PostsMostCommented, service.PageArgs{})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestListPosts_InvalidFilter(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	before := "yesterday"
+	result, err := postService.ListPosts(ctx, service.PostFilter{CreatedBefore: &before}, store.PostsNewest, service.PageArgs{})
+	assert.EqualError(t, err, "createdBefore must be an RFC3339 time")
+	assert.Nil(t, result)
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+
+	count, err := postService.CountPosts(ctx, service.PostFilter{CreatedBefore: &before})
+	assert.Error(t, err)
+	assert.Zero(t, count)
+
+	mockStore.AssertNotCalled(t, "FindPosts", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestListPosts_InvalidArgs(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore, service.WithMaxPageSize(50))
+
+	one, negative, tooMany := 1, -1, 51
+	cursor := "not a cursor"
+
+	tests := []struct {
+		name string
+		args service.PageArgs
+	}{
+		{"FirstAndLast", service.PageArgs{First: &one, Last: &one}},
+		{"NegativeFirst", service.PageArgs{First: &negative}},
+		{"NegativeLast", service.PageArgs{Last: &negative}},
+		{"FirstTooLarge", service.PageArgs{First: &tooMany}},
+		{"InvalidAfter", service.PageArgs{After: &cursor}},
+		{"InvalidBefore", service.PageArgs{Before: &cursor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := postService.ListPosts(ctx, service.PostFilter{}, store.PostsNewest, tt.args)
+			assert.Error(t, err)
+			assert.Nil(t, result)
+			assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+		})
+	}
+
+	mockStore.AssertNotCalled(t, "FindPosts", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCountPosts(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	mockStore.On("CountPosts", mock.Anything, store.PostFilter{}).Return(3, nil)
+
+	count, err := postService.CountPosts(ctx, service.PostFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
 
 	mockStore.AssertExpectations(t)
 }
 
 func TestCreatePost(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	postService := service.NewPostService(mockStore)
 
@@ -185,61 +597,139 @@ func TestCreatePost(t *testing.T) {
 	allowComments := true
 	postID := "post1"
 
-	mockStore.On("CreatePost", mock.Anything, title, content, author, allowComments).Return(&store.Post{ID: postID}, nil)
+	created := &store.Post{ID: postID, Title: title, Content: content}
+	mockStore.On("CreatePost", mock.Anything, mock.Anything, title, content, author, allowComments).Return(created, nil)
+	mockStore.On("FindPosts", mock.Anything, store.PostFilter{}, store.PostsNewest, mock.Anything).
+		Return(&store.Page[*store.Post]{Items: []*store.Post{created}}, nil)
 
-	post, err := postService.CreatePost(title, content, author, allowComments)
+	post, err := postService.CreatePost(ctx, title, content, author, allowComments)
 	assert.NoError(t, err)
 	assert.NotNil(t, post)
 	assert.Equal(t, postID, post.ID)
 
+	// Других постов нет, поэтому сходство не сохраняется
+	mockStore.AssertExpectations(t)
+	mockStore.AssertNotCalled(t, "AddRelatedPosts", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreatePost_LinksRelated(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	created := &store.Post{ID: "new", Title: "GraphQL pagination", Content: "Cursor based pagination in GraphQL"}
+	similar := &store.Post{ID: "similar", Title: "GraphQL pagination tips", Content: "Cursor pagination in GraphQL APIs"}
+	other := &store.Post{ID: "other", Title: "Baking bread", Content: "Flour, water and salt"}
+
+	mockStore.On("CreatePost", mock.Anything, mock.Anything, created.Title, created.Content, "author", true).Return(created, nil)
+	mockStore.On("FindPosts", mock.Anything, store.PostFilter{}, store.PostsNewest, mock.Anything).
+		Return(&store.Page[*store.Post]{Items: []*store.Post{created, similar, other}}, nil)
+	mockStore.On("AddRelatedPosts", mock.Anything, "new", mock.MatchedBy(func(related []store.RelatedScore) bool {
+		return len(related) == 1 && related[0].PostID == "similar" && related[0].Score > 0.5 && related[0].Score < 1
+	})).Return(nil)
+
+	_, err := postService.CreatePost(ctx, created.Title, created.Content, "author", true)
+	assert.NoError(t, err)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestCreatePost_RelatedFailureIgnored(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	mockStore.On("CreatePost", mock.Anything, mock.Anything, "title", "content", "author", true).Return(&store.Post{ID: "post1"}, nil)
+	mockStore.On("FindPosts", mock.Anything, store.PostFilter{}, store.PostsNewest, mock.Anything).
+		Return((*store.Page[*store.Post])(nil), errors.New("connection refused"))
+
+	post, err := postService.CreatePost(ctx, "title", "content", "author", true)
+	assert.NoError(t, err)
+	assert.Equal(t, "post1", post.ID)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestRelatedPosts(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	related := []*store.RelatedPost{{Post: &store.Post{ID: "post2"}, Score: 0.4}}
+	mockStore.On("ListRelatedPosts", mock.Anything, "post1", 5).Return(related, nil)
+
+	result, err := postService.RelatedPosts(ctx, "post1", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, related, result)
+
+	for _, first := range []int{-1, 11} {
+		_, err := postService.RelatedPosts(ctx, "post1", &first)
+		assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	}
+
 	mockStore.AssertExpectations(t)
 }
 
 func TestCreatePost_MissingFields(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	postService := service.NewPostService(mockStore)
 
-	_, err := postService.CreatePost("", "content", "author", true)
+	_, err := postService.CreatePost(ctx, "", "content", "author", true)
 	assert.Error(t, err)
 	assert.Equal(t, "title is required", err.Error())
 
-	_, err = postService.CreatePost("title", "", "author", true)
+	_, err = postService.CreatePost(ctx, "title", "", "author", true)
 	assert.Error(t, err)
 	assert.Equal(t, "content is required", err.Error())
 
-	_, err = postService.CreatePost("title", "content", "", true)
+	_, err = postService.CreatePost(ctx, "title", "content", "", true)
 	assert.Error(t, err)
 	assert.Equal(t, "author is required", err.Error())
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
 }
 
-func TestGetPosts(t *testing.T) {
+func TestCreatePost_AlreadyExists(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	postService := service.NewPostService(mockStore)
 
-	page := 1
-	pageSize := 10
-	posts := []*store.Post{{ID: "post1"}, {ID: "post2"}}
+	mockStore.On("CreatePost", mock.Anything, mock.Anything, "title", "content", "author", true).Return((*store.Post)(nil), store.ErrPostExists)
 
-	mockStore.On("GetPosts", page, pageSize).Return(posts, nil)
+	post, err := postService.CreatePost(ctx, "title", "content", "author", true)
+	assert.Nil(t, post)
+	assert.Equal(t, service.CodeConflict, service.ErrorCode(err))
 
-	result, err := postService.GetPosts(page, pageSize)
-	assert.NoError(t, err)
-	assert.NotNil(t, result)
-	assert.Equal(t, posts, result)
+	mockStore.AssertExpectations(t)
+}
+
+func TestGetPostByID_StoreFailure(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	dbErr := errors.New("could not get post: connection refused")
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return((*store.Post)(nil), dbErr)
+
+	post, err := postService.GetPostByID(ctx, "post1")
+	assert.Nil(t, post)
+	assert.ErrorIs(t, err, dbErr)
+	assert.Equal(t, service.CodeInternal, service.ErrorCode(err))
 
 	mockStore.AssertExpectations(t)
 }
 
 func TestGetPostByID(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	postService := service.NewPostService(mockStore)
 
 	postID := "post1"
 	post := &store.Post{ID: postID}
 
-	mockStore.On("GetPostByID", postID).Return(post, nil)
+	mockStore.On("GetPostByID", mock.Anything, postID).Return(post, nil)
 
-	result, err := postService.GetPostByID(postID)
+	result, err := postService.GetPostByID(ctx, postID)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, post, result)
@@ -248,6 +738,7 @@ func TestGetPostByID(t *testing.T) {
 }
 
 func TestUpdatePostCommentsPermission(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	postService := service.NewPostService(mockStore)
 
@@ -255,9 +746,9 @@ func TestUpdatePostCommentsPermission(t *testing.T) {
 	allowComments := true
 	post := &store.Post{ID: postID, AllowComments: allowComments}
 
-	mockStore.On("UpdatePostCommentsPermission", postID, allowComments).Return(post, nil)
+	mockStore.On("UpdatePostCommentsPermission", mock.Anything, postID, allowComments).Return(post, nil)
 
-	result, err := postService.UpdatePostCommentsPermission(postID, allowComments)
+	result, err := postService.UpdatePostCommentsPermission(ctx, postID, allowComments)
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, post, result)
@@ -265,32 +756,543 @@ func TestUpdatePostCommentsPermission(t *testing.T) {
 	mockStore.AssertExpectations(t)
 }
 
+func TestUpdatePost(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	post := &store.Post{ID: "post1", Title: "Title", Content: "Content"}
+	updated := &store.Post{ID: "post1", Title: "Title", Content: "New content"}
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return(post, nil)
+	// Незаданный заголовок остаётся прежним
+	mockStore.On("UpdatePost", mock.Anything, "post1", store.PostUpdate{Title: "Title", Content: "New content", Editor: "editor"}).Return(updated, nil)
+
+	content := "New content"
+	result, err := postService.UpdatePost(ctx, "post1", service.PostUpdate{Content: &content, Editor: "editor"})
+	assert.NoError(t, err)
+	assert.Equal(t, updated, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestUpdatePost_Unchanged(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	post := &store.Post{ID: "post1", Title: "Title", Content: "Content"}
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return(post, nil)
+
+	title := "Title"
+	result, err := postService.UpdatePost(ctx, "post1", service.PostUpdate{Title: &title, Editor: "editor"})
+	assert.NoError(t, err)
+	assert.Equal(t, post, result)
+
+	mockStore.AssertNotCalled(t, "UpdatePost", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdatePost_InvalidInput(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	title, empty := "Title", ""
+	tests := []struct {
+		update  service.PostUpdate
+		message string
+	}{
+		{service.PostUpdate{Title: &title}, "editor is required"},
+		{service.PostUpdate{Editor: "editor"}, "title or content is required"},
+		{service.PostUpdate{Title: &empty, Editor: "editor"}, "title must not be empty"},
+		{service.PostUpdate{Content: &empty, Editor: "editor"}, "content must not be empty"},
+	}
+	for _, tt := range tests {
+		_, err := postService.UpdatePost(ctx, "post1", tt.update)
+		assert.Equal(t, tt.message, err.Error())
+		assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	}
+
+	mockStore.AssertNotCalled(t, "GetPostByID", mock.Anything, mock.Anything)
+}
+
+func TestUpdatePost_NotFound(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	mockStore.On("GetPostByID", mock.Anything, "missing").Return((*store.Post)(nil), store.ErrPostNotFound)
+
+	title := "Title"
+	_, err := postService.UpdatePost(ctx, "missing", service.PostUpdate{Title: &title, Editor: "editor"})
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+}
+
+func TestRevisionDiff(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	post := &store.Post{ID: "post1", Title: "Final", Content: "one\nthree\nfour"}
+	revisions := []*store.PostRevision{
+		{PostID: "post1", Number: 1, Title: "Draft", Content: "one\ntwo\nthree"},
+		{PostID: "post1", Number: 2, Title: "Final", Content: "one\ntwo\nthree"},
+	}
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return(post, nil)
+	mockStore.On("ListPostRevisions", mock.Anything, "post1").Return(revisions, nil)
+
+	// Без to сравнение идёт с текущей версией 3
+	diff, err := postService.RevisionDiff(ctx, "post1", 1, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, diff.From)
+	assert.Equal(t, 3, diff.To)
+	assert.Equal(t, []service.DiffLine{
+		{Op: service.DiffDelete, Text: "Draft"},
+		{Op: service.DiffInsert, Text: "Final"},
+	}, diff.Title)
+	assert.Equal(t, []service.DiffLine{
+		{Op: service.DiffEqual, Text: "one"},
+		{Op: service.DiffDelete, Text: "two"},
+		{Op: service.DiffEqual, Text: "three"},
+		{Op: service.DiffInsert, Text: "four"},
+	}, diff.Content)
+
+	to := 2
+	diff, err = postService.RevisionDiff(ctx, "post1", 2, &to)
+	assert.NoError(t, err)
+	assert.Equal(t, []service.DiffLine{{Op: service.DiffEqual, Text: "Final"}}, diff.Title)
+
+	_, err = postService.RevisionDiff(ctx, "post1", 0, nil)
+	assert.Equal(t, "from must be between 1 and 3", err.Error())
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+
+	to = 4
+	_, err = postService.RevisionDiff(ctx, "post1", 1, &to)
+	assert.Equal(t, "to must be between 1 and 3", err.Error())
+}
+
+func TestRevisionDiff_LargeChange(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	// Изменённые части слишком велики для построчного сравнения
+	// и выдаются целиком, даже с общей строкой посередине
+	const lines = 600
+	old, updated := []string{"first"}, []string{"first"}
+	for i := range lines {
+		if i == lines/2 {
+			old, updated = append(old, "shared"), append(updated, "shared")
+		}
+		old = append(old, fmt.Sprintf("old %d", i))
+		updated = append(updated, fmt.Sprintf("new %d", i))
+	}
+	old, updated = append(old, "last"), append(updated, "last")
+
+	post := &store.Post{ID: "post1", Title: "Title", Content: strings.Join(updated, "\n")}
+	revisions := []*store.PostRevision{
+		{PostID: "post1", Number: 1, Title: "Title", Content: strings.Join(old, "\n")},
+	}
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return(post, nil)
+	mockStore.On("ListPostRevisions", mock.Anything, "post1").Return(revisions, nil)
+
+	diff, err := postService.RevisionDiff(ctx, "post1", 1, nil)
+	require.NoError(t, err)
+	require.Len(t, diff.Content, 2*(lines+1)+2)
+	assert.Equal(t, service.DiffLine{Op: service.DiffEqual, Text: "first"}, diff.Content[0])
+	assert.Equal(t, service.DiffLine{Op: service.DiffEqual, Text: "last"}, diff.Content[len(diff.Content)-1])
+	for i, line := range diff.Content[1 : len(diff.Content)-1] {
+		if i <= lines {
+			assert.Equal(t, service.DiffDelete, line.Op)
+		} else {
+			assert.Equal(t, service.DiffInsert, line.Op)
+		}
+	}
+}
+
+func TestEditComment(t *testing.T) {
+	ctx := service.WithUser(context.Background(), "author1")
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	editedAt := time.Now()
+	original := &store.Comment{ID: "comment1", Content: "Old", Author: "author1", CreatedAt: time.Now().Add(-time.Minute)}
+	mockStore.On("GetCommentByID", mock.Anything, "comment1").Return(original, nil)
+	mockStore.On("UpdateComment", mock.Anything, "comment1", "New").
+		Return(&store.Comment{ID: "comment1", Content: "New", Author: "author1", EditedAt: &editedAt}, nil)
+
+	comment, err := commentService.EditComment(ctx, "comment1", "New")
+	assert.NoError(t, err)
+	assert.Equal(t, "New", comment.Content)
+	assert.Equal(t, &editedAt, comment.EditedAt)
+
+	// Тот же текст не создаёт новую правку
+	comment, err = commentService.EditComment(ctx, "comment1", "Old")
+	assert.NoError(t, err)
+	assert.Same(t, original, comment)
+
+	mockStore.AssertNumberOfCalls(t, "UpdateComment", 1)
+}
+
+func TestEditComment_Forbidden(t *testing.T) {
+	ctx := service.WithUser(context.Background(), "author1")
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore, service.WithEditWindow(time.Hour))
+
+	mockStore.On("GetCommentByID", mock.Anything, "recent").
+		Return(&store.Comment{ID: "recent", Author: "author1", CreatedAt: time.Now()}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, "old").
+		Return(&store.Comment{ID: "old", Author: "author1", CreatedAt: time.Now().Add(-2 * time.Hour)}, nil)
+
+	_, err := commentService.EditComment(service.WithUser(ctx, "author2"), "recent", "New")
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+	assert.Equal(t, "only the author can edit the comment", err.Error())
+
+	_, err = commentService.EditComment(ctx, "old", "New")
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+	assert.Equal(t, "edit window has expired", err.Error())
+
+	// Без пользователя запроса автора не с чем сравнить
+	_, err = commentService.EditComment(context.Background(), "recent", "New")
+	assert.Equal(t, service.CodeUnauthenticated, service.ErrorCode(err))
+
+	// Без окна правки старый комментарий можно изменить
+	unlimited := service.NewCommentService(mockStore, service.WithEditWindow(0))
+	mockStore.On("UpdateComment", mock.Anything, "old", "New").Return(&store.Comment{ID: "old", Content: "New"}, nil)
+	_, err = unlimited.EditComment(ctx, "old", "New")
+	assert.NoError(t, err)
+}
+
+func TestEditComment_InvalidInput(t *testing.T) {
+	ctx := service.WithUser(context.Background(), "author1")
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore)
+
+	_, err := commentService.EditComment(ctx, "comment1", string(make([]byte, 2001)))
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	assert.Equal(t, "comment is too long", err.Error())
+
+	mockStore.On("GetCommentByID", mock.Anything, "missing").Return((*store.Comment)(nil), store.ErrCommentNotFound)
+	_, err = commentService.EditComment(ctx, "missing", "New")
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+	mockStore.AssertNotCalled(t, "UpdateComment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteComment(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore, service.WithModerators("moderator"))
+
+	deletedAt := time.Now()
+	tombstone := &store.Comment{
+		ID: "comment1", Content: store.DeletedPlaceholder, Author: store.DeletedPlaceholder,
+		DeletedAt: &deletedAt, DeleteReason: store.DeletedByAuthor,
+	}
+	mockStore.On("GetCommentByID", mock.Anything, "comment1").Return(&store.Comment{ID: "comment1", Author: "author1"}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, "comment2").Return(&store.Comment{ID: "comment2", Author: "author2"}, nil)
+	mockStore.On("DeleteComment", mock.Anything, "comment1", store.DeletedByAuthor).Return(tombstone, nil)
+	mockStore.On("DeleteComment", mock.Anything, "comment2", store.DeletedAsSpam).Return((*store.Comment)(nil), nil)
+
+	comment, err := commentService.DeleteComment(service.WithUser(ctx, "author1"), "comment1", store.DeletedByAuthor)
+	assert.NoError(t, err)
+	assert.Same(t, tombstone, comment)
+
+	// Модератор удаляет чужой комментарий
+	comment, err = commentService.DeleteComment(service.WithUser(ctx, "moderator"), "comment2", store.DeletedAsSpam)
+	assert.NoError(t, err)
+	assert.Nil(t, comment)
+}
+
+func TestDeleteComment_Errors(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	commentService := service.NewCommentService(mockStore, service.WithModerators("moderator"))
+
+	deletedAt := time.Now()
+	mockStore.On("GetCommentByID", mock.Anything, "comment1").Return(&store.Comment{ID: "comment1", Author: "author1"}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, "deleted").
+		Return(&store.Comment{ID: "deleted", PostID: "post1", Author: store.DeletedPlaceholder, DeletedAt: &deletedAt}, nil)
+	mockStore.On("GetCommentByID", mock.Anything, "missing").Return((*store.Comment)(nil), store.ErrCommentNotFound)
+
+	_, err := commentService.DeleteComment(service.WithUser(ctx, "author1"), "comment1", "other")
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	assert.Equal(t, "unknown delete reason", err.Error())
+
+	_, err = commentService.DeleteComment(service.WithUser(ctx, "author2"), "comment1", store.DeletedByAuthor)
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+	assert.Equal(t, "only the author can delete the comment", err.Error())
+
+	// Причины модератора недоступны ни постороннему, ни автору
+	for _, actor := range []string{"author2", "author1"} {
+		_, err = commentService.DeleteComment(service.WithUser(ctx, actor), "comment1", store.DeletedAsSpam)
+		assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+		assert.Equal(t, "only moderators can delete the comment for this reason", err.Error())
+	}
+	_, err = service.NewCommentService(mockStore).DeleteComment(service.WithUser(ctx, "moderator"), "comment1", store.DeletedAsOffTopic)
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+
+	_, err = commentService.DeleteComment(service.WithUser(ctx, "moderator"), "deleted", store.DeletedAsAbuse)
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	assert.Equal(t, "comment is already deleted", err.Error())
+
+	_, err = commentService.DeleteComment(service.WithUser(ctx, "moderator"), "missing", store.DeletedAsAbuse)
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+
+	_, err = commentService.DeleteComment(ctx, "comment1", store.DeletedByAuthor)
+	assert.Equal(t, service.CodeUnauthenticated, service.ErrorCode(err))
+
+	// Удалённый комментарий нельзя изменить и на него нельзя ответить
+	_, err = commentService.EditComment(service.WithUser(ctx, "author1"), "deleted", "New")
+	assert.Equal(t, service.CodeForbidden, service.ErrorCode(err))
+	assert.Equal(t, "comment is deleted", err.Error())
+
+	mockStore.On("GetPostByID", mock.Anything, "post1").Return(&store.Post{ID: "post1", AllowComments: true}, nil)
+	parentID := "deleted"
+	_, err = commentService.AddComment(ctx, "post1", "Reply", "author1", &parentID)
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	assert.Equal(t, "parent comment is deleted", err.Error())
+
+	mockStore.AssertNotCalled(t, "DeleteComment", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeletePost(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore)
+
+	deletedAt := time.Now()
+	mockStore.On("DeletePost", mock.Anything, "post1").Return(&store.Post{ID: "post1", DeletedAt: &deletedAt}, nil)
+	mockStore.On("DeletePost", mock.Anything, "missing").Return((*store.Post)(nil), store.ErrPostNotFound)
+
+	post, err := postService.DeletePost(ctx, "post1")
+	assert.NoError(t, err)
+	assert.Equal(t, &deletedAt, post.DeletedAt)
+
+	_, err = postService.DeletePost(ctx, "missing")
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+}
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore, service.WithTrashRetention(time.Hour))
+
+	// Удаляются посты, удалённые раньше, чем час назад
+	before := time.Now().Add(-time.Hour)
+	mockStore.On("PurgeDeletedPosts", mock.Anything, mock.MatchedBy(func(at time.Time) bool {
+		return at.Sub(before).Abs() < time.Minute
+	})).Return(3, nil)
+
+	purged, err := postService.PurgeDeletedPosts(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, purged)
+	mockStore.AssertExpectations(t)
+}
+
+func TestPurgeDeletedPosts_KeepForever(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	postService := service.NewPostService(mockStore, service.WithTrashRetention(0))
+
+	purged, err := postService.PurgeDeletedPosts(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+	mockStore.AssertNotCalled(t, "PurgeDeletedPosts", mock.Anything, mock.Anything)
+}
+
 func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	subscriptionService := service.NewSubscriptionService(mockStore)
 
 	postID := "post1"
-	Chan := make(chan *store.Comment)
+	Chan := make(chan *store.CommentEvent)
 	unsubscribeFunc := func() {}
 
-	mockStore.On("Subscribe", postID).Return((<-chan *store.Comment)(Chan), unsubscribeFunc)
+	mockStore.On("Subscribe", mock.Anything, postID).Return((<-chan *store.CommentEvent)(Chan), unsubscribeFunc)
 
-	resultChan, resultFunc := subscriptionService.Subscribe(postID)
+	resultChan, resultFunc := subscriptionService.Subscribe(ctx, postID)
 	assert.NotNil(t, resultChan)
 	assert.NotNil(t, resultFunc)
 
 	mockStore.AssertExpectations(t)
 }
 
+func TestSubscribe_DropsDuplicates(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	subscriptionService := service.NewSubscriptionService(mockStore)
+
+	postID := "post1"
+	Chan := make(chan *store.CommentEvent, 3)
+	comment := &store.Comment{ID: "comment1", PostID: postID}
+	Chan <- store.NewCommentEvent("event1", store.EventCommentAdded, comment)
+	Chan <- store.NewCommentEvent("event1", store.EventCommentAdded, comment)
+	Chan <- store.NewCommentEvent("event2", store.EventCommentAdded, comment)
+	close(Chan)
+
+	mockStore.On("Subscribe", mock.Anything, postID).Return((<-chan *store.CommentEvent)(Chan), func() {})
+
+	resultChan, _ := subscriptionService.Subscribe(ctx, postID)
+
+	var ids []string
+	for event := range resultChan {
+		ids = append(ids, event.ID)
+	}
+	assert.Equal(t, []string{"event1", "event2"}, ids)
+
+	mockStore.AssertExpectations(t)
+}
+
 func TestPublish(t *testing.T) {
+	ctx := context.Background()
 	mockStore := new(MockStore)
 	subscriptionService := service.NewSubscriptionService(mockStore)
 
-	comment := &store.Comment{ID: "comment1"}
+	comment := &store.Comment{ID: "comment1", PostID: "post1"}
 
-	mockStore.On("Publish", comment).Return()
+	mockStore.On("Publish", mock.Anything, mock.MatchedBy(func(event *store.CommentEvent) bool {
+		return event.ID != "" &&
+			event.Type == store.EventCommentAdded &&
+			event.Version == store.EventVersion &&
+			event.PostID == "post1" &&
+			event.CommentID == "comment1" &&
+			event.Comment == comment
+	})).Return()
 
-	subscriptionService.Publish(comment)
+	subscriptionService.Publish(ctx, comment)
 
 	mockStore.AssertExpectations(t)
 }
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	searchService := service.NewSearchService(mockStore)
+
+	cursor := store.Cursor{CreatedAt: time.Unix(0, 1700000000123456789), ID: "post1", Score: 0.6079271}
+	after := service.EncodeCursor(cursor)
+	first := 5
+	kinds := []store.SearchKind{store.SearchPosts}
+	page := &store.Page[*store.SearchHit]{Items: []*store.SearchHit{}}
+
+	mockStore.On("Search", mock.Anything, store.SearchQuery{Text: "go generics", Kinds: kinds}, mock.MatchedBy(func(q store.PageQuery) bool {
+		return q.First == first && q.After != nil && q.After.ID == cursor.ID && q.After.Score == cursor.Score
+	})).Return(page, nil)
+
+	result, err := searchService.Search(ctx, "  go generics ", kinds, service.PageArgs{First: &first, After: &after})
+	assert.NoError(t, err)
+	assert.Equal(t, page, result)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestSearch_InvalidQuery(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	searchService := service.NewSearchService(mockStore)
+
+	for _, query := range []string{"", "   ", strings.Repeat("я", 201)} {
+		result, err := searchService.Search(ctx, query, nil, service.PageArgs{})
+		assert.Nil(t, result)
+		assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+	}
+
+	mockStore.AssertNotCalled(t, "Search", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNodes(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	nodeService := service.NewNodeService(mockStore)
+
+	post := &store.Post{ID: "post1"}
+	comment := &store.Comment{ID: "comment1", PostID: "post1"}
+	mockStore.On("GetPostsByIDs", mock.Anything, []string{"post1"}).Return(map[string]*store.Post{"post1": post}, nil)
+	mockStore.On("GetCommentsByIDs", mock.Anything, []string{"comment1", "missing"}).Return(map[string]*store.Comment{"comment1": comment}, nil)
+
+	nodes, err := nodeService.Nodes(ctx, []string{
+		service.EncodeGlobalID(service.NodeComment, "comment1"),
+		service.EncodeGlobalID(service.NodeComment, "missing"),
+		service.EncodeGlobalID(service.NodePost, "post1"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []*service.Node{{Comment: comment}, nil, {Post: post}}, nodes)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestNodes_InvalidID(t *testing.T) {
+	mockStore := new(MockStore)
+	nodeService := service.NewNodeService(mockStore)
+
+	nodes, err := nodeService.Nodes(context.Background(), []string{"post1"})
+	assert.Nil(t, nodes)
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+
+	mockStore.AssertNotCalled(t, "GetPostsByIDs", mock.Anything, mock.Anything)
+}
+
+func TestDecodeID(t *testing.T) {
+	id, err := service.DecodeID(service.NodePost, service.EncodeGlobalID(service.NodePost, "post1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "post1", id)
+
+	// ID без типа принимается как есть
+	id, err = service.DecodeID(service.NodePost, "post1")
+	assert.NoError(t, err)
+	assert.Equal(t, "post1", id)
+
+	_, err = service.DecodeID(service.NodePost, service.EncodeGlobalID(service.NodeComment, "comment1"))
+	assert.Equal(t, service.CodeInvalidInput, service.ErrorCode(err))
+}
+
+func TestRegisterManifest(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	persistedQueryService := service.NewPersistedQueryService(mockStore)
+
+	body := "{ posts { totalCount } }"
+	manifest := `{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [
+		{"id": "` + service.QueryHash(body) + `", "name": "Posts", "type": "query", "body": "{ posts { totalCount } }"}
+	]}`
+	mockStore.On("SavePersistedQueries", mock.Anything, []*store.PersistedQuery{
+		{Hash: service.QueryHash(body), Name: "Posts", Query: body},
+	}).Return(nil)
+
+	count, err := persistedQueryService.RegisterManifest(ctx, []byte(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	mockStore.AssertExpectations(t)
+}
+
+func TestRegisterManifest_Invalid(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	persistedQueryService := service.NewPersistedQueryService(mockStore)
+
+	for _, manifest := range []string{
+		`not json`,
+		`{"format": "unknown", "version": 1, "operations": []}`,
+		`{"format": "apollo-persisted-query-manifest", "version": 2, "operations": []}`,
+		// ID не совпадает с хешем текста операции
+		`{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": "abc", "body": "{ posts { totalCount } }"}]}`,
+	} {
+		_, err := persistedQueryService.RegisterManifest(ctx, []byte(manifest))
+		assert.Error(t, err, manifest)
+	}
+
+	mockStore.AssertNotCalled(t, "SavePersistedQueries", mock.Anything, mock.Anything)
+}
+
+func TestPersistedQuery_NotFound(t *testing.T) {
+	ctx := context.Background()
+	mockStore := new(MockStore)
+	persistedQueryService := service.NewPersistedQueryService(mockStore)
+
+	mockStore.On("GetPersistedQuery", mock.Anything, "missing").Return(nil, store.ErrPersistedQueryNotFound)
+
+	query, err := persistedQueryService.Query(ctx, "missing")
+	assert.Empty(t, query)
+	assert.Equal(t, service.CodeNotFound, service.ErrorCode(err))
+}
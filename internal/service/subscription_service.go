@@ -1,25 +1,88 @@
 package service
 
 import (
+	"context"
+
 	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/google/uuid"
 )
 
-// CommentService отвечает за работу с комментариями
+// dedupeWindow — сколько последних ID событий помнит подписка
+const dedupeWindow = 1024
+
+// SubscriptionService отвечает за события о комментариях
 type SubscriptionService struct {
 	store store.Store
 }
 
-// NewCommentService создаёт сервис для работы с комментариями
+// NewSubscriptionService создаёт сервис событий о комментариях
 func NewSubscriptionService(store store.Store) *SubscriptionService {
 	return &SubscriptionService{store: store}
 }
 
-// Subscribe создаёт подписку на новые комментарии к посту
-func (s *SubscriptionService) Subscribe(postID string) (<-chan *store.Comment, func()) {
-	return s.store.Subscribe(postID)
+// Subscribe создаёт подписку на события комментариев к посту
+// Повторно доставленные события с уже виденным ID отбрасываются
+func (s *SubscriptionService) Subscribe(ctx context.Context, postID string) (<-chan *store.CommentEvent, func()) {
+	events, unsubscribe := s.store.Subscribe(ctx, postID)
+	if events == nil {
+		return nil, unsubscribe
+	}
+
+	ch := make(chan *store.CommentEvent)
+	go func() {
+		defer close(ch)
+
+		seen := newSeenEvents(dedupeWindow)
+		for event := range events {
+			if !seen.add(event.ID) {
+				continue // Дубликат
+			}
+			select {
+			case ch <- event:
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return ch, unsubscribe
+}
+
+// Publish публикует событие о новом комментарии
+func (s *SubscriptionService) Publish(ctx context.Context, comment *store.Comment) {
+	s.store.Publish(ctx, store.NewCommentEvent(uuid.NewString(), store.EventCommentAdded, comment))
+}
+
+// PublishEdited публикует событие об изменении текста комментария
+func (s *SubscriptionService) PublishEdited(ctx context.Context, comment *store.Comment) {
+	s.store.Publish(ctx, store.NewCommentEvent(uuid.NewString(), store.EventCommentEdited, comment))
+}
+
+// seenEvents — ограниченное множество последних ID событий
+type seenEvents struct {
+	ids   map[string]struct{}
+	order []string // ID в порядке поступления, для вытеснения старых
+	next  int
+}
+
+func newSeenEvents(limit int) *seenEvents {
+	return &seenEvents{
+		ids:   make(map[string]struct{}, limit),
+		order: make([]string, limit),
+	}
 }
 
-// Publish публикует новый комментарий
-func (s *SubscriptionService) Publish(comment *store.Comment) {
-	s.store.Publish(comment)
+// add запоминает ID и возвращает false, если он уже встречался
+func (s *seenEvents) add(id string) bool {
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+	if old := s.order[s.next]; old != "" {
+		delete(s.ids, old)
+	}
+	s.order[s.next] = id
+	s.next = (s.next + 1) % len(s.order)
+	s.ids[id] = struct{}{}
+	return true
 }
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+)
+
+// DeletePost переносит пост в корзину
+// Пост и его комментарии скрываются, пока пост не восстановят
+// или не удалят окончательно по истечении срока хранения
+func (s *PostService) DeletePost(ctx context.Context, id string) (*store.Post, error) {
+	post, err := s.store.DeletePost(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to delete post", err)
+	}
+	return post, nil
+}
+
+// RestorePost возвращает пост из корзины
+func (s *PostService) RestorePost(ctx context.Context, id string) (*store.Post, error) {
+	post, err := s.store.RestorePost(ctx, id)
+	if err != nil {
+		return nil, storeError("failed to restore post", err)
+	}
+	return post, nil
+}
+
+// PurgeDeletedPosts окончательно удаляет посты, которые пролежали
+// в корзине дольше срока хранения, и возвращает их число
+func (s *PostService) PurgeDeletedPosts(ctx context.Context) (int, error) {
+	if s.opts.trashRetention <= 0 {
+		return 0, nil
+	}
+	count, err := s.store.PurgeDeletedPosts(ctx, time.Now().Add(-s.opts.trashRetention))
+	if err != nil {
+		return 0, storeError("failed to purge posts", err)
+	}
+	return count, nil
+}
+
+// RunPurge очищает корзину каждые interval, пока не отменён ctx
+func (s *PostService) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		count, err := s.PurgeDeletedPosts(ctx)
+		if err != nil {
+			log.Printf("could not purge deleted posts: %v", err)
+			continue
+		}
+		if count > 0 {
+			log.Printf("Purged %d deleted posts", count)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import "context"
+
+// userKey — ключ пользователя запроса в контексте
+type userKey struct{}
+
+// WithUser возвращает контекст запроса от имени пользователя user
+// Пользователя определяет транспорт, например HTTP middleware
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext возвращает пользователя запроса; false, если он не задан
+func UserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(userKey{}).(string)
+	return user, ok && user != ""
+}
+
+// currentUser возвращает пользователя запроса или ошибку, если он не задан
+func currentUser(ctx context.Context) (string, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return "", unauthenticated("user is not authenticated")
+	}
+	return user, nil
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+)
+
+// broker — рассылка событий о комментариях внутри процесса
+// Используется хранилищами, у которых нет собственного механизма уведомлений
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[string][]chan *CommentEvent // Подписчики на события комментариев
+}
+
+// newBroker создаёт пустой broker
+func newBroker() *broker {
+	return &broker{subscribers: make(map[string][]chan *CommentEvent)}
+}
+
+// Subscribe — добавляет подписчика
+func (b *broker) Subscribe(postID string) (<-chan *CommentEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Создаём новый канал для подписчика
+	ch := make(chan *CommentEvent, 1)
+	b.subscribers[postID] = append(b.subscribers[postID], ch)
+
+	// Функция для отписки
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		channels := b.subscribers[postID]
+		for i, c := range channels {
+			if c == ch {
+				// Канал закрывается только один раз: после удаления
+				// из списка повторный вызов его уже не найдёт
+				b.subscribers[postID] = append(channels[:i], channels[i+1:]...)
+				close(c)
+				break
+			}
+		}
+		if len(b.subscribers[postID]) == 0 {
+			delete(b.subscribers, postID)
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish — отправляет событие о комментарии подписчикам
+func (b *broker) Publish(event *CommentEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Рассылаем событие всем подписчикам поста
+	for _, ch := range b.subscribers[event.PostID] {
+		select {
+		case ch <- event: // Если клиент готов читать, отправляем
+		default: // Если клиент не читает, пропускаем
+			fmt.Printf("Warning: event %s was not delivered to a subscriber\n", event.ID)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package store
+
+// DeletedPlaceholder заменяет текст и автора удалённого комментария,
+// который остался в ветке ради ответов на него
+const DeletedPlaceholder = "[deleted]"
+
+// Причины удаления комментария: автором или модератором
+const (
+	DeletedByAuthor   = "author"    // Удалён автором
+	DeletedAsSpam     = "spam"      // Удалён модератором как спам
+	DeletedAsAbuse    = "abuse"     // Удалён модератором как оскорбительный
+	DeletedAsOffTopic = "off_topic" // Удалён модератором как не относящийся к теме
+)
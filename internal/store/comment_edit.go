@@ -0,0 +1,13 @@
+package store
+
+import "time"
+
+// CommentEdit — прежний текст комментария, сохранённый при его изменении
+// Number — номер версии: у исходного текста 1, у текста после
+// n-го изменения — n+1. Текущий текст комментария правкой не хранится
+type CommentEdit struct {
+	CommentID string    `json:"comment_id"`
+	Number    int       `json:"number"`
+	Content   string    `json:"content"`   // Текст до изменения
+	EditedAt  time.Time `json:"edited_at"` // Когда комментарий изменён
+}
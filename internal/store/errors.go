@@ -0,0 +1,24 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ошибки хранилища. Все реализации возвращают именно их,
+// поэтому причину можно проверить через errors.Is независимо от СУБД
+var (
+	ErrNotFound      = errors.New("not found")              // Запись не найдена
+	ErrAlreadyExists = errors.New("already exists")         // Запись с таким ID уже есть
+	ErrInvalidParent = errors.New("invalid parent comment") // Родительский комментарий не подходит
+)
+
+// Ошибки для конкретных записей, уточняющие общие
+var (
+	ErrPostNotFound    = fmt.Errorf("post %w", ErrNotFound)
+	ErrCommentNotFound = fmt.Errorf("comment %w", ErrNotFound)
+	ErrPostExists      = fmt.Errorf("post %w", ErrAlreadyExists)
+	ErrCommentExists   = fmt.Errorf("comment %w", ErrAlreadyExists)
+
+	ErrPersistedQueryNotFound = fmt.Errorf("persisted query %w", ErrNotFound)
+)
@@ -0,0 +1,39 @@
+package store
+
+import "time"
+
+// Типы событий о комментариях
+const (
+	EventCommentAdded  = "comment.added"  // Добавлен новый комментарий
+	EventCommentEdited = "comment.edited" // Изменён текст комментария
+)
+
+// EventVersion — текущая версия формата событий
+// Увеличивается при несовместимых изменениях полей
+const EventVersion = 1
+
+// CommentEvent — конверт события о комментарии
+// Это единственный формат, в котором события доходят до подписчиков:
+// и в памяти, и через уведомления PostgreSQL
+type CommentEvent struct {
+	ID         string    `json:"id"`                // Уникальный ID события, по нему отбрасываются дубликаты
+	Type       string    `json:"type"`              // Тип события
+	Version    int       `json:"version"`           // Версия формата события
+	PostID     string    `json:"post_id"`           // Пост, к которому относится событие
+	CommentID  string    `json:"comment_id"`        // Комментарий, к которому относится событие
+	OccurredAt time.Time `json:"occurred_at"`       // Время события
+	Comment    *Comment  `json:"comment,omitempty"` // Комментарий; nil, если он не поместился в уведомление
+}
+
+// NewCommentEvent создаёт событие типа eventType для комментария
+func NewCommentEvent(id, eventType string, comment *Comment) *CommentEvent {
+	return &CommentEvent{
+		ID:         id,
+		Type:       eventType,
+		Version:    EventVersion,
+		PostID:     comment.PostID,
+		CommentID:  comment.ID,
+		OccurredAt: time.Now(),
+		Comment:    comment,
+	}
+}
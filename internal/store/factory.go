@@ -1,10 +1,12 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/SobolevTim/t-graphql/internal/config"
+	"github.com/SobolevTim/t-graphql/internal/migrate"
 )
 
 // NewStore создаёт нужную реализацию хранилища
@@ -17,14 +19,55 @@ func NewStore(cfg *config.Config) (Store, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to Postgres: %w", err)
 		}
+		if cfg.MigrateOnStart {
+			if err := migratePostgres(store); err != nil {
+				store.Close()
+				return nil, err
+			}
+		}
 		log.Println("Using PostgreSQL storage")
 		return store, nil
+	// Использование файловой базы SQLite
+	case "sqlite":
+		store, err := NewSQLiteStore(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite: %w", err)
+		}
+		log.Printf("Using SQLite storage at %s", cfg.SQLitePath)
+		return store, nil
 	// Использование in-memory хранилища
 	case "memory":
-		log.Println("Using in-memory storage")
-		return NewMemoryStore(), nil
+		if cfg.MemoryDataDir == "" {
+			log.Println("Using in-memory storage")
+			return NewMemoryStore(), nil
+		}
+		store, err := OpenMemoryStore(PersistenceOptions{
+			Dir:           cfg.MemoryDataDir,
+			Fsync:         FsyncPolicy(cfg.MemoryFsync),
+			FsyncInterval: cfg.MemoryFsyncInterval,
+			SnapshotEvery: cfg.MemorySnapshotEvery,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open in-memory storage: %w", err)
+		}
+		log.Printf("Using in-memory storage persisted to %s", cfg.MemoryDataDir)
+		return store, nil
 	// Неизвестный тип хранилища
 	default:
 		return nil, fmt.Errorf("unknown storage type: %s", cfg.StorageType)
 	}
 }
+
+// migratePostgres применяет недостающие миграции схемы PostgreSQL
+func migratePostgres(s *Service) error {
+	migrator, err := migrate.NewPostgres(s.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to migrate Postgres: %w", err)
+	}
+	log.Printf("Applied %d migration(s)", applied)
+	return nil
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	listenerBufferSize   = 16                     // Размер буфера канала подписчика
+	listenerReadyTimeout = 5 * time.Second        // Сколько ждать выполнения LISTEN при подписке
+	listenerMinBackoff   = 100 * time.Millisecond // Начальная пауза перед переподключением
+	listenerMaxBackoff   = 10 * time.Second       // Максимальная пауза перед переподключением
+)
+
+// ListenerStats описывает состояние соединения для LISTEN и подписчиков
+type ListenerStats struct {
+	Connected   bool           `json:"connected"`            // Установлено ли соединение
+	Channels    int            `json:"channels"`             // Каналы с активным LISTEN
+	Subscribers int            `json:"subscribers"`          // Всего подписчиков
+	PerChannel  map[string]int `json:"per_channel"`          // Подписчики по каналам
+	Dropped     uint64         `json:"dropped"`              // Уведомления, не доставленные медленным подписчикам
+	Reconnects  uint64         `json:"reconnects"`           // Количество переподключений
+	LastError   string         `json:"last_error,omitempty"` // Последняя ошибка соединения
+}
+
+// listenerSub — подписчик на уведомления одного канала
+type listenerSub struct {
+	ch chan string
+}
+
+// Listener держит одно выделенное соединение с PostgreSQL и раздаёт
+// уведомления подписчикам внутри процесса. LISTEN выполняется при
+// появлении первого подписчика канала, UNLISTEN — при уходе последнего
+type Listener struct {
+	connConfig *pgx.ConnConfig
+
+	mu          sync.Mutex
+	subscribers map[string]map[*listenerSub]struct{} // Подписчики по каналам
+	listening   map[string]struct{}                  // Каналы с выполненным LISTEN
+	ready       map[string]chan struct{}             // Ожидание LISTEN для новых каналов
+	connected   bool
+	dropped     uint64
+	reconnects  uint64
+	lastError   string
+
+	wake   chan struct{} // Сигнал циклу: набор каналов изменился
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewListener создаёт и запускает Listener с собственным соединением
+func NewListener(connConfig *pgx.ConnConfig) *Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener{
+		connConfig:  connConfig,
+		subscribers: make(map[string]map[*listenerSub]struct{}),
+		listening:   make(map[string]struct{}),
+		ready:       make(map[string]chan struct{}),
+		wake:        make(chan struct{}, 1),
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	go l.run(ctx)
+	return l
+}
+
+// Close останавливает цикл и закрывает соединение
+func (l *Listener) Close() {
+	l.cancel()
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for channel, subs := range l.subscribers {
+		for sub := range subs {
+			close(sub.ch)
+		}
+		delete(l.subscribers, channel)
+	}
+}
+
+// Subscribe регистрирует подписчика на канал и ждёт, пока для канала
+// будет выполнен LISTEN. Возвращает канал с содержимым уведомлений
+// и функцию отписки, которая закрывает этот канал
+func (l *Listener) Subscribe(ctx context.Context, channel string) (<-chan string, func()) {
+	sub := &listenerSub{ch: make(chan string, listenerBufferSize)}
+
+	l.mu.Lock()
+	subs, exists := l.subscribers[channel]
+	if !exists {
+		subs = make(map[*listenerSub]struct{})
+		l.subscribers[channel] = subs
+	}
+	subs[sub] = struct{}{}
+	// Первый подписчик канала ждёт, пока цикл выполнит LISTEN
+	ready, pending := l.ready[channel]
+	if len(subs) == 1 && !pending {
+		ready = make(chan struct{})
+		l.ready[channel] = ready
+		pending = true
+	}
+	l.mu.Unlock()
+
+	if pending {
+		l.notify()
+		timer := time.NewTimer(listenerReadyTimeout)
+		select {
+		case <-ready:
+		case <-ctx.Done():
+		case <-timer.C:
+			log.Printf("listener: LISTEN %s is not confirmed yet, subscription will catch up after reconnect", channel)
+		}
+		timer.Stop()
+	}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			subs := l.subscribers[channel]
+			if _, ok := subs[sub]; !ok {
+				return // Уже закрыт в Close
+			}
+			delete(subs, sub)
+			close(sub.ch)
+			if len(subs) == 0 {
+				delete(l.subscribers, channel)
+				if ready, ok := l.ready[channel]; ok {
+					close(ready)
+					delete(l.ready, channel)
+				}
+				l.notify()
+			}
+		})
+	}
+
+	return sub.ch, unsubscribe
+}
+
+// Stats возвращает текущее состояние listener
+func (l *Listener) Stats() ListenerStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := ListenerStats{
+		Connected:  l.connected,
+		Channels:   len(l.listening),
+		PerChannel: make(map[string]int, len(l.subscribers)),
+		Dropped:    l.dropped,
+		Reconnects: l.reconnects,
+		LastError:  l.lastError,
+	}
+	for channel, subs := range l.subscribers {
+		stats.PerChannel[channel] = len(subs)
+		stats.Subscribers += len(subs)
+	}
+	return stats
+}
+
+// notify будит цикл, не блокируясь, если сигнал уже ожидает обработки.
+// Можно вызывать с удержанием l.mu
+func (l *Listener) notify() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run поддерживает соединение и переподключается при ошибках
+func (l *Listener) run(ctx context.Context) {
+	defer close(l.done)
+
+	backoff := listenerMinBackoff
+	for {
+		err := l.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		l.mu.Lock()
+		// После успешного подключения паузы начинаются заново
+		if l.connected {
+			backoff = listenerMinBackoff
+		}
+		l.connected = false
+		l.listening = make(map[string]struct{})
+		l.reconnects++
+		if err != nil {
+			l.lastError = err.Error()
+		}
+		l.mu.Unlock()
+		log.Printf("listener: connection lost: %v, reconnecting in %s", err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, listenerMaxBackoff)
+	}
+}
+
+// session обслуживает одно соединение до первой ошибки
+func (l *Listener) session(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, l.connConfig.Copy())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	l.mu.Lock()
+	l.connected = true
+	l.lastError = ""
+	l.mu.Unlock()
+
+	for {
+		if err := l.sync(ctx, conn); err != nil {
+			return err
+		}
+
+		// Ожидание прерывается, когда меняется набор каналов
+		waitCtx, cancel := context.WithCancel(ctx)
+		stop := make(chan struct{})
+		go func() {
+			select {
+			case <-l.wake:
+				cancel()
+			case <-stop:
+			}
+		}()
+
+		notification, err := conn.WaitForNotification(waitCtx)
+		close(stop)
+		interrupted := waitCtx.Err() != nil
+		cancel()
+
+		if notification != nil {
+			l.dispatch(notification.Channel, notification.Payload)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if interrupted {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// sync приводит набор каналов с LISTEN в соответствие с подписчиками
+func (l *Listener) sync(ctx context.Context, conn *pgx.Conn) error {
+	l.mu.Lock()
+	var toListen, toUnlisten []string
+	for channel := range l.subscribers {
+		if _, ok := l.listening[channel]; !ok {
+			toListen = append(toListen, channel)
+		}
+	}
+	for channel := range l.listening {
+		if _, ok := l.subscribers[channel]; !ok {
+			toUnlisten = append(toUnlisten, channel)
+		}
+	}
+	// Каналы, уже находящиеся под LISTEN, готовы сразу
+	for channel, ready := range l.ready {
+		if _, ok := l.listening[channel]; ok {
+			close(ready)
+			delete(l.ready, channel)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, channel := range toUnlisten {
+		if _, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		delete(l.listening, channel)
+		l.mu.Unlock()
+	}
+
+	for _, channel := range toListen {
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		l.listening[channel] = struct{}{}
+		if ready, ok := l.ready[channel]; ok {
+			close(ready)
+			delete(l.ready, channel)
+		}
+		l.mu.Unlock()
+	}
+
+	return nil
+}
+
+// dispatch рассылает уведомление всем подписчикам канала
+func (l *Listener) dispatch(channel, payload string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sub := range l.subscribers[channel] {
+		select {
+		case sub.ch <- payload: // Если клиент готов читать, отправляем
+		default: // Если клиент не читает, пропускаем
+			l.dropped++
+			log.Printf("listener: notification on %s was not delivered to a subscriber", channel)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FsyncPolicy — когда журнал MemoryStore сбрасывается на диск
+type FsyncPolicy string
+
+const (
+	FsyncAlways   FsyncPolicy = "always"   // После каждой записи: ничего не теряется, но медленно
+	FsyncInterval FsyncPolicy = "interval" // Периодически в фоне: при сбое теряется не больше интервала
+	FsyncNever    FsyncPolicy = "never"    // Сбросом на диск управляет ОС
+)
+
+// PersistenceOptions — параметры сохранения MemoryStore на диск
+type PersistenceOptions struct {
+	Dir           string        // Каталог журнала и снимков
+	Fsync         FsyncPolicy   // Политика fsync журнала
+	FsyncInterval time.Duration // Период fsync для FsyncInterval
+	SnapshotEvery int           // Число записей журнала между снимками; 0 — снимок только при Close
+}
+
+const (
+	logFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+
+	defaultFsyncInterval = time.Second
+)
+
+// Операции, которые записываются в журнал
+const (
+	opCreatePost               = "create_post"
+	opCreateComment            = "create_comment"
+	opUpdateCommentsPermission = "update_comments_permission"
+	opAddRelatedPosts          = "add_related_posts"
+	opSavePersistedQueries     = "save_persisted_queries"
+	opUpdatePost               = "update_post"
+	opDeletePost               = "delete_post"
+	opRestorePost              = "restore_post"
+	opPurgePosts               = "purge_posts"
+	opUpdateComment            = "update_comment"
+	opDeleteComment            = "delete_comment"
+)
+
+// logRecord — одна строка журнала
+type logRecord struct {
+	Seq           uint64            `json:"seq"` // Номер записи, растёт монотонно
+	Op            string            `json:"op"`
+	Post          *Post             `json:"post,omitempty"`
+	Comment       *Comment          `json:"comment,omitempty"`
+	PostID        string            `json:"post_id,omitempty"`
+	AllowComments bool              `json:"allow_comments,omitempty"`
+	Related       []RelatedScore    `json:"related,omitempty"`
+	Queries       []*PersistedQuery `json:"queries,omitempty"`
+	Update        *PostUpdate       `json:"update,omitempty"`
+	Revision      *PostRevision     `json:"revision,omitempty"`
+	DeletedAt     *time.Time        `json:"deleted_at,omitempty"`
+	PostIDs       []string          `json:"post_ids,omitempty"`
+	CommentID     string            `json:"comment_id,omitempty"`
+	Content       string            `json:"content,omitempty"`
+	Edit          *CommentEdit      `json:"edit,omitempty"`
+	Reason        string            `json:"reason,omitempty"`
+}
+
+// snapshot — сжатое состояние хранилища
+// Записи журнала с Seq <= snapshot.Seq уже учтены в снимке
+type snapshot struct {
+	Seq              uint64                     `json:"seq"`
+	Posts            []*Post                    `json:"posts"`                       // В порядке создания
+	Comments         map[string][]*Comment      `json:"comments"`                    // Комментарии к постам в порядке создания
+	Related          map[string][]RelatedScore  `json:"related,omitempty"`           // Сходство постов, в обе стороны
+	Revisions        map[string][]*PostRevision `json:"revisions,omitempty"`         // Ревизии постов от старых к новым
+	Edits            map[string][]*CommentEdit  `json:"edits,omitempty"`             // Правки комментариев от старых к новым
+	PersistedQueries []*PersistedQuery          `json:"persisted_queries,omitempty"` // Разрешённые операции GraphQL
+}
+
+// memoryLog — журнал изменений MemoryStore (write-ahead log)
+// Каждое изменение записывается в журнал до того, как применяется в памяти
+type memoryLog struct {
+	mu            sync.Mutex
+	opts          PersistenceOptions
+	file          logFile
+	size          int64  // Размер журнала после последней успешной записи
+	seq           uint64 // Номер последней записи
+	sinceSnapshot int    // Записей с момента последнего снимка
+	dirty         bool   // Есть записи, ещё не сброшенные на диск
+	stop          chan struct{}
+	done          chan struct{}
+}
+
+// logFile — файл журнала; *os.File, в тестах его можно подменить
+type logFile interface {
+	io.ReadWriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+	Name() string
+}
+
+// OpenMemoryStore создаёт MemoryStore, который сохраняет изменения в opts.Dir
+// Состояние восстанавливается из последнего снимка и журнала.
+// Недописанная последняя запись журнала (например, после сбоя питания) отбрасывается
+func OpenMemoryStore(opts PersistenceOptions) (*MemoryStore, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("data dir is required")
+	}
+	switch opts.Fsync {
+	case FsyncAlways, FsyncInterval, FsyncNever:
+	default:
+		return nil, fmt.Errorf("unknown fsync policy: %s", opts.Fsync)
+	}
+	if opts.FsyncInterval <= 0 {
+		opts.FsyncInterval = defaultFsyncInterval
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create data dir: %w", err)
+	}
+
+	s := NewMemoryStore()
+	wal := &memoryLog{opts: opts}
+
+	// Сначала снимок, затем более новые записи журнала
+	seq, err := s.loadSnapshot(filepath.Join(opts.Dir, snapshotFileName))
+	if err != nil {
+		return nil, err
+	}
+	wal.seq = seq
+
+	file, err := os.OpenFile(filepath.Join(opts.Dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log: %w", err)
+	}
+	wal.file = file
+	if err := s.replay(wal); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if opts.Fsync == FsyncInterval {
+		wal.stop = make(chan struct{})
+		wal.done = make(chan struct{})
+		go wal.syncLoop()
+	}
+
+	s.wal = wal
+	return s, nil
+}
+
+// loadSnapshot восстанавливает состояние из снимка, если он есть
+// Возвращает номер последней учтённой записи журнала
+func (s *MemoryStore) loadSnapshot(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("could not decode snapshot: %w", err)
+	}
+	for _, post := range snap.Posts {
+		// Счётчики пересчитываются по комментариям снимка
+		post.CommentCount, post.TopLevelCommentCount = 0, 0
+		s.applyCreatePost(post)
+	}
+	for _, comments := range snap.Comments {
+		for _, comment := range comments {
+			// Родитель всегда идёт раньше ответа, поэтому
+			// ветку старого снимка можно восстановить по порядку
+			if comment.RootID == "" {
+				if err := s.setThread(comment); err != nil {
+					return 0, fmt.Errorf("could not restore comment %s: %w", comment.ID, err)
+				}
+			}
+			// Удалённые комментарии, оставленные в ветке, в поисковый индекс
+			// не попадают: их пропускает searchIndex.addComment
+			comment.ReplyCount, comment.DescendantCount = 0, 0
+			s.applyCreateComment(comment)
+		}
+	}
+	for _, post := range snap.Posts {
+		// Пост уходит в корзину после своих комментариев,
+		// чтобы они тоже не попали в поисковый индекс
+		// В хранилище уже копия поста с пересчитанными счётчиками
+		if post.DeletedAt != nil {
+			s.applyDeletePost(s.posts[post.ID], *post.DeletedAt)
+		}
+	}
+	for id, related := range snap.Related {
+		s.applyAddRelatedPosts(id, related)
+	}
+	for id, revisions := range snap.Revisions {
+		// Текущие заголовок и текст постов уже в снимке
+		s.revisions[id] = revisions
+	}
+	for id, edits := range snap.Edits {
+		// Текущий текст комментариев уже в снимке
+		s.edits[id] = edits
+	}
+	s.applySavePersistedQueries(snap.PersistedQueries)
+	return snap.Seq, nil
+}
+
+// replay применяет записи журнала, которых ещё нет в снимке
+// Недописанный хвост журнала обрезается
+func (s *MemoryStore) replay(wal *memoryLog) error {
+	reader := bufio.NewReader(wal.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// Последняя запись не дописана до конца
+				return wal.truncateTail(offset, int64(len(line)))
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("could not read log: %w", err)
+		}
+
+		var record logRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			// Повреждённой может быть только последняя запись,
+			// повреждение в середине журнала не исправить без потери данных
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return wal.truncateTail(offset, int64(len(line)))
+			}
+			return fmt.Errorf("corrupted log record at offset %d: %w", offset, err)
+		}
+		offset += int64(len(line))
+
+		// Запись уже учтена в снимке
+		if record.Seq <= wal.seq {
+			continue
+		}
+		if err := s.apply(&record); err != nil {
+			return fmt.Errorf("could not replay log record %d: %w", record.Seq, err)
+		}
+		wal.seq = record.Seq
+		wal.sinceSnapshot++
+	}
+
+	wal.size = offset
+	_, err := wal.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// apply применяет запись журнала к состоянию в памяти
+func (s *MemoryStore) apply(record *logRecord) error {
+	switch record.Op {
+	case opCreatePost:
+		if record.Post == nil {
+			return errors.New("post is missing")
+		}
+		if _, exists := s.posts[record.Post.ID]; exists {
+			return ErrPostExists
+		}
+		s.applyCreatePost(record.Post)
+	case opCreateComment:
+		if record.Comment == nil {
+			return errors.New("comment is missing")
+		}
+		// В журналах, записанных до появления веток, нет глубины и корня
+		if record.Comment.RootID == "" {
+			if err := s.setThread(record.Comment); err != nil {
+				return err
+			}
+		}
+		s.applyCreateComment(record.Comment)
+	case opUpdateCommentsPermission:
+		post, exists := s.posts[record.PostID]
+		if !exists {
+			return ErrPostNotFound
+		}
+		s.applyUpdateCommentsPermission(post, record.AllowComments)
+	case opAddRelatedPosts:
+		if _, exists := s.posts[record.PostID]; !exists {
+			return ErrPostNotFound
+		}
+		s.applyAddRelatedPosts(record.PostID, record.Related)
+	case opUpdatePost:
+		post, exists := s.posts[record.PostID]
+		if !exists {
+			return ErrPostNotFound
+		}
+		if record.Update == nil || record.Revision == nil {
+			return errors.New("post update is missing")
+		}
+		s.applyUpdatePost(post, *record.Update, record.Revision)
+	case opDeletePost:
+		post, exists := s.visiblePost(record.PostID)
+		if !exists {
+			return ErrPostNotFound
+		}
+		if record.DeletedAt == nil {
+			return errors.New("deletion time is missing")
+		}
+		s.applyDeletePost(post, *record.DeletedAt)
+	case opRestorePost:
+		post, exists := s.posts[record.PostID]
+		if !exists || post.DeletedAt == nil {
+			return ErrPostNotFound
+		}
+		s.applyRestorePost(post)
+	case opPurgePosts:
+		s.applyPurgePosts(record.PostIDs)
+	case opUpdateComment:
+		comment, exists := s.commentsByID[record.CommentID]
+		if !exists {
+			return ErrCommentNotFound
+		}
+		if record.Edit == nil {
+			return errors.New("comment edit is missing")
+		}
+		s.applyUpdateComment(comment, record.Content, record.Edit)
+	case opDeleteComment:
+		comment, exists := s.commentsByID[record.CommentID]
+		if !exists || comment.DeletedAt != nil {
+			return ErrCommentNotFound
+		}
+		if record.DeletedAt == nil {
+			return errors.New("deletion time is missing")
+		}
+		s.applyDeleteComment(comment, *record.DeletedAt, record.Reason)
+	case opSavePersistedQueries:
+		s.applySavePersistedQueries(record.Queries)
+	default:
+		return fmt.Errorf("unknown operation: %s", record.Op)
+	}
+	return nil
+}
+
+// truncateTail отрезает недописанную запись длиной length по смещению offset
+func (l *memoryLog) truncateTail(offset, length int64) error {
+	log.Printf("Warning: discarding %d bytes of incomplete record at the end of %s", length, l.file.Name())
+	if err := l.file.Truncate(offset); err != nil {
+		return fmt.Errorf("could not truncate log: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("could not sync log: %w", err)
+	}
+	l.size = offset
+	_, err := l.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// append записывает изменение в журнал
+// Вызывается под блокировкой MemoryStore, поэтому порядок записей
+// совпадает с порядком изменений в памяти
+func (l *memoryLog) append(record *logRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Seq = l.seq + 1
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if _, err := l.file.Write(data); err != nil {
+		l.rollback()
+		return err
+	}
+	if l.opts.Fsync == FsyncAlways {
+		if err := l.file.Sync(); err != nil {
+			// Изменение не применяется в памяти, поэтому запись
+			// не должна попасть в журнал и при следующем сбросе
+			l.rollback()
+			return err
+		}
+	} else {
+		l.dirty = true
+	}
+
+	l.size += int64(len(data))
+	l.seq = record.Seq
+	l.sinceSnapshot++
+	return nil
+}
+
+// rollback убирает из журнала данные неудавшейся записи, чтобы следующие
+// записи не оказались после повреждённой
+func (l *memoryLog) rollback() {
+	l.file.Truncate(l.size)
+	l.file.Seek(l.size, io.SeekStart)
+}
+
+// needsSnapshot сообщает, пора ли сжать журнал в снимок
+func (l *memoryLog) needsSnapshot() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.opts.SnapshotEvery > 0 && l.sinceSnapshot >= l.opts.SnapshotEvery
+}
+
+// compact атомарно записывает снимок и очищает журнал
+// Если процесс упадёт между этими шагами, записи журнала
+// будут пропущены при восстановлении по номеру snap.Seq
+func (l *memoryLog) compact(snap *snapshot) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snap.Seq = l.seq
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(l.opts.Dir, snapshotFileName)
+	tmp := path + ".tmp"
+	if err := writeFileSync(tmp, data); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not replace snapshot: %w", err)
+	}
+	if err := syncDir(l.opts.Dir); err != nil {
+		return fmt.Errorf("could not sync data dir: %w", err)
+	}
+
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("could not truncate log: %w", err)
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.size = 0
+	l.sinceSnapshot = 0
+	l.dirty = false
+	return nil
+}
+
+// syncLoop периодически сбрасывает журнал на диск
+func (l *memoryLog) syncLoop() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.opts.FsyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.sync(); err != nil {
+				log.Printf("Warning: could not sync log: %v", err)
+			}
+		}
+	}
+}
+
+// sync сбрасывает на диск ещё не сброшенные записи
+func (l *memoryLog) sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.dirty {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return err
+	}
+	l.dirty = false
+	return nil
+}
+
+// close останавливает фоновый fsync и закрывает журнал
+func (l *memoryLog) close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	if err := l.sync(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+// writeFileSync записывает файл и сбрасывает его на диск
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir сбрасывает на диск каталог, чтобы переименование файла пережило сбой
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
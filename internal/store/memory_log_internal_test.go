@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingSyncFile — файл журнала, сброс которого на диск не удаётся
+type failingSyncFile struct {
+	*os.File
+}
+
+func (f failingSyncFile) Sync() error {
+	return errors.New("sync failed")
+}
+
+func TestDurableMemoryStore_SyncFailure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	opts := PersistenceOptions{Dir: dir, Fsync: FsyncAlways}
+
+	s, err := OpenMemoryStore(opts)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "1", "First", "Content", "Author", true)
+	require.NoError(t, err)
+
+	file := s.wal.file.(*os.File)
+	s.wal.file = failingSyncFile{file}
+	_, err = s.CreatePost(ctx, "2", "Lost", "Content", "Author", true)
+	require.Error(t, err)
+	_, err = s.GetPostByID(ctx, "2")
+	assert.ErrorIs(t, err, ErrPostNotFound)
+
+	// Неудавшаяся запись убрана из журнала, следующая идёт сразу за первой
+	info, err := file.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, s.wal.size, info.Size())
+	s.wal.file = file
+	_, err = s.CreatePost(ctx, "3", "Third", "Content", "Author", true)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), s.wal.seq)
+
+	// Закрываем без снимка, чтобы состояние восстанавливалось только из журнала
+	require.NoError(t, s.wal.close())
+	s.wal = nil
+
+	restored, err := OpenMemoryStore(opts)
+	require.NoError(t, err)
+	defer restored.Close()
+	_, err = restored.GetPostByID(ctx, "1")
+	assert.NoError(t, err)
+	_, err = restored.GetPostByID(ctx, "2")
+	assert.ErrorIs(t, err, ErrPostNotFound)
+	_, err = restored.GetPostByID(ctx, "3")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,417 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/SobolevTim/t-graphql/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openDurable открывает сохраняемое in-memory хранилище в каталоге dir
+func openDurable(t *testing.T, dir string, snapshotEvery int) *store.MemoryStore {
+	s, err := store.OpenMemoryStore(store.PersistenceOptions{
+		Dir:           dir,
+		Fsync:         store.FsyncAlways,
+		SnapshotEvery: snapshotEvery,
+	})
+	require.NoError(t, err)
+	return s
+}
+
+// fillStore создаёт пост с комментарием, ответом и запретом комментариев
+func fillStore(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	_, err := s.CreatePost(ctx, "1", "Title", "Content", "Author", true)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "2", "Second", "Content", "Author", true)
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "c1", "1", nil, "Comment", "Author")
+	require.NoError(t, err)
+	parentID := "c1"
+	_, err = s.CreateComment(ctx, "c2", "1", &parentID, "Reply", "Author")
+	require.NoError(t, err)
+	_, err = s.UpdatePostCommentsPermission(ctx, "2", false)
+	require.NoError(t, err)
+}
+
+// assertRestored проверяет, что данные из fillStore восстановлены
+func assertRestored(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	page, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	posts := page.Items
+	require.Len(t, posts, 2)
+	assert.Equal(t, "2", posts[0].ID)
+	assert.False(t, posts[0].AllowComments)
+	assert.Equal(t, "1", posts[1].ID)
+	assert.True(t, posts[1].AllowComments)
+	assert.Equal(t, 2, posts[1].CommentCount)
+	assert.Equal(t, 1, posts[1].TopLevelCommentCount)
+
+	comments, err := s.ListComments(ctx, "1", nil, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, comments.Items, 1)
+	assert.Equal(t, "c1", comments.Items[0].ID)
+	// Счётчики ответов пересчитываются, а не берутся из снимка повторно
+	assert.Equal(t, 1, comments.Items[0].ReplyCount)
+	assert.Equal(t, 1, comments.Items[0].DescendantCount)
+
+	parentID := "c1"
+	replies, err := s.ListComments(ctx, "1", &parentID, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, replies.Items, 1)
+	assert.Equal(t, "c2", replies.Items[0].ID)
+
+	// Поисковый индекс строится заново при восстановлении
+	hits, err := s.Search(ctx, store.SearchQuery{Text: "reply"}, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, hits.Items, 1)
+	assert.Equal(t, "c2", hits.Items[0].Comment.ID)
+}
+
+func TestDurableMemoryStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		s := openDurable(t, t.TempDir(), 3)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestDurableMemoryStore_ReplaysLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+
+	// Имитируем сбой: снимок при Close не пишется,
+	// состояние восстанавливается только из журнала
+	reopened := openDurable(t, dir, 0)
+	defer reopened.Close()
+	assertRestored(t, reopened)
+
+	_, err := os.Stat(filepath.Join(dir, "snapshot.json"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDurableMemoryStore_SnapshotAndLog(t *testing.T) {
+	dir := t.TempDir()
+	// Снимок после трёх записей, остальные две остаются в журнале
+	s := openDurable(t, dir, 3)
+	fillStore(t, s)
+
+	_, err := os.Stat(filepath.Join(dir, "snapshot.json"))
+	require.NoError(t, err)
+
+	reopened := openDurable(t, dir, 3)
+	defer reopened.Close()
+	assertRestored(t, reopened)
+}
+
+func TestDurableMemoryStore_CloseWritesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	require.NoError(t, s.Close())
+
+	info, err := os.Stat(filepath.Join(dir, "wal.log"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	reopened := openDurable(t, dir, 0)
+	defer reopened.Close()
+	assertRestored(t, reopened)
+}
+
+func TestDurableMemoryStore_RelatedPosts(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Пять записей fillStore попадают в снимок, сходство — в журнал
+	s := openDurable(t, dir, 5)
+	fillStore(t, s)
+	require.NoError(t, s.AddRelatedPosts(ctx, "1", []store.RelatedScore{{PostID: "2", Score: 0.4}}))
+
+	assertRelated := func(s store.Store) {
+		t.Helper()
+		related, err := s.ListRelatedPosts(ctx, "2", 10)
+		require.NoError(t, err)
+		require.Len(t, related, 1)
+		assert.Equal(t, "1", related[0].Post.ID)
+		assert.Equal(t, 0.4, related[0].Score)
+	}
+
+	reopened := openDurable(t, dir, 5)
+	assertRelated(reopened)
+	// Close переносит сходство в снимок
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 5)
+	defer restored.Close()
+	assertRelated(restored)
+}
+
+func TestDurableMemoryStore_PersistedQueries(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	require.NoError(t, s.SavePersistedQueries(ctx, []*store.PersistedQuery{{Hash: "h1", Name: "Posts", Query: "{ posts { totalCount } }"}}))
+
+	assertSaved := func(s store.Store) {
+		t.Helper()
+		query, err := s.GetPersistedQuery(ctx, "h1")
+		require.NoError(t, err)
+		assert.Equal(t, "{ posts { totalCount } }", query.Query)
+	}
+
+	reopened := openDurable(t, dir, 0)
+	assertSaved(reopened)
+	// Close переносит операции в снимок
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	assertSaved(restored)
+}
+
+func TestDurableMemoryStore_PostRevisions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	_, err := s.UpdatePost(ctx, "1", store.PostUpdate{Title: "Edited", Content: "New content", Editor: "Editor"})
+	require.NoError(t, err)
+
+	assertEdited := func(s store.Store) {
+		t.Helper()
+		post, err := s.GetPostByID(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "Edited", post.Title)
+		assert.NotNil(t, post.UpdatedAt)
+
+		revisions, err := s.ListPostRevisions(ctx, "1")
+		require.NoError(t, err)
+		require.Len(t, revisions, 1)
+		assert.Equal(t, "Title", revisions[0].Title)
+		assert.Equal(t, "Editor", revisions[0].Editor)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "edited"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 1)
+		assert.Equal(t, "1", hits.Items[0].Post.ID)
+	}
+
+	reopened := openDurable(t, dir, 0)
+	assertEdited(reopened)
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	assertEdited(restored)
+}
+
+func TestDurableMemoryStore_CommentEdits(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	_, err := s.UpdateComment(ctx, "c2", "Edited reply")
+	require.NoError(t, err)
+
+	assertEdited := func(s store.Store) {
+		t.Helper()
+		comment, err := s.GetCommentByID(ctx, "c2")
+		require.NoError(t, err)
+		assert.Equal(t, "Edited reply", comment.Content)
+		assert.NotNil(t, comment.EditedAt)
+
+		edits, err := s.ListCommentEdits(ctx, []string{"c2"})
+		require.NoError(t, err)
+		require.Len(t, edits["c2"], 1)
+		assert.Equal(t, "Reply", edits["c2"][0].Content)
+	}
+
+	reopened := openDurable(t, dir, 0)
+	assertEdited(reopened)
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	assertEdited(restored)
+}
+
+func TestDurableMemoryStore_CommentDeletion(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	_, err := s.DeleteComment(ctx, "c1", store.DeletedAsSpam)
+	require.NoError(t, err)
+
+	assertTombstone := func(s store.Store) {
+		t.Helper()
+		comment, err := s.GetCommentByID(ctx, "c1")
+		require.NoError(t, err)
+		assert.Equal(t, store.DeletedPlaceholder, comment.Content)
+		assert.Equal(t, store.DeletedAsSpam, comment.DeleteReason)
+		assert.NotNil(t, comment.DeletedAt)
+		assert.Equal(t, 1, comment.ReplyCount)
+
+		// Удалённый комментарий не возвращается в поисковый индекс
+		for _, text := range []string{"comment", "deleted"} {
+			hits, err := s.Search(ctx, store.SearchQuery{Text: text}, store.PageQuery{First: 10})
+			require.NoError(t, err)
+			assert.Empty(t, hits.Items, text)
+		}
+	}
+
+	// Сначала из журнала, затем из снимка
+	reopened := openDurable(t, dir, 0)
+	assertTombstone(reopened)
+	require.NoError(t, reopened.Close())
+
+	reopened = openDurable(t, dir, 0)
+	assertTombstone(reopened)
+	_, err = reopened.DeleteComment(ctx, "c2", store.DeletedByAuthor)
+	require.NoError(t, err)
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	for _, id := range []string{"c1", "c2"} {
+		_, err := restored.GetCommentByID(ctx, id)
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+	}
+	post, err := restored.GetPostByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Zero(t, post.CommentCount)
+}
+
+func TestDurableMemoryStore_Trash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	_, err := s.DeletePost(ctx, "1")
+	require.NoError(t, err)
+	_, err = s.DeletePost(ctx, "2")
+	require.NoError(t, err)
+	_, err = s.RestorePost(ctx, "2")
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "3", "Third", "Content", "Author", true)
+	require.NoError(t, err)
+	_, err = s.DeletePost(ctx, "3")
+	require.NoError(t, err)
+	purged, err := s.PurgeDeletedPosts(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, 2, purged)
+
+	assertPurged := func(s store.Store) {
+		t.Helper()
+		page, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, "2", page.Items[0].ID)
+		assert.Nil(t, page.Items[0].DeletedAt)
+
+		count, err := s.CountPosts(ctx, store.PostFilter{Deleted: true})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		_, err = s.RestorePost(ctx, "1")
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+		_, err = s.GetCommentByID(ctx, "c1")
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+	}
+
+	reopened := openDurable(t, dir, 0)
+	assertPurged(reopened)
+	require.NoError(t, reopened.Close())
+
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	assertPurged(restored)
+}
+
+func TestDurableMemoryStore_TrashSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+	_, err := s.DeletePost(ctx, "1")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// Пост из снимка остаётся в корзине вместе с комментариями
+	restored := openDurable(t, dir, 0)
+	defer restored.Close()
+	_, err = restored.GetPostByID(ctx, "1")
+	assert.ErrorIs(t, err, store.ErrPostNotFound)
+	_, err = restored.GetCommentByID(ctx, "c2")
+	assert.ErrorIs(t, err, store.ErrCommentNotFound)
+
+	post, err := restored.RestorePost(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	comment, err := restored.GetCommentByID(ctx, "c2")
+	require.NoError(t, err)
+	assert.Equal(t, "Reply", comment.Content)
+}
+
+func TestDurableMemoryStore_TruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+
+	// Недописанная запись в конце журнала, как после сбоя питания
+	logPath := filepath.Join(dir, "wal.log")
+	before, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	file, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"seq":6,"op":"create_post","post":{"id":"3","tit`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened := openDurable(t, dir, 0)
+	assertRestored(t, reopened)
+
+	// Хвост обрезан, новые записи идут после последней целой
+	after, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	_, err = reopened.CreatePost(context.Background(), "3", "Third", "Content", "Author", true)
+	require.NoError(t, err)
+
+	again := openDurable(t, dir, 0)
+	defer again.Close()
+	post, err := again.GetPostByID(context.Background(), "3")
+	require.NoError(t, err)
+	assert.Equal(t, "Third", post.Title)
+}
+
+func TestDurableMemoryStore_CorruptedMiddle(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir, 0)
+	fillStore(t, s)
+
+	logPath := filepath.Join(dir, "wal.log")
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(logPath, append([]byte("garbage\n"), data...), 0o644))
+
+	_, err = store.OpenMemoryStore(store.PersistenceOptions{Dir: dir, Fsync: store.FsyncAlways})
+	assert.ErrorContains(t, err, "corrupted log record")
+}
+
+func TestOpenMemoryStore_InvalidOptions(t *testing.T) {
+	_, err := store.OpenMemoryStore(store.PersistenceOptions{Fsync: store.FsyncAlways})
+	assert.Error(t, err)
+
+	_, err = store.OpenMemoryStore(store.PersistenceOptions{Dir: t.TempDir(), Fsync: "sometimes"})
+	assert.ErrorContains(t, err, "unknown fsync policy")
+}
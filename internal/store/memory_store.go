@@ -1,38 +1,66 @@
 package store
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"log"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 )
 
 // MemoryStore — in-memory хранилище постов и комментариев
+// Посты и комментарии, выданные из хранилища, читаются без блокировки,
+// поэтому сохранённые значения не изменяются: изменение записывается
+// в копию, которая заменяет прежнее значение во всех индексах
 type MemoryStore struct {
-	mu          sync.RWMutex               // Защита от гонок при доступе к хранилищу
-	posts       map[string]*Post           // Посты
-	comments    map[string][]*Comment      // Комментарии к постам
-	subscribers map[string][]chan *Comment // Подписчики на новые комментарии
+	mu           sync.RWMutex                  // Защита от гонок при доступе к хранилищу
+	posts        map[string]*Post              // Посты
+	postOrder    []string                      // ID постов в порядке создания
+	postIndex    []*Post                       // Посты от старых к новым по (CreatedAt, ID)
+	byAuthor     map[string][]*Post            // Посты авторов от старых к новым
+	byComments   []*Post                       // Посты по возрастанию (CommentCount, CreatedAt, ID)
+	trash        []*Post                       // Удалённые посты от старых к новым по (CreatedAt, ID)
+	related      map[string]map[string]float64 // Сходство постов: ID поста → ID похожего поста → сходство
+	revisions    map[string][]*PostRevision    // Ревизии постов от старых к новым
+	edits        map[string][]*CommentEdit     // Правки комментариев от старых к новым
+	commentOrder map[string][]string           // ID комментариев к постам в порядке создания
+	threads      map[threadKey][]*Comment      // Ветки комментариев от старых к новым по (CreatedAt, ID)
+	commentsByID map[string]*Comment           // Комментарии по ID
+	search       *searchIndex                  // Обратный индекс для полнотекстового поиска
+	persisted    map[string]*PersistedQuery    // Разрешённые операции GraphQL по хешу
+	events       *broker                       // Подписчики на события комментариев
+	wal          *memoryLog                    // Журнал изменений; nil, если данные не сохраняются
 }
 
 // NewMemoryStore создаёт новый in-memory store
 // и инициализирует его пустыми списками постов и комментариев
+// Данные такого хранилища теряются при перезапуске, см. OpenMemoryStore
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		posts:       make(map[string]*Post),
-		comments:    make(map[string][]*Comment),
-		subscribers: make(map[string][]chan *Comment),
+		posts:        make(map[string]*Post),
+		byAuthor:     make(map[string][]*Post),
+		related:      make(map[string]map[string]float64),
+		revisions:    make(map[string][]*PostRevision),
+		edits:        make(map[string][]*CommentEdit),
+		commentOrder: make(map[string][]string),
+		threads:      make(map[threadKey][]*Comment),
+		commentsByID: make(map[string]*Comment),
+		search:       newSearchIndex(),
+		persisted:    make(map[string]*PersistedQuery),
+		events:       newBroker(),
 	}
 }
 
 // Создание поста
 // allowComments — разрешены ли комментарии к посту
-func (s *MemoryStore) CreatePost(id, title, content, author string, allowComments bool) (*Post, error) {
+func (s *MemoryStore) CreatePost(ctx context.Context, id, title, content, author string, allowComments bool) (*Post, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.posts[id]; exists {
-		return nil, errors.New("post with this ID already exists")
+		return nil, ErrPostExists
 	}
 
 	post := &Post{
@@ -43,77 +71,406 @@ func (s *MemoryStore) CreatePost(id, title, content, author string, allowComment
 		CreatedAt:     time.Now(),
 		AllowComments: allowComments,
 	}
-	s.posts[id] = post
+	if err := s.writeLog(&logRecord{Op: opCreatePost, Post: post}); err != nil {
+		return nil, err
+	}
+	s.applyCreatePost(post)
+	s.maybeSnapshot()
 	return post, nil
 }
 
-// Получение копии постов с пагинацией
-// page — номер страницы, pageSize — количество постов на странице
-func (s *MemoryStore) GetPosts(page, pageSize int) ([]*Post, error) {
+// Получение страницы постов по фильтру в порядке order
+func (s *MemoryStore) FindPosts(ctx context.Context, filter PostFilter, order PostOrder, page PageQuery) (*Page[*Post], error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	posts := make([]*Post, 0, len(s.posts))
-	for _, post := range s.posts {
-		posts = append(posts, &Post{
-			ID:            post.ID,
-			Title:         post.Title,
-			Content:       post.Content,
-			Author:        post.Author,
-			CreatedAt:     post.CreatedAt,
-			AllowComments: post.AllowComments,
-		})
+	key := order.sortKey()
+	if filter.Deleted {
+		return seekPage(s.trashed(key), (*Post).Cursor, key, page, filter.match), nil
+	}
+	if key.byCount {
+		return seekPage(s.byComments, (*Post).Cursor, key, page, filter.match), nil
+	}
+	return seekPage(s.candidates(filter), (*Post).Cursor, key, page, filter.match), nil
+}
+
+// Количество постов по фильтру
+func (s *MemoryStore) CountPosts(ctx context.Context, filter PostFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := s.candidates(filter)
+	if filter.Deleted {
+		posts = s.trash
+	}
+	if filter.AllowComments == nil && !filter.Deleted {
+		return len(posts), nil
 	}
+	count := 0
+	for _, post := range posts {
+		if filter.match(post) {
+			count++
+		}
+	}
+	return count, nil
+}
 
-	start := (page - 1) * pageSize
-	if start >= len(posts) {
-		return []*Post{}, nil
+// trashed возвращает удалённые посты по возрастанию ключа key
+func (s *MemoryStore) trashed(key sortKey) []*Post {
+	if !key.byCount {
+		return s.trash
 	}
+	posts := slices.Clone(s.trash)
+	slices.SortFunc(posts, func(a, b *Post) int {
+		if key.less(a.Cursor(), b.Cursor()) {
+			return -1
+		}
+		return 1
+	})
+	return posts
+}
 
-	end := start + pageSize
-	if end > len(posts) {
-		end = len(posts)
+// candidates возвращает неудалённые посты от старых к новым, отобранные по автору
+// и времени создания. Остальные условия фильтра не проверяются
+func (s *MemoryStore) candidates(filter PostFilter) []*Post {
+	posts := s.postIndex
+	if filter.Author != nil {
+		posts = s.byAuthor[*filter.Author]
 	}
 
-	return posts[start:end], nil
+	// Посты упорядочены по времени создания, поэтому диапазон находится двоичным поиском
+	lo, hi := 0, len(posts)
+	if filter.CreatedAfter != nil {
+		lo = sort.Search(len(posts), func(i int) bool { return !posts[i].CreatedAt.Before(*filter.CreatedAfter) })
+	}
+	if filter.CreatedBefore != nil {
+		hi = sort.Search(len(posts), func(i int) bool { return !posts[i].CreatedAt.Before(*filter.CreatedBefore) })
+	}
+	if lo > hi {
+		return nil
+	}
+	return posts[lo:hi]
 }
 
 // Получение поста по ID
-func (s *MemoryStore) GetPostByID(id string) (*Post, error) {
+func (s *MemoryStore) GetPostByID(ctx context.Context, id string) (*Post, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	// Поиск поста в хранилище
-	post, exists := s.posts[id]
+	post, exists := s.visiblePost(id)
 	if !exists {
-		return nil, errors.New("post not found")
+		return nil, ErrPostNotFound
 	}
 	return post, nil
 }
 
+// Получение постов по ID
+func (s *MemoryStore) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := make(map[string]*Post, len(ids))
+	for _, id := range ids {
+		if post, exists := s.visiblePost(id); exists {
+			posts[id] = post
+		}
+	}
+	return posts, nil
+}
+
 // Обновление разрешения на комментарии
 // allowComments — разрешены ли комментарии к посту
-func (s *MemoryStore) UpdatePostCommentsPermission(postID string, allowComments bool) (*Post, error) {
+func (s *MemoryStore) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*Post, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Поиск поста в хранилище
-	post, exists := s.posts[postID]
+	post, exists := s.visiblePost(postID)
 	if !exists {
-		return nil, errors.New("post not found")
+		return nil, ErrPostNotFound
+	}
+
+	if err := s.writeLog(&logRecord{Op: opUpdateCommentsPermission, PostID: postID, AllowComments: allowComments}); err != nil {
+		return nil, err
 	}
 
 	// Обновление разрешения на комментарии
-	post.AllowComments = allowComments
+	post = s.applyUpdateCommentsPermission(post, allowComments)
+	s.maybeSnapshot()
 	return post, nil
 }
 
+// applyUpdateCommentsPermission меняет разрешение на комментарии к посту
+func (s *MemoryStore) applyUpdateCommentsPermission(post *Post, allowComments bool) *Post {
+	updated := *post
+	updated.AllowComments = allowComments
+	s.replacePost(&updated)
+	return &updated
+}
+
+// Изменение заголовка и текста поста
+// Прежние заголовок и текст сохраняются ревизией
+func (s *MemoryStore) UpdatePost(ctx context.Context, postID string, update PostUpdate) (*Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, exists := s.visiblePost(postID)
+	if !exists {
+		return nil, ErrPostNotFound
+	}
+	revision := &PostRevision{
+		PostID:   postID,
+		Number:   len(s.revisions[postID]) + 1,
+		Title:    post.Title,
+		Content:  post.Content,
+		Editor:   update.Editor,
+		EditedAt: time.Now(),
+	}
+
+	if err := s.writeLog(&logRecord{Op: opUpdatePost, PostID: postID, Update: &update, Revision: revision}); err != nil {
+		return nil, err
+	}
+	post = s.applyUpdatePost(post, update, revision)
+	s.maybeSnapshot()
+	return post, nil
+}
+
+// Получение ревизий поста
+func (s *MemoryStore) ListPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]*PostRevision{}, s.revisions[postID]...), nil
+}
+
+// applyUpdatePost заменяет заголовок и текст поста и запоминает ревизию с прежними
+func (s *MemoryStore) applyUpdatePost(post *Post, update PostUpdate, revision *PostRevision) *Post {
+	s.search.removePost(post)
+	updated := *post
+	updated.Title = update.Title
+	updated.Content = update.Content
+	updated.UpdatedAt = &revision.EditedAt
+	s.replacePost(&updated)
+	s.search.addPost(&updated)
+	s.revisions[post.ID] = append(s.revisions[post.ID], revision)
+	return &updated
+}
+
+// Удаление поста в корзину
+func (s *MemoryStore) DeletePost(ctx context.Context, postID string) (*Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, exists := s.visiblePost(postID)
+	if !exists {
+		return nil, ErrPostNotFound
+	}
+	deletedAt := time.Now()
+
+	if err := s.writeLog(&logRecord{Op: opDeletePost, PostID: postID, DeletedAt: &deletedAt}); err != nil {
+		return nil, err
+	}
+	post = s.applyDeletePost(post, deletedAt)
+	s.maybeSnapshot()
+	return post, nil
+}
+
+// Восстановление поста из корзины
+func (s *MemoryStore) RestorePost(ctx context.Context, postID string) (*Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, exists := s.posts[postID]
+	if !exists || post.DeletedAt == nil {
+		return nil, ErrPostNotFound
+	}
+
+	if err := s.writeLog(&logRecord{Op: opRestorePost, PostID: postID}); err != nil {
+		return nil, err
+	}
+	post = s.applyRestorePost(post)
+	s.maybeSnapshot()
+	return post, nil
+}
+
+// Окончательное удаление постов, удалённых в корзину раньше deletedBefore
+func (s *MemoryStore) PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for _, post := range s.trash {
+		if post.DeletedAt.Before(deletedBefore) {
+			ids = append(ids, post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if err := s.writeLog(&logRecord{Op: opPurgePosts, PostIDs: ids}); err != nil {
+		return 0, err
+	}
+	s.applyPurgePosts(ids)
+	s.maybeSnapshot()
+	return len(ids), nil
+}
+
+// visiblePost возвращает пост, если он есть и не удалён
+func (s *MemoryStore) visiblePost(id string) (*Post, bool) {
+	post, exists := s.posts[id]
+	if !exists || post.DeletedAt != nil {
+		return nil, false
+	}
+	return post, true
+}
+
+// visibleComment возвращает комментарий, если он есть и его пост не удалён
+func (s *MemoryStore) visibleComment(id string) (*Comment, bool) {
+	comment, exists := s.commentsByID[id]
+	if !exists {
+		return nil, false
+	}
+	if _, exists := s.visiblePost(comment.PostID); !exists {
+		return nil, false
+	}
+	return comment, true
+}
+
+// applyDeletePost переносит пост в корзину: пост и его комментарии
+// убираются из списков постов и поискового индекса
+func (s *MemoryStore) applyDeletePost(post *Post, deletedAt time.Time) *Post {
+	c := post.Cursor()
+	s.postIndex = removeSorted(s.postIndex, c, (*Post).Cursor, newestFirst)
+	s.byAuthor[post.Author] = removeSorted(s.byAuthor[post.Author], c, (*Post).Cursor, newestFirst)
+	s.byComments = removeSorted(s.byComments, c, (*Post).Cursor, PostsMostCommented.sortKey())
+	s.search.removePost(post)
+	for _, id := range s.commentOrder[post.ID] {
+		s.search.removeComment(s.commentsByID[id])
+	}
+
+	deleted := *post
+	deleted.DeletedAt = &deletedAt
+	s.posts[post.ID] = &deleted
+	s.trash = insertSorted(s.trash, &deleted, (*Post).Cursor, newestFirst)
+	return &deleted
+}
+
+// applyRestorePost возвращает пост из корзины
+func (s *MemoryStore) applyRestorePost(post *Post) *Post {
+	s.trash = removeSorted(s.trash, post.Cursor(), (*Post).Cursor, newestFirst)
+	restored := *post
+	restored.DeletedAt = nil
+	post = &restored
+	s.posts[post.ID] = post
+
+	s.postIndex = insertSorted(s.postIndex, post, (*Post).Cursor, newestFirst)
+	s.byAuthor[post.Author] = insertSorted(s.byAuthor[post.Author], post, (*Post).Cursor, newestFirst)
+	s.byComments = insertSorted(s.byComments, post, (*Post).Cursor, PostsMostCommented.sortKey())
+	s.search.addPost(post)
+	for _, id := range s.commentOrder[post.ID] {
+		s.search.addComment(s.commentsByID[id])
+	}
+	return post
+}
+
+// applyPurgePosts удаляет посты из корзины вместе с комментариями,
+// ревизиями и сходством с другими постами
+func (s *MemoryStore) applyPurgePosts(ids []string) {
+	purged := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		post, exists := s.posts[id]
+		if !exists || post.DeletedAt == nil {
+			continue
+		}
+		purged[id] = true
+		s.trash = removeSorted(s.trash, post.Cursor(), (*Post).Cursor, newestFirst)
+		delete(s.posts, id)
+
+		delete(s.threads, threadOf(id, nil))
+		for _, commentID := range s.commentOrder[id] {
+			delete(s.threads, threadOf(id, &commentID))
+			delete(s.commentsByID, commentID)
+			delete(s.edits, commentID)
+		}
+		delete(s.commentOrder, id)
+		delete(s.revisions, id)
+		for other := range s.related[id] {
+			delete(s.related[other], id)
+		}
+		delete(s.related, id)
+	}
+	s.postOrder = slices.DeleteFunc(s.postOrder, func(id string) bool { return purged[id] })
+}
+
+// Сохранение сходства поста с другими постами
+func (s *MemoryStore) AddRelatedPosts(ctx context.Context, postID string, related []RelatedScore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.posts[postID]; !exists {
+		return ErrPostNotFound
+	}
+	for _, r := range related {
+		if _, exists := s.posts[r.PostID]; !exists {
+			return ErrPostNotFound
+		}
+	}
+
+	if err := s.writeLog(&logRecord{Op: opAddRelatedPosts, PostID: postID, Related: related}); err != nil {
+		return err
+	}
+	s.applyAddRelatedPosts(postID, related)
+	s.maybeSnapshot()
+	return nil
+}
+
+// Получение похожих постов
+func (s *MemoryStore) ListRelatedPosts(ctx context.Context, postID string, limit int) ([]*RelatedPost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	related := make([]*RelatedPost, 0, len(s.related[postID]))
+	for id, score := range s.related[postID] {
+		if post, exists := s.visiblePost(id); exists {
+			related = append(related, &RelatedPost{Post: post, Score: score})
+		}
+	}
+	sortRelated(related)
+	if len(related) > limit {
+		related = related[:limit]
+	}
+	return related, nil
+}
+
+// applyAddRelatedPosts запоминает сходство постов в обе стороны
+func (s *MemoryStore) applyAddRelatedPosts(postID string, related []RelatedScore) {
+	link := func(from, to string, score float64) {
+		if s.related[from] == nil {
+			s.related[from] = make(map[string]float64)
+		}
+		s.related[from][to] = score
+	}
+	for _, r := range related {
+		link(postID, r.PostID, r.Score)
+		link(r.PostID, postID, r.Score)
+	}
+}
+
 // Создание комментария
 // parentID == nil — комментарий к посту
-func (s *MemoryStore) CreateComment(id, postID string, parentID *string, content string, author string) (*Comment, error) {
+func (s *MemoryStore) CreateComment(ctx context.Context, id, postID string, parentID *string, content string, author string) (*Comment, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.visiblePost(postID); !exists {
+		return nil, ErrPostNotFound
+	}
+	if _, exists := s.commentsByID[id]; exists {
+		return nil, ErrCommentExists
+	}
+
 	comment := &Comment{
 		ID:        id,
 		PostID:    postID,
@@ -122,127 +479,516 @@ func (s *MemoryStore) CreateComment(id, postID string, parentID *string, content
 		Author:    author,
 		CreatedAt: time.Now(),
 	}
+	if err := s.setThread(comment); err != nil {
+		return nil, err
+	}
+
+	if err := s.writeLog(&logRecord{Op: opCreateComment, Comment: comment}); err != nil {
+		return nil, err
+	}
 
 	// Запись комментария в хранилище
-	s.comments[postID] = append(s.comments[postID], comment)
+	s.applyCreateComment(comment)
+	s.maybeSnapshot()
 	return comment, nil
 }
 
-// Получение комментариев по ID поста
-// ParentID == nil — комментарий к посту
-func (s *MemoryStore) GetCommentsByPostID(postID string, page, pageSize int) ([]*Comment, error) {
+// applyCreatePost добавляет пост в хранилище
+func (s *MemoryStore) applyCreatePost(post *Post) {
+	s.posts[post.ID] = post
+	s.postOrder = append(s.postOrder, post.ID)
+	s.postIndex = insertSorted(s.postIndex, post, (*Post).Cursor, newestFirst)
+	s.byAuthor[post.Author] = insertSorted(s.byAuthor[post.Author], post, (*Post).Cursor, newestFirst)
+	s.byComments = insertSorted(s.byComments, post, (*Post).Cursor, PostsMostCommented.sortKey())
+	s.search.addPost(post)
+}
+
+// applyCreateComment добавляет комментарий в хранилище
+func (s *MemoryStore) applyCreateComment(comment *Comment) {
+	s.commentOrder[comment.PostID] = append(s.commentOrder[comment.PostID], comment.ID)
+	s.commentsByID[comment.ID] = comment
+
+	key := threadOf(comment.PostID, comment.ParentID)
+	s.threads[key] = insertSorted(s.threads[key], comment, (*Comment).Cursor, newestFirst)
+	s.search.addComment(comment)
+	s.updateCounters(comment, 1)
+}
+
+// updateCounters меняет на delta счётчики комментариев поста, ответов
+// у родителя comment и ответов у всех комментариев выше по ветке
+func (s *MemoryStore) updateCounters(comment *Comment, delta int) {
+	if comment.ParentID != nil {
+		parent := *s.commentsByID[*comment.ParentID]
+		parent.ReplyCount += delta
+		s.replaceComment(&parent)
+	}
+	for id := comment.ParentID; id != nil; {
+		ancestor := *s.commentsByID[*id]
+		ancestor.DescendantCount += delta
+		s.replaceComment(&ancestor)
+		id = ancestor.ParentID
+	}
+
+	post := *s.posts[comment.PostID]
+	if comment.ParentID == nil {
+		post.TopLevelCommentCount += delta
+	}
+	post.CommentCount += delta
+	s.replacePost(&post)
+}
+
+// replacePost заменяет сохранённый пост его изменённой копией post
+// Время создания, автор и признак удаления у копии те же
+func (s *MemoryStore) replacePost(post *Post) {
+	old := s.posts[post.ID]
+	s.posts[post.ID] = post
+	c := old.Cursor()
+	if old.DeletedAt != nil {
+		replaceSorted(s.trash, c, post, (*Post).Cursor, newestFirst)
+		return
+	}
+	replaceSorted(s.postIndex, c, post, (*Post).Cursor, newestFirst)
+	replaceSorted(s.byAuthor[post.Author], c, post, (*Post).Cursor, newestFirst)
+
+	// Пост меняет место в порядке по числу комментариев
+	byCount := PostsMostCommented.sortKey()
+	s.byComments = removeSorted(s.byComments, c, (*Post).Cursor, byCount)
+	s.byComments = insertSorted(s.byComments, post, (*Post).Cursor, byCount)
+}
+
+// replaceComment заменяет сохранённый комментарий его изменённой копией comment
+func (s *MemoryStore) replaceComment(comment *Comment) {
+	s.commentsByID[comment.ID] = comment
+	thread := s.threads[threadOf(comment.PostID, comment.ParentID)]
+	replaceSorted(thread, comment.Cursor(), comment, (*Comment).Cursor, newestFirst)
+}
+
+// threadKey — ветка: комментарии верхнего уровня к посту
+// (parentID == "") или ответы на комментарий parentID
+type threadKey struct {
+	postID   string
+	parentID string
+}
+
+func threadOf(postID string, parentID *string) threadKey {
+	if parentID == nil {
+		return threadKey{postID: postID}
+	}
+	return threadKey{postID: postID, parentID: *parentID}
+}
+
+// setThread вычисляет глубину и корень ветки комментария по родителю
+// Родитель должен существовать и относиться к тому же посту
+func (s *MemoryStore) setThread(comment *Comment) error {
+	if comment.ParentID == nil {
+		comment.Depth = 0
+		comment.RootID = comment.ID
+		return nil
+	}
+
+	parent, exists := s.commentsByID[*comment.ParentID]
+	if !exists || parent.PostID != comment.PostID {
+		return ErrInvalidParent
+	}
+	comment.Depth = parent.Depth + 1
+	comment.RootID = parent.RootID
+	return nil
+}
+
+// Получение комментария по ID
+func (s *MemoryStore) GetCommentByID(ctx context.Context, id string) (*Comment, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	var result []*Comment
 
-	// Применяем пагинацию
-	start := (page - 1) * pageSize
-	end := start + pageSize
-	// Гарантируем, что индексы в пределах массива
-	if start >= len(s.comments[postID]) {
-		return nil, errors.New("post not found") // Выход за границы массива
-	}
-	if end > len(s.comments[postID]) {
-		end = len(s.comments[postID])
+	comment, exists := s.visibleComment(id)
+	if !exists {
+		return nil, ErrCommentNotFound
 	}
+	return comment, nil
+}
 
-	// Формируем список комментариев к посту
-	for i := start; i < end; i++ {
-		c := s.comments[postID][i]
-		if c.ParentID == nil {
-			result = append(result, c)
+// Получение комментариев по ID
+func (s *MemoryStore) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := make(map[string]*Comment, len(ids))
+	for _, id := range ids {
+		if comment, exists := s.visibleComment(id); exists {
+			comments[id] = comment
 		}
 	}
+	return comments, nil
+}
 
-	return result, nil
+// Изменение текста комментария
+// Прежний текст сохраняется в истории правок
+func (s *MemoryStore) UpdateComment(ctx context.Context, id, content string) (*Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, exists := s.visibleComment(id)
+	if !exists || comment.DeletedAt != nil {
+		return nil, ErrCommentNotFound
+	}
+	edit := &CommentEdit{
+		CommentID: id,
+		Number:    len(s.edits[id]) + 1,
+		Content:   comment.Content,
+		EditedAt:  time.Now(),
+	}
+
+	if err := s.writeLog(&logRecord{Op: opUpdateComment, CommentID: id, Content: content, Edit: edit}); err != nil {
+		return nil, err
+	}
+	comment = s.applyUpdateComment(comment, content, edit)
+	s.maybeSnapshot()
+	return comment, nil
 }
 
-// Получение ответов на комментарий
-func (s *MemoryStore) GetCommentsByPostIDAndParentID(postID string, parentID *string, page, pageSize int) ([]*Comment, error) {
+// Получение правок комментариев
+func (s *MemoryStore) ListCommentEdits(ctx context.Context, commentIDs []string) (map[string][]*CommentEdit, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	var filtered []*Comment
+	edits := make(map[string][]*CommentEdit, len(commentIDs))
+	for _, id := range commentIDs {
+		if list := s.edits[id]; len(list) > 0 {
+			edits[id] = append([]*CommentEdit{}, list...)
+		}
+	}
+	return edits, nil
+}
+
+// applyUpdateComment заменяет текст комментария и запоминает прежний
+func (s *MemoryStore) applyUpdateComment(comment *Comment, content string, edit *CommentEdit) *Comment {
+	s.search.removeComment(comment)
+	updated := *comment
+	updated.Content = content
+	updated.EditedAt = &edit.EditedAt
+	s.replaceComment(&updated)
+	s.search.addComment(&updated)
+	s.edits[comment.ID] = append(s.edits[comment.ID], edit)
+	return &updated
+}
+
+// Удаление комментария
+// Комментарий с ответами остаётся в ветке без текста и автора
+func (s *MemoryStore) DeleteComment(ctx context.Context, id, reason string) (*Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// Отфильтруем комментарии по parentID
-	for _, c := range s.comments[postID] {
-		if (parentID == nil && c.ParentID == nil) ||
-			(parentID != nil && c.ParentID != nil && *c.ParentID == *parentID) {
-			filtered = append(filtered, c)
+	comment, exists := s.visibleComment(id)
+	if !exists || comment.DeletedAt != nil {
+		return nil, ErrCommentNotFound
+	}
+	deletedAt := time.Now()
+
+	if err := s.writeLog(&logRecord{Op: opDeleteComment, CommentID: id, DeletedAt: &deletedAt, Reason: reason}); err != nil {
+		return nil, err
+	}
+	kept := s.applyDeleteComment(comment, deletedAt, reason)
+	s.maybeSnapshot()
+	return kept, nil
+}
+
+// applyDeleteComment удаляет комментарий и возвращает его, если он остался в ветке
+func (s *MemoryStore) applyDeleteComment(comment *Comment, deletedAt time.Time, reason string) *Comment {
+	if comment.ReplyCount > 0 {
+		s.search.removeComment(comment)
+		kept := *comment
+		kept.Content = DeletedPlaceholder
+		kept.Author = DeletedPlaceholder
+		kept.EditedAt = nil
+		kept.DeletedAt = &deletedAt
+		kept.DeleteReason = reason
+		s.replaceComment(&kept)
+		delete(s.edits, comment.ID)
+		return &kept
+	}
+
+	// Удалённые комментарии без ответов в ветке больше не нужны
+	for comment != nil {
+		parent := s.dropComment(comment)
+		if parent == nil || parent.DeletedAt == nil || parent.ReplyCount > 0 {
+			break
 		}
+		comment = parent
+	}
+	return nil
+}
+
+// dropComment удаляет комментарий без ответов и возвращает его родителя
+func (s *MemoryStore) dropComment(comment *Comment) *Comment {
+	s.commentOrder[comment.PostID] = slices.DeleteFunc(s.commentOrder[comment.PostID], func(id string) bool { return id == comment.ID })
+	delete(s.commentsByID, comment.ID)
+	delete(s.threads, threadOf(comment.PostID, &comment.ID))
+	delete(s.edits, comment.ID)
+	key := threadOf(comment.PostID, comment.ParentID)
+	s.threads[key] = removeSorted(s.threads[key], comment.Cursor(), (*Comment).Cursor, newestFirst)
+	s.search.removeComment(comment)
+	s.updateCounters(comment, -1)
+
+	if comment.ParentID == nil {
+		return nil
 	}
+	return s.commentsByID[*comment.ParentID]
+}
 
-	// Применим пагинацию после фильтрации
-	start := (page - 1) * pageSize
-	end := start + pageSize
+// writeLog записывает изменение в журнал, если данные сохраняются на диск
+// Вызывается под блокировкой до изменения состояния в памяти
+func (s *MemoryStore) writeLog(record *logRecord) error {
+	if s.wal == nil {
+		return nil
+	}
+	if err := s.wal.append(record); err != nil {
+		return fmt.Errorf("could not write log: %w", err)
+	}
+	return nil
+}
 
-	// Гарантируем, что индексы в пределах массива
-	if start >= len(filtered) {
-		return nil, errors.New("post not found") // Выход за границы массива
+// maybeSnapshot сжимает журнал в снимок, когда в нём накопилось
+// достаточно записей. Вызывается под блокировкой
+func (s *MemoryStore) maybeSnapshot() {
+	if s.wal == nil || !s.wal.needsSnapshot() {
+		return
 	}
-	if end > len(filtered) {
-		end = len(filtered)
+	// Изменение уже сохранено в журнале, поэтому ошибка снимка
+	// не отменяет его: снимок будет повторён после следующей записи
+	if err := s.snapshot(); err != nil {
+		log.Printf("Warning: could not write snapshot: %v", err)
 	}
+}
 
-	return filtered[start:end], nil
+// snapshot записывает текущее состояние в снимок и очищает журнал
+// Вызывается под блокировкой
+func (s *MemoryStore) snapshot() error {
+	snap := &snapshot{
+		Posts:            make([]*Post, 0, len(s.postOrder)),
+		Comments:         make(map[string][]*Comment, len(s.commentOrder)),
+		Related:          make(map[string][]RelatedScore, len(s.related)),
+		Revisions:        s.revisions,
+		Edits:            s.edits,
+		PersistedQueries: make([]*PersistedQuery, 0, len(s.persisted)),
+	}
+	for _, id := range s.postOrder {
+		snap.Posts = append(snap.Posts, s.posts[id])
+	}
+	for postID, ids := range s.commentOrder {
+		comments := make([]*Comment, 0, len(ids))
+		for _, id := range ids {
+			comments = append(comments, s.commentsByID[id])
+		}
+		snap.Comments[postID] = comments
+	}
+	for id, related := range s.related {
+		for other, score := range related {
+			snap.Related[id] = append(snap.Related[id], RelatedScore{PostID: other, Score: score})
+		}
+	}
+	for _, query := range s.persisted {
+		snap.PersistedQueries = append(snap.PersistedQueries, query)
+	}
+	return s.wal.compact(snap)
 }
 
-// Subscribe — добавляет подписчика
-func (s *MemoryStore) Subscribe(postID string) (<-chan *Comment, func()) {
+// Close сохраняет снимок и закрывает журнал
+// Для хранилища без сохранения на диск ничего не делает
+func (s *MemoryStore) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Создаём новый канал для подписчика
-	ch := make(chan *Comment, 1)
-	s.subscribers[postID] = append(s.subscribers[postID], ch)
+	if s.wal == nil {
+		return nil
+	}
+	err := s.snapshot()
+	if closeErr := s.wal.close(); err == nil {
+		err = closeErr
+	}
+	s.wal = nil
+	return err
+}
+
+// Получение страницы комментариев верхнего уровня или ответов на parentID
+func (s *MemoryStore) ListComments(ctx context.Context, postID string, parentID *string, page PageQuery) (*Page[*Comment], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return seekPage(s.threads[threadOf(postID, parentID)], (*Comment).Cursor, newestFirst, page, nil), nil
+}
+
+// Количество комментариев верхнего уровня или ответов на parentID
+func (s *MemoryStore) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.threads[threadOf(postID, parentID)]), nil
+}
+
+// Получение страниц комментариев верхнего уровня к нескольким постам
+func (s *MemoryStore) ListCommentsByPostIDs(ctx context.Context, postIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make(map[string]*Page[*Comment], len(postIDs))
+	for _, id := range postIDs {
+		pages[id] = seekPage(s.threads[threadOf(id, nil)], (*Comment).Cursor, newestFirst, page, nil)
+	}
+	return pages, nil
+}
+
+// Получение страниц ответов на несколько комментариев
+func (s *MemoryStore) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pages := make(map[string]*Page[*Comment], len(parentIDs))
+	for _, id := range parentIDs {
+		pages[id] = seekPage(s.replies(id), (*Comment).Cursor, newestFirst, page, nil)
+	}
+	return pages, nil
+}
+
+// Количество комментариев верхнего уровня к нескольким постам
+func (s *MemoryStore) CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int, len(postIDs))
+	for _, id := range postIDs {
+		counts[id] = len(s.threads[threadOf(id, nil)])
+	}
+	return counts, nil
+}
+
+// Количество ответов на несколько комментариев
+func (s *MemoryStore) CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int, len(parentIDs))
+	for _, id := range parentIDs {
+		counts[id] = len(s.replies(id))
+	}
+	return counts, nil
+}
 
-	// Функция для отписки
-	unsubscribe := func() {
-		s.mu.Lock()
-		defer s.mu.Unlock()
+// Получение ветки комментариев по индексу ответов threads
+func (s *MemoryStore) CommentTree(ctx context.Context, postID string, tree TreeQuery) ([]*Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-		channels := s.subscribers[postID]
-		for i, c := range channels {
-			if c == ch {
-				s.subscribers[postID] = append(channels[:i], channels[i+1:]...)
-				if !isClosed(c) {
-					close(c)
-				}
-				break
-			}
+	newest := func(thread []*Comment) []*Comment {
+		return seekPage(thread, (*Comment).Cursor, newestFirst, PageQuery{First: tree.LimitPerLevel}, nil).Items
+	}
+	start := newest(s.threads[threadOf(postID, nil)])
+	startDepth := 0
+	if tree.RootID != nil {
+		root, exists := s.visibleComment(*tree.RootID)
+		if !exists || root.PostID != postID {
+			return nil, ErrCommentNotFound
 		}
+		start, startDepth = []*Comment{root}, root.Depth
 	}
 
-	return ch, unsubscribe
+	return flattenTree(start, func(id string) []*Comment {
+		parent := s.commentsByID[id]
+		if parent.Depth-startDepth >= tree.MaxDepth {
+			return nil
+		}
+		return newest(s.threads[threadOf(postID, &id)])
+	}), nil
 }
 
-// isClosed проверяет, закрыт ли канал
-func isClosed(ch chan *Comment) bool {
-	select {
-	case <-ch:
-		return true // Канал закрыт
-	default:
-		return false // Канал открыт
+// replies возвращает ответы на комментарий parentID от старых к новым
+func (s *MemoryStore) replies(parentID string) []*Comment {
+	parent, exists := s.commentsByID[parentID]
+	if !exists {
+		return nil
+	}
+	return s.threads[threadOf(parent.PostID, &parentID)]
+}
+
+// Поиск постов и комментариев по словам запроса
+func (s *MemoryStore) Search(ctx context.Context, query SearchQuery, page PageQuery) (*Page[*SearchHit], error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	terms := searchTerms(query.Text)
+	scores := s.search.match(terms)
+	hits := make([]*SearchHit, 0, len(scores))
+	for doc, score := range scores {
+		if !query.includes(doc.kind) {
+			continue
+		}
+		hit := &SearchHit{Score: score}
+		if doc.kind == SearchPosts {
+			hit.Post = s.posts[doc.id]
+		} else {
+			hit.Comment = s.commentsByID[doc.id]
+		}
+		hits = append(hits, hit)
+	}
+	slices.SortFunc(hits, func(a, b *SearchHit) int {
+		if searchOrder.less(a.Cursor(), b.Cursor()) {
+			return -1
+		}
+		return 1
+	})
+
+	// Фрагменты текста нужны только для выданной страницы
+	result := seekPage(hits, (*SearchHit).Cursor, searchOrder, page, nil)
+	for _, hit := range result.Items {
+		if hit.Post != nil {
+			hit.Snippet = snippet(hit.Post.Title+" "+hit.Post.Content, terms)
+		} else {
+			hit.Snippet = snippet(hit.Comment.Content, terms)
+		}
 	}
+	return result, nil
 }
 
-// Publish — отправляет новый комментарий подписчикам
-func (s *MemoryStore) Publish(comment *Comment) {
+// Сохранение разрешённых операций
+func (s *MemoryStore) SavePersistedQueries(ctx context.Context, queries []*PersistedQuery) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Поиск подписчиков поста
-	channels, exists := s.subscribers[comment.PostID]
+	if len(queries) == 0 {
+		return nil
+	}
+	if err := s.writeLog(&logRecord{Op: opSavePersistedQueries, Queries: queries}); err != nil {
+		return err
+	}
+	s.applySavePersistedQueries(queries)
+	s.maybeSnapshot()
+	return nil
+}
+
+// Получение разрешённой операции по хешу
+func (s *MemoryStore) GetPersistedQuery(ctx context.Context, hash string) (*PersistedQuery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query, exists := s.persisted[hash]
 	if !exists {
-		return
+		return nil, ErrPersistedQueryNotFound
 	}
+	return query, nil
+}
 
-	// Рассылаем комментарий всем подписчикам
-	for _, ch := range channels {
-		select {
-		case ch <- comment: // Если клиент готов читать, отправляем
-		default: // Если клиент не читает, пропускаем
-			fmt.Printf("Warning: comment %s was not delivered to a subscriber\n", comment.ID)
-		}
+// applySavePersistedQueries запоминает операции по хешам
+func (s *MemoryStore) applySavePersistedQueries(queries []*PersistedQuery) {
+	for _, query := range queries {
+		s.persisted[query.Hash] = query
 	}
 }
+
+// Subscribe — добавляет подписчика
+func (s *MemoryStore) Subscribe(ctx context.Context, postID string) (<-chan *CommentEvent, func()) {
+	return s.events.Subscribe(postID)
+}
+
+// Publish — отправляет событие о комментарии подписчикам
+func (s *MemoryStore) Publish(ctx context.Context, event *CommentEvent) {
+	s.events.Publish(event)
+}
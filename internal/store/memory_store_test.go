@@ -1,16 +1,20 @@
 package store_test
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/SobolevTim/t-graphql/internal/store/storetest"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCreatePost(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	post, err := memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	post, err := memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 	assert.NoError(t, err)
 	assert.NotNil(t, post)
 	assert.Equal(t, "1", post.ID)
@@ -20,41 +24,45 @@ func TestCreatePost(t *testing.T) {
 	assert.True(t, post.AllowComments)
 }
 
-func TestGetPosts(t *testing.T) {
+func TestFindPosts(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title 1", "Test Content 1", "Author 1", true)
-	memStore.CreatePost("2", "Test Title 2", "Test Content 2", "Author 2", true)
+	memStore.CreatePost(ctx, "1", "Test Title 1", "Test Content 1", "Author 1", true)
+	memStore.CreatePost(ctx, "2", "Test Title 2", "Test Content 2", "Author 2", true)
 
-	posts, err := memStore.GetPosts(1, 10)
+	posts, err := memStore.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
 	assert.NoError(t, err)
-	assert.Len(t, posts, 2)
+	assert.Len(t, posts.Items, 2)
 }
 
 func TestGetPostByID(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 
-	post, err := memStore.GetPostByID("1")
+	post, err := memStore.GetPostByID(ctx, "1")
 	assert.NoError(t, err)
 	assert.NotNil(t, post)
 	assert.Equal(t, "1", post.ID)
 }
 
 func TestUpdatePostCommentsPermission(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 
-	post, err := memStore.UpdatePostCommentsPermission("1", false)
+	post, err := memStore.UpdatePostCommentsPermission(ctx, "1", false)
 	assert.NoError(t, err)
 	assert.NotNil(t, post)
 	assert.False(t, post.AllowComments)
 }
 
 func TestCreateComment(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 
-	comment, err := memStore.CreateComment("1", "1", nil, "Test Comment", "Comment Author")
+	comment, err := memStore.CreateComment(ctx, "1", "1", nil, "Test Comment", "Comment Author")
 	assert.NoError(t, err)
 	assert.NotNil(t, comment)
 	assert.Equal(t, "1", comment.ID)
@@ -64,38 +72,37 @@ func TestCreateComment(t *testing.T) {
 	assert.Equal(t, "Comment Author", comment.Author)
 }
 
-func TestGetCommentsByPostID(t *testing.T) {
+func TestListComments(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
-	memStore.CreateComment("1", "1", nil, "Test Comment 1", "Comment Author 1")
-	memStore.CreateComment("2", "1", nil, "Test Comment 2", "Comment Author 2")
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
+	memStore.CreateComment(ctx, "1", "1", nil, "Test Comment 1", "Comment Author 1")
+	memStore.CreateComment(ctx, "2", "1", nil, "Test Comment 2", "Comment Author 2")
 
-	page := 1
-	pageSize := 10
-	comments, err := memStore.GetCommentsByPostID("1", page, pageSize)
+	comments, err := memStore.ListComments(ctx, "1", nil, store.PageQuery{First: 10})
 	assert.NoError(t, err)
-	assert.Len(t, comments, 2)
+	assert.Len(t, comments.Items, 2)
 }
 
-func TestGetCommentsByPostIDAndParentID(t *testing.T) {
+func TestListReplies(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 	parentID := "1"
-	memStore.CreateComment("1", "1", nil, "Test Comment 1", "Comment Author 1")
-	memStore.CreateComment("2", "1", &parentID, "Test Reply", "Reply Author")
+	memStore.CreateComment(ctx, "1", "1", nil, "Test Comment 1", "Comment Author 1")
+	memStore.CreateComment(ctx, "2", "1", &parentID, "Test Reply", "Reply Author")
 
-	page := 1
-	pageSize := 10
-	comments, err := memStore.GetCommentsByPostIDAndParentID("1", &parentID, page, pageSize)
+	comments, err := memStore.ListComments(ctx, "1", &parentID, store.PageQuery{First: 10})
 	assert.NoError(t, err)
-	assert.Len(t, comments, 1)
+	assert.Len(t, comments.Items, 1)
 }
 
 func TestSubscribeAndPublish(t *testing.T) {
+	ctx := context.Background()
 	memStore := store.NewMemoryStore()
-	memStore.CreatePost("1", "Test Title", "Test Content", "Author", true)
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
 
-	ch, unsubscribe := memStore.Subscribe("1")
+	ch, unsubscribe := memStore.Subscribe(ctx, "1")
 	defer unsubscribe()
 
 	comment := &store.Comment{
@@ -106,10 +113,106 @@ func TestSubscribeAndPublish(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 
-	go memStore.Publish(comment)
+	go memStore.Publish(ctx, store.NewCommentEvent("event1", store.EventCommentAdded, comment))
 
-	receivedComment := <-ch
-	assert.NotNil(t, receivedComment)
-	assert.Equal(t, "1", receivedComment.ID)
-	assert.Equal(t, "Test Comment", receivedComment.Content)
+	receivedEvent := <-ch
+	assert.NotNil(t, receivedEvent)
+	assert.Equal(t, "event1", receivedEvent.ID)
+	assert.Equal(t, "1", receivedEvent.Comment.ID)
+	assert.Equal(t, "Test Comment", receivedEvent.Comment.Content)
+}
+
+// TestReturnedValuesNotModified проверяет, что выданные хранилищем посты
+// и комментарии не меняются при последующих изменениях: их читают без блокировки
+func TestReturnedValuesNotModified(t *testing.T) {
+	ctx := context.Background()
+	memStore := store.NewMemoryStore()
+	post, err := memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
+	assert.NoError(t, err)
+	parent, err := memStore.CreateComment(ctx, "c1", "1", nil, "Test Comment", "Author")
+	assert.NoError(t, err)
+	parentID := "c1"
+	_, err = memStore.CreateComment(ctx, "c2", "1", &parentID, "Test Reply", "Author")
+	assert.NoError(t, err)
+	_, err = memStore.UpdatePostCommentsPermission(ctx, "1", false)
+	assert.NoError(t, err)
+	_, err = memStore.UpdatePost(ctx, "1", store.PostUpdate{Title: "New Title", Content: "New Content", Editor: "Author"})
+	assert.NoError(t, err)
+	deleted, err := memStore.DeletePost(ctx, "1")
+	assert.NoError(t, err)
+	_, err = memStore.RestorePost(ctx, "1")
+	assert.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+	edited, err := memStore.UpdateComment(ctx, "c1", "Edited Comment")
+	assert.NoError(t, err)
+	_, err = memStore.DeleteComment(ctx, "c1", store.DeletedAsSpam)
+	assert.NoError(t, err)
+	assert.Equal(t, "Edited Comment", edited.Content)
+	assert.Nil(t, edited.DeletedAt)
+
+	assert.Zero(t, post.CommentCount)
+	assert.True(t, post.AllowComments)
+	assert.Equal(t, "Test Title", post.Title)
+	assert.Nil(t, post.UpdatedAt)
+	assert.Nil(t, post.DeletedAt)
+	assert.Zero(t, parent.ReplyCount)
+	assert.Zero(t, parent.DescendantCount)
+	assert.Equal(t, "Test Comment", parent.Content)
+	assert.Equal(t, "Author", parent.Author)
+	assert.Nil(t, parent.EditedAt)
+	assert.Nil(t, parent.DeletedAt)
+
+	post, err = memStore.GetPostByID(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, post.CommentCount)
+	assert.Equal(t, 1, post.TopLevelCommentCount)
+	assert.False(t, post.AllowComments)
+	assert.Equal(t, "New Title", post.Title)
+	assert.NotNil(t, post.UpdatedAt)
+	assert.Nil(t, post.DeletedAt)
+	parent, err = memStore.GetCommentByID(ctx, "c1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, parent.ReplyCount)
+	assert.Equal(t, 1, parent.DescendantCount)
+	assert.Equal(t, store.DeletedPlaceholder, parent.Content)
+	assert.NotNil(t, parent.DeletedAt)
+}
+
+// TestConcurrentReadsAndWrites читает выданные значения во время записи
+// Гонки в нём находит go test -race
+func TestConcurrentReadsAndWrites(t *testing.T) {
+	ctx := context.Background()
+	memStore := store.NewMemoryStore()
+	memStore.CreatePost(ctx, "1", "Test Title", "Test Content", "Author", true)
+	memStore.CreateComment(ctx, "c0", "1", nil, "Test Comment", "Author")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		parentID := "c0"
+		for i := 1; i <= 100; i++ {
+			memStore.CreateComment(ctx, fmt.Sprintf("c%d", i), "1", &parentID, "Test Reply", "Author")
+		}
+	}()
+
+	for {
+		comments, err := memStore.ListComments(ctx, "1", nil, store.PageQuery{First: 10})
+		assert.NoError(t, err)
+		post, err := memStore.GetPostByID(ctx, "1")
+		assert.NoError(t, err)
+		// Пост прочитан позже, поэтому в CommentCount учтены c0 и все прочитанные ответы
+		assert.Less(t, comments.Items[0].ReplyCount, post.CommentCount)
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// TestMemoryStoreConformance прогоняет общий набор тестов хранилища
+func TestMemoryStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return store.NewMemoryStore()
+	})
 }
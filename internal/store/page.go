@@ -0,0 +1,306 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursor — позиция записи в выдаче
+// Записи упорядочены по (CreatedAt, ID), а при сортировке постов
+// по числу комментариев — по (Count, CreatedAt, ID), поэтому позиция
+// не сдвигается, когда появляются новые записи. Результаты поиска
+// упорядочены по (Score, CreatedAt, ID)
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+	Count     int     // Число комментариев поста; у комментариев всегда 0
+	Score     float64 // Релевантность результата поиска; вне поиска всегда 0
+}
+
+// Cursor возвращает позицию поста в выдаче
+func (p *Post) Cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID, Count: p.CommentCount}
+}
+
+// Cursor возвращает позицию комментария в выдаче
+func (c *Comment) Cursor() Cursor {
+	return Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+}
+
+// olderThan сообщает, создана ли запись с позицией c раньше other
+func (c Cursor) olderThan(other Cursor) bool {
+	if !c.CreatedAt.Equal(other.CreatedAt) {
+		return c.CreatedAt.Before(other.CreatedAt)
+	}
+	return c.ID < other.ID
+}
+
+// sortKey — ключ сортировки выдачи
+type sortKey struct {
+	byCount bool // Сначала по числу комментариев, затем по (CreatedAt, ID)
+	byScore bool // Сначала по релевантности, затем по (CreatedAt, ID)
+	desc    bool // По убыванию ключа
+}
+
+// newestFirst — порядок по умолчанию: от новых к старым
+var newestFirst = sortKey{desc: true}
+
+// less сравнивает ключи позиций a и b по возрастанию
+func (k sortKey) less(a, b Cursor) bool {
+	if k.byCount && a.Count != b.Count {
+		return a.Count < b.Count
+	}
+	if k.byScore && a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.olderThan(b)
+}
+
+// PageQuery — запрос страницы в стиле Relay
+// First выбирает первые записи после After, Last — последние записи перед Before.
+// Если задан Last, страница выбирается с конца, иначе с начала
+type PageQuery struct {
+	First  int
+	After  *Cursor // Выдавать только записи, идущие после курсора
+	Last   int
+	Before *Cursor // Выдавать только записи, идущие до курсора
+}
+
+// backward сообщает, выбирается ли страница с конца
+func (q PageQuery) backward() bool {
+	return q.Last > 0
+}
+
+// limit возвращает размер страницы
+func (q PageQuery) limit() int {
+	if q.backward() {
+		return q.Last
+	}
+	return q.First
+}
+
+// Page — страница записей в порядке выдачи
+type Page[T any] struct {
+	Items           []T
+	HasNextPage     bool // Есть записи после последней
+	HasPreviousPage bool // Есть записи до первой
+}
+
+// newPage собирает страницу из выбранных записей
+// Хранилище выбирает на одну запись больше размера страницы: по ней
+// видно, есть ли следующая страница. При выборке с конца записи
+// идут в обратном порядке и разворачиваются здесь
+func newPage[T any](items []T, q PageQuery) *Page[T] {
+	page := &Page[T]{}
+	more := len(items) > q.limit()
+	if more {
+		items = items[:q.limit()]
+	}
+
+	if q.backward() {
+		slices.Reverse(items)
+		page.HasPreviousPage = more
+		page.HasNextPage = q.Before != nil
+	} else {
+		page.HasNextPage = more
+		page.HasPreviousPage = q.After != nil
+	}
+
+	if items == nil {
+		items = []T{}
+	}
+	page.Items = items
+	return page
+}
+
+// groupPages собирает страницы для каждого ID из записей, выбранных
+// пакетным запросом и сгруппированных по ID
+func groupPages[T any](ids []string, groups map[string][]T, q PageQuery) map[string]*Page[T] {
+	pages := make(map[string]*Page[T], len(ids))
+	for _, id := range ids {
+		pages[id] = newPage(groups[id], q)
+	}
+	return pages
+}
+
+// seekPage выбирает страницу из записей, упорядоченных по возрастанию ключа key
+// Границы страницы находятся двоичным поиском по курсорам. Если match
+// не nil, записи, которые ему не подходят, пропускаются
+func seekPage[T any](items []T, cursor func(T) Cursor, key sortKey, q PageQuery, match func(T) bool) *Page[T] {
+	after := func(c Cursor) int { // Первая запись с ключом больше c
+		return sort.Search(len(items), func(i int) bool { return key.less(c, cursor(items[i])) })
+	}
+	notBefore := func(c Cursor) int { // Первая запись с ключом не меньше c
+		return sort.Search(len(items), func(i int) bool { return !key.less(cursor(items[i]), c) })
+	}
+
+	// Записи между After и Before лежат в items[lo:hi]
+	lo, hi := 0, len(items)
+	if q.After != nil {
+		if key.desc {
+			hi = notBefore(*q.After)
+		} else {
+			lo = after(*q.After)
+		}
+	}
+	if q.Before != nil {
+		if key.desc {
+			lo = after(*q.Before)
+		} else {
+			hi = notBefore(*q.Before)
+		}
+	}
+
+	// Выдача идёт по убыванию индекса, если ключ убывает, а страница
+	// выбирается с начала, или если ключ возрастает, а страница — с конца
+	down := key.desc != q.backward()
+	selected := make([]T, 0, min(q.limit()+1, max(hi-lo, 0)))
+	for n := 0; n < hi-lo && len(selected) <= q.limit(); n++ {
+		i := lo + n
+		if down {
+			i = hi - 1 - n
+		}
+		if match == nil || match(items[i]) {
+			selected = append(selected, items[i])
+		}
+	}
+	return newPage(selected, q)
+}
+
+// insertSorted вставляет запись в список, упорядоченный по возрастанию ключа key
+// Новые записи обычно добавляются в конец
+func insertSorted[T any](items []T, item T, cursor func(T) Cursor, key sortKey) []T {
+	c := cursor(item)
+	i := len(items)
+	if i > 0 && key.less(c, cursor(items[i-1])) {
+		i = sort.Search(len(items), func(i int) bool { return key.less(c, cursor(items[i])) })
+	}
+	return slices.Insert(items, i, item)
+}
+
+// removeSorted удаляет запись с позицией c из списка, упорядоченного по ключу key
+func removeSorted[T any](items []T, c Cursor, cursor func(T) Cursor, key sortKey) []T {
+	i := sort.Search(len(items), func(i int) bool { return !key.less(cursor(items[i]), c) })
+	if i < len(items) && cursor(items[i]) == c {
+		return slices.Delete(items, i, i+1)
+	}
+	return items
+}
+
+// replaceSorted заменяет запись с позицией c в списке, упорядоченном по ключу key,
+// записью item. Место item в порядке должно совпадать с местом прежней записи
+func replaceSorted[T any](items []T, c Cursor, item T, cursor func(T) Cursor, key sortKey) {
+	i := sort.Search(len(items), func(i int) bool { return !key.less(cursor(items[i]), c) })
+	if i < len(items) && cursor(items[i]) == c {
+		items[i] = item
+	}
+}
+
+// sqlDialect — различия SQL хранилищ, важные для выборки страниц
+type sqlDialect struct {
+	placeholder func(n int) string  // Параметр запроса с номером n
+	timeValue   func(time.Time) any // Значение времени в формате столбца created_at
+}
+
+var postgresDialect = sqlDialect{
+	placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+	timeValue:   func(t time.Time) any { return t },
+}
+
+var sqliteDialect = sqlDialect{
+	placeholder: func(int) string { return "?" },
+	timeValue:   func(t time.Time) any { return t.UnixNano() },
+}
+
+// pageClause дополняет условия conds и аргументы args выборкой страницы q
+// в порядке key. Возвращает хвост запроса: WHERE, ORDER BY и LIMIT
+func (d sqlDialect) pageClause(key sortKey, q PageQuery, conds []string, args []any) (string, []any) {
+	conds, args = d.seekConditions(key, q, conds, args)
+	clause := whereClause(conds) + " ORDER BY " + key.orderBy(q)
+	// Лишняя запись показывает, есть ли следующая страница
+	args = append(args, q.limit()+1)
+	clause += " LIMIT " + d.placeholder(len(args))
+
+	return clause, args
+}
+
+// batchPageQuery строит запрос страниц q сразу для нескольких групп записей
+// Группу задаёт столбец partition, в каждой группе выбирается на одну запись
+// больше размера страницы. Записи упорядочены по группам, внутри группы — как
+// в pageClause
+func (d sqlDialect) batchPageQuery(columns, table, partition string, key sortKey, q PageQuery, conds []string, args []any) (string, []any) {
+	conds, args = d.seekConditions(key, q, conds, args)
+	args = append(args, q.limit()+1)
+	query := fmt.Sprintf(`
+		SELECT %s FROM (
+			SELECT %s, ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) AS page_row
+			FROM %s%s
+		) AS batch
+		WHERE page_row <= %s
+		ORDER BY %s, page_row`,
+		columns, columns, partition, key.orderBy(q), table, whereClause(conds), d.placeholder(len(args)), partition)
+	return query, args
+}
+
+// seekConditions дополняет условия conds и аргументы args границами After и Before
+func (d sqlDialect) seekConditions(key sortKey, q PageQuery, conds []string, args []any) ([]string, []any) {
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.placeholder(len(args))
+	}
+	values := func(c Cursor) string {
+		return arg(d.timeValue(c.CreatedAt)) + ", " + arg(c.ID)
+	}
+	if key.byCount {
+		values = func(c Cursor) string {
+			return arg(c.Count) + ", " + arg(d.timeValue(c.CreatedAt)) + ", " + arg(c.ID)
+		}
+	}
+	if key.byScore {
+		values = func(c Cursor) string {
+			return arg(c.Score) + ", " + arg(d.timeValue(c.CreatedAt)) + ", " + arg(c.ID)
+		}
+	}
+
+	// После курсора идут записи с меньшим ключом, если ключ убывает
+	afterOp, beforeOp := ">", "<"
+	if key.desc {
+		afterOp, beforeOp = "<", ">"
+	}
+	if q.After != nil {
+		conds = append(conds, fmt.Sprintf("(%s) %s (%s)", key.columns(), afterOp, values(*q.After)))
+	}
+	if q.Before != nil {
+		conds = append(conds, fmt.Sprintf("(%s) %s (%s)", key.columns(), beforeOp, values(*q.Before)))
+	}
+	return conds, args
+}
+
+// columns возвращает столбцы ключа сортировки
+func (k sortKey) columns() string {
+	switch {
+	case k.byCount:
+		return "comment_count, created_at, id"
+	case k.byScore:
+		return "score, created_at, id"
+	default:
+		return "created_at, id"
+	}
+}
+
+// orderBy возвращает порядок выборки страницы q без слов ORDER BY
+func (k sortKey) orderBy(q PageQuery) string {
+	direction := " ASC"
+	if k.desc != q.backward() {
+		direction = " DESC"
+	}
+	order := strings.Split(k.columns(), ", ")
+	for i := range order {
+		order[i] += direction
+	}
+	return strings.Join(order, ", ")
+}
@@ -0,0 +1,8 @@
+package store
+
+// PersistedQuery — операция GraphQL из списка разрешённых
+type PersistedQuery struct {
+	Hash  string `json:"hash"`  // SHA-256 текста операции в hex
+	Name  string `json:"name"`  // Имя операции из манифеста
+	Query string `json:"query"` // Текст операции
+}
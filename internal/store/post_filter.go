@@ -0,0 +1,94 @@
+package store
+
+import (
+	"strings"
+	"time"
+)
+
+// PostFilter — условия выборки постов
+// Незаданные (nil) условия выборку не ограничивают
+type PostFilter struct {
+	Author        *string    // Автор поста
+	CreatedAfter  *time.Time // Посты, созданные не раньше этого времени
+	CreatedBefore *time.Time // Посты, созданные раньше этого времени
+	AllowComments *bool      // Открыты ли комментарии
+	Deleted       bool       // Только удалённые посты вместо неудалённых
+}
+
+// PostOrder — порядок выдачи постов
+type PostOrder int
+
+const (
+	PostsNewest        PostOrder = iota // От новых к старым
+	PostsOldest                         // От старых к новым
+	PostsMostCommented                  // По убыванию числа комментариев, затем от новых к старым
+)
+
+// sortKey возвращает ключ сортировки для порядка o
+func (o PostOrder) sortKey() sortKey {
+	switch o {
+	case PostsOldest:
+		return sortKey{}
+	case PostsMostCommented:
+		return sortKey{byCount: true, desc: true}
+	default:
+		return newestFirst
+	}
+}
+
+// match сообщает, подходит ли пост под фильтр
+func (f PostFilter) match(post *Post) bool {
+	if (post.DeletedAt != nil) != f.Deleted {
+		return false
+	}
+	if f.Author != nil && post.Author != *f.Author {
+		return false
+	}
+	if f.CreatedAfter != nil && post.CreatedAt.Before(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !post.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.AllowComments != nil && post.AllowComments != *f.AllowComments {
+		return false
+	}
+	return true
+}
+
+// conditions возвращает SQL условия фильтра, дополняя аргументы args
+func (f PostFilter) conditions(d sqlDialect, args []any) ([]string, []any) {
+	conds := []string{"deleted_at IS NULL"}
+	if f.Deleted {
+		conds[0] = "deleted_at IS NOT NULL"
+	}
+	arg := func(v any) string {
+		args = append(args, v)
+		return d.placeholder(len(args))
+	}
+
+	if f.Author != nil {
+		conds = append(conds, "author = "+arg(*f.Author))
+	}
+	if f.CreatedAfter != nil {
+		conds = append(conds, "created_at >= "+arg(d.timeValue(*f.CreatedAfter)))
+	}
+	if f.CreatedBefore != nil {
+		conds = append(conds, "created_at < "+arg(d.timeValue(*f.CreatedBefore)))
+	}
+	if f.AllowComments != nil {
+		conds = append(conds, "allow_comments = "+arg(*f.AllowComments))
+	}
+	return conds, args
+}
+
+// visibleComment — условие SQL для комментариев неудалённых постов
+const visibleComment = "EXISTS (SELECT 1 FROM posts p WHERE p.id = comments.post_id AND p.deleted_at IS NULL)"
+
+// whereClause собирает условия в WHERE; пустая строка, если условий нет
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
@@ -0,0 +1,27 @@
+package store_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/SobolevTim/t-graphql/internal/store/storetest"
+)
+
+// TestPostgresStoreConformance прогоняет общий набор тестов хранилища
+// на тестовой базе PostgreSQL. Миграции применяет TestMain из postgres_store_test.go
+func TestPostgresStoreConformance(t *testing.T) {
+	s, err := store.NewPostgresStore(os.Getenv("TEST_DATABASE_URL"))
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	defer s.Close()
+
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		if _, err := s.DB.Exec(context.Background(), "TRUNCATE TABLE comment_edits, post_revisions, related_posts, comments, posts;"); err != nil {
+			t.Fatalf("failed to clean tables: %v", err)
+		}
+		return s
+	})
+}
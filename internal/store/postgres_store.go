@@ -3,15 +3,21 @@ package store
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"sync"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Service struct {
-	DB *pgxpool.Pool
+	DB       *pgxpool.Pool
+	listener *Listener // Общее соединение для LISTEN всех подписок
 }
 
 // NewPostgresStore создаёт новый экземпляр сервиса
@@ -26,44 +32,56 @@ func NewPostgresStore(url string) (*Service, error) {
 		return nil, fmt.Errorf("could not ping db: %w", err)
 	}
 
-	return &Service{DB: db}, nil
+	// Подписки не занимают соединения пула: все LISTEN
+	// выполняются на одном выделенном соединении
+	listener := NewListener(db.Config().ConnConfig)
+
+	return &Service{DB: db, listener: listener}, nil
 }
 
 // Close закрывает соединение с базой данных
 func (s *Service) Close() {
+	s.listener.Close()
 	s.DB.Close()
 }
 
+// ListenerStats возвращает состояние соединения для подписок
+func (s *Service) ListenerStats() ListenerStats {
+	return s.listener.Stats()
+}
+
 // Создание поста
 // allowComments — разрешены ли комментарии к посту
-func (s *Service) CreatePost(id, title, content, author string, allowComments bool) (*Post, error) {
+func (s *Service) CreatePost(ctx context.Context, id, title, content, author string, allowComments bool) (*Post, error) {
 	query := `
         INSERT INTO posts (id, title, content, author, allow_comments, created_at)
         VALUES ($1, $2, $3, $4, $5, NOW())
-        RETURNING id, title, content, author, allow_comments, created_at
+        RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
         `
 	// Выполнение запроса
-	row := s.DB.QueryRow(context.Background(), query, id, title, content, author, allowComments)
+	row := s.DB.QueryRow(ctx, query, id, title, content, author, allowComments)
 
 	post := &Post{}
-	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt); err != nil {
-		return nil, fmt.Errorf("could not create post: %w", err)
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not create post", err, ErrPostNotFound)
 	}
 
 	return post, nil
 }
 
-// Получение копии постов с пагинацией
-// page — номер страницы, pageSize — количество постов на странице
-func (s *Service) GetPosts(page, pageSize int) ([]*Post, error) {
+// Получение страницы постов по фильтру в порядке order
+func (s *Service) FindPosts(ctx context.Context, filter PostFilter, order PostOrder, page PageQuery) (*Page[*Post], error) {
+	conds, args := filter.conditions(postgresDialect, nil)
+	clause, args := postgresDialect.pageClause(order.sortKey(), page, conds, args)
 	query := `
-		SELECT id, title, content, author, allow_comments, created_at
-		FROM posts
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-		`
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		FROM posts` + clause
 	// Выполнение запроса
-	rows, err := s.DB.Query(context.Background(), query, pageSize, (page-1)*pageSize)
+	rows, err := s.DB.Query(ctx, query, args...)
+	if isInvalidID(err) {
+		// Курсор с таким ID не может указывать ни на один пост
+		return newPage([]*Post{}, page), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not get posts: %w", err)
 	}
@@ -73,85 +91,333 @@ func (s *Service) GetPosts(page, pageSize int) ([]*Post, error) {
 	posts := make([]*Post, 0)
 	for rows.Next() {
 		post := &Post{}
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt); err != nil {
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
 			return nil, fmt.Errorf("could not read post: %w", err)
 		}
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
 
-	return posts, nil
+	return newPage(posts, page), nil
+}
+
+// Количество постов по фильтру
+func (s *Service) CountPosts(ctx context.Context, filter PostFilter) (int, error) {
+	conds, args := filter.conditions(postgresDialect, nil)
+	query := `SELECT COUNT(*) FROM posts` + whereClause(conds)
+
+	var count int
+	if err := s.DB.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not count posts: %w", err)
+	}
+	return count, nil
 }
 
 // Получение поста по ID
-func (s *Service) GetPostByID(id string) (*Post, error) {
+func (s *Service) GetPostByID(ctx context.Context, id string) (*Post, error) {
 	query := `
-		SELECT id, title, content, author, allow_comments, created_at
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
 		FROM posts
-		WHERE id = $1
+		WHERE id = $1 AND deleted_at IS NULL
 		`
 	// Выполнение запроса
-	row := s.DB.QueryRow(context.Background(), query, id)
+	row := s.DB.QueryRow(ctx, query, id)
 
 	// Обработка результата запроса
 	post := &Post{}
-	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt); err != nil {
-		return nil, fmt.Errorf("could not get post: %w", err)
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not get post", err, ErrPostNotFound)
 	}
 
 	return post, nil
 }
 
 // Обновление разрешения на комментарии к посту
-func (s *Service) UpdatePostCommentsPermission(postID string, allowComments bool) (*Post, error) {
+func (s *Service) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*Post, error) {
 	query := `
 		UPDATE posts
 		SET allow_comments = $1
-		WHERE id = $2
-		RETURNING id, title, content, author, allow_comments, created_at
+		WHERE id = $2 AND deleted_at IS NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	row := s.DB.QueryRow(ctx, query, allowComments, postID)
+
+	// Обработка результата запроса
+	post := &Post{}
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not update post", err, ErrPostNotFound)
+	}
+
+	return post, nil
+}
+
+// Изменение заголовка и текста поста
+// Прежние заголовок и текст сохраняются ревизией в той же транзакции
+func (s *Service) UpdatePost(ctx context.Context, postID string, update PostUpdate) (*Post, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not update post: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка поста: номер следующей ревизии считается без гонок
+	locked := `SELECT id FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`
+	if err := tx.QueryRow(ctx, locked, postID).Scan(new(string)); err != nil {
+		return nil, pgError("could not update post", err, ErrPostNotFound)
+	}
+
+	revision := `
+		INSERT INTO post_revisions (post_id, number, title, content, editor, edited_at)
+		SELECT id, (SELECT COALESCE(MAX(number), 0) + 1 FROM post_revisions WHERE post_id = $1), title, content, $2, NOW()
+		FROM posts
+		WHERE id = $1 AND deleted_at IS NULL
+		`
+	if _, err := tx.Exec(ctx, revision, postID, update.Editor); err != nil {
+		return nil, fmt.Errorf("could not save post revision: %w", err)
+	}
+
+	query := `
+		UPDATE posts
+		SET title = $1, content = $2, updated_at = NOW()
+		WHERE id = $3
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
 		`
 	// Выполнение запроса
-	row := s.DB.QueryRow(context.Background(), query, allowComments, postID)
+	row := tx.QueryRow(ctx, query, update.Title, update.Content, postID)
 
 	// Обработка результата запроса
 	post := &Post{}
-	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt); err != nil {
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not update post", err, ErrPostNotFound)
+	}
+	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("could not update post: %w", err)
 	}
 
 	return post, nil
 }
 
+// Получение ревизий поста
+func (s *Service) ListPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error) {
+	query := `
+		SELECT post_id, number, title, content, editor, edited_at
+		FROM post_revisions
+		WHERE post_id = $1
+		ORDER BY number
+		`
+	// Выполнение запроса
+	rows, err := s.DB.Query(ctx, query, postID)
+	if isInvalidID(err) {
+		return []*PostRevision{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get post revisions: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	revisions := make([]*PostRevision, 0)
+	for rows.Next() {
+		r := &PostRevision{}
+		if err := rows.Scan(&r.PostID, &r.Number, &r.Title, &r.Content, &r.Editor, &r.EditedAt); err != nil {
+			return nil, fmt.Errorf("could not read post revision: %w", err)
+		}
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get post revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// Удаление поста в корзину
+func (s *Service) DeletePost(ctx context.Context, postID string) (*Post, error) {
+	query := `
+		UPDATE posts
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	row := s.DB.QueryRow(ctx, query, postID)
+
+	// Обработка результата запроса
+	post := &Post{}
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not delete post", err, ErrPostNotFound)
+	}
+
+	return post, nil
+}
+
+// Восстановление поста из корзины
+func (s *Service) RestorePost(ctx context.Context, postID string) (*Post, error) {
+	query := `
+		UPDATE posts
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	row := s.DB.QueryRow(ctx, query, postID)
+
+	// Обработка результата запроса
+	post := &Post{}
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+		return nil, pgError("could not restore post", err, ErrPostNotFound)
+	}
+
+	return post, nil
+}
+
+// Окончательное удаление постов, удалённых в корзину раньше deletedBefore
+// Комментарии, ревизии и сходство постов удаляются каскадно
+func (s *Service) PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int, error) {
+	query := `DELETE FROM posts WHERE deleted_at < $1`
+	// Выполнение запроса
+	tag, err := s.DB.Exec(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("could not purge posts: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Сохранение сходства поста с другими постами
+// Пары записываются в обе стороны, повторная запись обновляет сходство
+func (s *Service) AddRelatedPosts(ctx context.Context, postID string, related []RelatedScore) error {
+	if len(related) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(related))
+	scores := make([]float64, 0, len(related))
+	for _, r := range related {
+		ids = append(ids, r.PostID)
+		scores = append(scores, r.Score)
+	}
+
+	query := `
+		INSERT INTO related_posts (post_id, related_id, score)
+		SELECT $1::uuid, r.id, r.score FROM unnest($2::uuid[], $3::float8[]) AS r(id, score)
+		UNION ALL
+		SELECT r.id, $1::uuid, r.score FROM unnest($2::uuid[], $3::float8[]) AS r(id, score)
+		ON CONFLICT (post_id, related_id) DO UPDATE SET score = EXCLUDED.score
+		`
+	// Выполнение запроса
+	if _, err := s.DB.Exec(ctx, query, postID, ids, scores); err != nil {
+		return pgError("could not add related posts", err, ErrPostNotFound)
+	}
+	return nil
+}
+
+// Получение похожих постов
+func (s *Service) ListRelatedPosts(ctx context.Context, postID string, limit int) ([]*RelatedPost, error) {
+	query := `
+		SELECT p.id, p.title, p.content, p.author, p.allow_comments, p.created_at, p.comment_count, p.top_level_comment_count, p.updated_at, r.score
+		FROM related_posts r
+		JOIN posts p ON p.id = r.related_id
+		WHERE r.post_id = $1 AND p.deleted_at IS NULL
+		ORDER BY r.score DESC, p.created_at DESC, p.id DESC
+		LIMIT $2
+		`
+	// Выполнение запроса
+	rows, err := s.DB.Query(ctx, query, postID, limit)
+	if isInvalidID(err) {
+		return []*RelatedPost{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get related posts: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	related := make([]*RelatedPost, 0)
+	for rows.Next() {
+		post := &Post{}
+		r := &RelatedPost{Post: post}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &r.Score); err != nil {
+			return nil, fmt.Errorf("could not read related post: %w", err)
+		}
+		related = append(related, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get related posts: %w", err)
+	}
+	return related, nil
+}
+
 // Создание комментария
 // parentID — ID родительского комментария
-func (s *Service) CreateComment(id, postID string, parentID *string, content, author string) (*Comment, error) {
+func (s *Service) CreateComment(ctx context.Context, id, postID string, parentID *string, content, author string) (*Comment, error) {
+	// Глубина и корень ветки берутся у родителя. Родитель ищется
+	// только среди комментариев того же поста: если его нет,
+	// запрос ничего не вставляет и возвращает ErrInvalidParent.
+	// Пост в корзине тоже не принимает комментарии; блокировка строки
+	// поста не даёт переместить его в корзину до конца запроса.
+	// Счётчики комментариев поста и ответов всех комментариев
+	// выше по ветке обновляются тем же запросом
 	query := `
-		INSERT INTO comments (id, post_id, parent_id, content, author, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
-		RETURNING id, post_id, parent_id, content, author, created_at
+		WITH RECURSIVE inserted AS (
+			INSERT INTO comments (id, post_id, parent_id, content, author, depth, root_id, created_at)
+			SELECT $1::uuid, $2::uuid, $3::uuid, $4::text, $5::text,
+				COALESCE(parent.depth + 1, 0), COALESCE(parent.root_id, $1::uuid), NOW()
+			FROM posts post
+			LEFT JOIN comments parent ON parent.id = $3::uuid AND parent.post_id = post.id
+			WHERE post.id = $2::uuid AND post.deleted_at IS NULL
+				AND ($3::uuid IS NULL OR parent.id IS NOT NULL)
+			FOR SHARE OF post
+			RETURNING id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		), counted AS (
+			UPDATE posts
+			SET comment_count = comment_count + 1,
+				top_level_comment_count = top_level_comment_count + CASE WHEN $3::uuid IS NULL THEN 1 ELSE 0 END
+			WHERE id IN (SELECT post_id FROM inserted)
+		), ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id IN (SELECT parent_id FROM inserted)
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		), replied AS (
+			UPDATE comments
+			SET reply_count = reply_count + CASE WHEN id = $3::uuid THEN 1 ELSE 0 END,
+				descendant_count = descendant_count + 1
+			WHERE id IN (SELECT id FROM ancestors)
+		)
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM inserted
 		`
 	// Выполнение запроса
-	row := s.DB.QueryRow(context.Background(), query, id, postID, parentID, content, author)
+	row := s.DB.QueryRow(ctx, query, id, postID, parentID, content, author)
 
 	// Обработка результата запроса
 	comment := &Comment{}
-	if err := row.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt); err != nil {
-		return nil, fmt.Errorf("could not create comment: %w", err)
+	if err := row.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
+		// Запрос ничего не вставил: нет поста или родителя
+		if errors.Is(err, pgx.ErrNoRows) {
+			if _, err := s.GetPostByID(ctx, postID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, pgError("could not create comment", err, ErrInvalidParent)
 	}
 
 	return comment, nil
 }
 
-// Получение комментариев к посту с пагинацией
-func (s *Service) GetCommentsByPostID(postID string, page, pageSize int) ([]*Comment, error) {
+// Получение страницы комментариев верхнего уровня или ответов на parentID
+func (s *Service) ListComments(ctx context.Context, postID string, parentID *string, page PageQuery) (*Page[*Comment], error) {
+	conds, args := commentsFilter(postID, parentID)
+	clause, args := postgresDialect.pageClause(newestFirst, page, conds, args)
 	query := `
-		SELECT id, post_id, parent_id, content, author, created_at
-		FROM comments
-		WHERE post_id = $1 AND parent_id IS NULL
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
-		`
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments` + clause
 	// Выполнение запроса
-	rows, err := s.DB.Query(context.Background(), query, postID, pageSize, (page-1)*pageSize)
+	rows, err := s.DB.Query(ctx, query, args...)
+	if isInvalidID(err) {
+		// ID не может принадлежать ни одному посту или комментарию
+		return newPage([]*Comment{}, page), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not get comments: %w", err)
 	}
@@ -161,98 +427,611 @@ func (s *Service) GetCommentsByPostID(postID string, page, pageSize int) ([]*Com
 	comments := make([]*Comment, 0)
 	for rows.Next() {
 		comment := &Comment{}
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
 			return nil, fmt.Errorf("could not read comment: %w", err)
 		}
 		comments = append(comments, comment)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comments: %w", err)
+	}
 
-	return comments, nil
+	return newPage(comments, page), nil
 }
 
-// Получение ответов на комментарий с пагинацией
-func (s *Service) GetCommentsByPostIDAndParentID(postID string, parentID *string, page, pageSize int) ([]*Comment, error) {
+// Количество комментариев верхнего уровня или ответов на parentID
+func (s *Service) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	conds, args := commentsFilter(postID, parentID)
+	query := `SELECT COUNT(*) FROM comments WHERE ` + strings.Join(conds, " AND ")
+
+	var count int
+	err := s.DB.QueryRow(ctx, query, args...).Scan(&count)
+	if isInvalidID(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("could not count comments: %w", err)
+	}
+	return count, nil
+}
+
+// Поиск постов и комментариев по словам запроса
+// Релевантность считает ts_rank по столбцам search, их поддерживают триггеры
+func (s *Service) Search(ctx context.Context, search SearchQuery, page PageQuery) (*Page[*SearchHit], error) {
+	terms := searchTerms(search.Text)
+	if len(terms) == 0 {
+		return newPage([]*SearchHit{}, page), nil
+	}
+
+	// Все слова запроса должны встретиться в записи
+	args := []any{strings.Join(terms, " "), pgHeadlineOptions}
+	var sources []string
+	if search.includes(SearchPosts) {
+		sources = append(sources, `
+			SELECT 'post' AS kind, id, created_at, ts_rank(search, q)::float8 AS score, title || ' ' || content AS body
+			FROM posts, plainto_tsquery('simple', $1) AS q
+			WHERE search @@ q AND deleted_at IS NULL`)
+	}
+	if search.includes(SearchComments) {
+		sources = append(sources, `
+			SELECT 'comment' AS kind, id, created_at, ts_rank(search, q)::float8 AS score, content AS body
+			FROM comments, plainto_tsquery('simple', $1) AS q
+			WHERE search @@ q AND deleted_at IS NULL AND `+visibleComment)
+	}
+	clause, args := postgresDialect.pageClause(searchOrder, page, nil, args)
+	// ts_headline дорогая, поэтому PostgreSQL вычисляет её только для строк страницы
 	query := `
-		SELECT id, post_id, parent_id, content, author, created_at
+		WITH hits AS (` + strings.Join(sources, " UNION ALL ") + `)
+		SELECT kind, id, score, ts_headline('simple', body, plainto_tsquery('simple', $1), $2)
+		FROM hits` + clause
+	// Выполнение запроса
+	rows, err := s.DB.Query(ctx, query, args...)
+	if isInvalidID(err) {
+		// Курсор с таким ID не может указывать ни на одну запись
+		return newPage([]*SearchHit{}, page), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not search: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	hits := make([]*SearchHit, 0)
+	var postIDs, commentIDs []string
+	for rows.Next() {
+		var kind, id string
+		hit := &SearchHit{}
+		if err := rows.Scan(&kind, &id, &hit.Score, &hit.Snippet); err != nil {
+			return nil, fmt.Errorf("could not read search result: %w", err)
+		}
+		hit.Snippet = highlighter.Replace(hit.Snippet)
+		if kind == "post" {
+			hit.Post = &Post{ID: id}
+			postIDs = append(postIDs, id)
+		} else {
+			hit.Comment = &Comment{ID: id}
+			commentIDs = append(commentIDs, id)
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not search: %w", err)
+	}
+	rows.Close()
+
+	// Найденные записи загружаются отдельными запросами по ID
+	posts, err := s.GetPostsByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.GetCommentsByIDs(ctx, commentIDs)
+	if err != nil {
+		return nil, err
+	}
+	found := hits[:0]
+	for _, hit := range hits {
+		// Запись могла быть удалена между запросами
+		if hit.Post != nil {
+			hit.Post = posts[hit.Post.ID]
+		} else {
+			hit.Comment = comments[hit.Comment.ID]
+		}
+		if hit.Post != nil || hit.Comment != nil {
+			found = append(found, hit)
+		}
+	}
+
+	return newPage(found, page), nil
+}
+
+// pgHeadlineOptions задаёт размер фрагментов ts_headline как у snippet
+// Найденные слова отмечаются метками, выделение добавляет highlighter
+var pgHeadlineOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=%d, MinWords=%d",
+	matchStart, matchStop, snippetWords, snippetWords/2)
+
+// Получение постов по ID, ненайденных постов в результате нет
+func (s *Service) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*Post, error) {
+	posts := make(map[string]*Post, len(ids))
+	ids = validUUIDs(ids)
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	query := `
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		FROM posts
+		WHERE id = ANY($1::uuid[]) AND deleted_at IS NULL
+		`
+	rows, err := s.DB.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		post := &Post{}
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &post.CreatedAt, &post.CommentCount, &post.TopLevelCommentCount, &post.UpdatedAt, &post.DeletedAt); err != nil {
+			return nil, fmt.Errorf("could not read post: %w", err)
+		}
+		posts[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+	return posts, nil
+}
+
+// Получение комментариев по ID, ненайденных комментариев в результате нет
+func (s *Service) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*Comment, error) {
+	comments := make(map[string]*Comment, len(ids))
+	ids = validUUIDs(ids)
+	if len(ids) == 0 {
+		return comments, nil
+	}
+	query := `
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments
+		WHERE id = ANY($1::uuid[]) AND ` + visibleComment
+	rows, err := s.DB.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		comment := &Comment{}
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
+			return nil, fmt.Errorf("could not read comment: %w", err)
+		}
+		comments[comment.ID] = comment
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comments: %w", err)
+	}
+	return comments, nil
+}
+
+// Изменение текста комментария
+// Прежний текст сохраняется в истории правок в той же транзакции
+func (s *Service) UpdateComment(ctx context.Context, id, content string) (*Comment, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not update comment: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка комментария: номер следующей правки считается без гонок
+	locked := `SELECT id FROM comments WHERE id = $1 AND deleted_at IS NULL AND ` + visibleComment + ` FOR UPDATE`
+	if err := tx.QueryRow(ctx, locked, id).Scan(new(string)); err != nil {
+		return nil, pgError("could not update comment", err, ErrCommentNotFound)
+	}
+
+	edit := `
+		INSERT INTO comment_edits (comment_id, number, content, edited_at)
+		SELECT id, (SELECT COALESCE(MAX(number), 0) + 1 FROM comment_edits WHERE comment_id = $1), content, NOW()
 		FROM comments
-		WHERE post_id = $1 AND parent_id = $2
-		ORDER BY created_at DESC
-		LIMIT $3 OFFSET $4
+		WHERE id = $1
+		`
+	if _, err := tx.Exec(ctx, edit, id); err != nil {
+		return nil, fmt.Errorf("could not save comment edit: %w", err)
+	}
+
+	query := `
+		UPDATE comments
+		SET content = $1, edited_at = NOW()
+		WHERE id = $2
+		RETURNING id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		`
+	// Выполнение запроса
+	row := tx.QueryRow(ctx, query, content, id)
+
+	// Обработка результата запроса
+	comment := &Comment{}
+	if err := row.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
+		return nil, pgError("could not update comment", err, ErrCommentNotFound)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not update comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// Получение правок нескольких комментариев
+func (s *Service) ListCommentEdits(ctx context.Context, commentIDs []string) (map[string][]*CommentEdit, error) {
+	edits := make(map[string][]*CommentEdit, len(commentIDs))
+	commentIDs = validUUIDs(commentIDs)
+	if len(commentIDs) == 0 {
+		return edits, nil
+	}
+	query := `
+		SELECT comment_id, number, content, edited_at
+		FROM comment_edits
+		WHERE comment_id = ANY($1::uuid[])
+		ORDER BY comment_id, number
+		`
+	// Выполнение запроса
+	rows, err := s.DB.Query(ctx, query, commentIDs)
+	if err != nil {
+		return nil, fmt.Errorf("could not get comment edits: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	for rows.Next() {
+		e := &CommentEdit{}
+		if err := rows.Scan(&e.CommentID, &e.Number, &e.Content, &e.EditedAt); err != nil {
+			return nil, fmt.Errorf("could not read comment edit: %w", err)
+		}
+		edits[e.CommentID] = append(edits[e.CommentID], e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comment edits: %w", err)
+	}
+	return edits, nil
+}
+
+// Удаление комментария
+// Комментарий с ответами остаётся в ветке без текста и автора,
+// комментарий без ответов удаляется вместе с ненужными удалёнными предками
+func (s *Service) DeleteComment(ctx context.Context, id, reason string) (*Comment, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокировка комментария: ответ на него не появится до конца транзакции
+	var replies int
+	locked := `SELECT reply_count FROM comments WHERE id = $1 AND deleted_at IS NULL AND ` + visibleComment + ` FOR UPDATE`
+	if err := tx.QueryRow(ctx, locked, id).Scan(&replies); err != nil {
+		return nil, pgError("could not delete comment", err, ErrCommentNotFound)
+	}
+
+	var kept *Comment
+	if replies > 0 {
+		query := `
+			UPDATE comments
+			SET content = $2, author = $2, edited_at = NULL, deleted_at = NOW(), delete_reason = $3
+			WHERE id = $1
+			RETURNING id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+			`
+		row := tx.QueryRow(ctx, query, id, DeletedPlaceholder, reason)
+		kept = &Comment{}
+		if err := row.Scan(&kept.ID, &kept.PostID, &kept.ParentID, &kept.Content, &kept.Author, &kept.CreatedAt, &kept.Depth, &kept.RootID, &kept.ReplyCount, &kept.DescendantCount, &kept.EditedAt, &kept.DeletedAt, &kept.DeleteReason); err != nil {
+			return nil, pgError("could not delete comment", err, ErrCommentNotFound)
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM comment_edits WHERE comment_id = $1`, id); err != nil {
+			return nil, fmt.Errorf("could not delete comment edits: %w", err)
+		}
+	} else {
+		// Удалённые комментарии без ответов в ветке больше не нужны
+		for next := &id; next != nil; {
+			if next, err = dropComment(ctx, tx, *next); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+
+	return kept, nil
+}
+
+// dropComment удаляет комментарий без ответов и обновляет счётчики поста
+// и комментариев выше по ветке. Возвращает ID родителя, если он удалён
+// и у него не осталось ответов
+func dropComment(ctx context.Context, tx pgx.Tx, id string) (*string, error) {
+	query := `
+		WITH RECURSIVE removed AS (
+			DELETE FROM comments
+			WHERE id = $1
+			RETURNING post_id, parent_id
+		), counted AS (
+			UPDATE posts
+			SET comment_count = comment_count - 1,
+				top_level_comment_count = top_level_comment_count - CASE WHEN (SELECT parent_id FROM removed) IS NULL THEN 1 ELSE 0 END
+			WHERE id IN (SELECT post_id FROM removed)
+		), ancestors AS (
+			SELECT id, parent_id FROM comments WHERE id IN (SELECT parent_id FROM removed)
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		), replied AS (
+			UPDATE comments
+			SET reply_count = reply_count - CASE WHEN id IN (SELECT parent_id FROM removed) THEN 1 ELSE 0 END,
+				descendant_count = descendant_count - 1
+			WHERE id IN (SELECT id FROM ancestors)
+			RETURNING id, reply_count, deleted_at
+		)
+		SELECT id FROM replied
+		WHERE id IN (SELECT parent_id FROM removed) AND reply_count = 0 AND deleted_at IS NOT NULL
 		`
+	var parentID string
+	err := tx.QueryRow(ctx, query, id).Scan(&parentID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+	return &parentID, nil
+}
+
+// Получение страниц комментариев верхнего уровня к нескольким постам
+func (s *Service) ListCommentsByPostIDs(ctx context.Context, postIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	return s.listCommentsBatch(ctx, "post_id", []string{"post_id = ANY($1::uuid[])", "parent_id IS NULL"}, postIDs, page)
+}
+
+// Получение страниц ответов на несколько комментариев
+func (s *Service) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	return s.listCommentsBatch(ctx, "parent_id", []string{"parent_id = ANY($1::uuid[])"}, parentIDs, page)
+}
+
+// listCommentsBatch выбирает страницы комментариев, сгруппированных по столбцу
+// partition, для каждого ID из ids одним запросом
+func (s *Service) listCommentsBatch(ctx context.Context, partition string, conds []string, ids []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	query, args := postgresDialect.batchPageQuery("id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason",
+		"comments", partition, newestFirst, page, conds, []any{ids})
 	// Выполнение запроса
-	rows, err := s.DB.Query(context.Background(), query, postID, parentID, pageSize, (page-1)*pageSize)
+	rows, err := s.DB.Query(ctx, query, args...)
+	if isInvalidID(err) {
+		// Курсор с таким ID не может указывать ни на один комментарий
+		return groupPages[*Comment](ids, nil, page), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("could not get comments: %w", err)
 	}
 	defer rows.Close()
 
+	// Обработка результатов запроса
+	groups := make(map[string][]*Comment, len(ids))
+	for rows.Next() {
+		comment := &Comment{}
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
+			return nil, fmt.Errorf("could not read comment: %w", err)
+		}
+		key := comment.PostID
+		if partition == "parent_id" {
+			key = *comment.ParentID
+		}
+		groups[key] = append(groups[key], comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comments: %w", err)
+	}
+
+	return groupPages(ids, groups, page), nil
+}
+
+// Количество комментариев верхнего уровня к нескольким постам
+func (s *Service) CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error) {
+	query := `
+		SELECT post_id, COUNT(*)
+		FROM comments
+		WHERE post_id = ANY($1::uuid[]) AND parent_id IS NULL
+		GROUP BY post_id
+		`
+	return s.countCommentsBatch(ctx, query, postIDs)
+}
+
+// Количество ответов на несколько комментариев
+func (s *Service) CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	query := `
+		SELECT parent_id, COUNT(*)
+		FROM comments
+		WHERE parent_id = ANY($1::uuid[])
+		GROUP BY parent_id
+		`
+	return s.countCommentsBatch(ctx, query, parentIDs)
+}
+
+// countCommentsBatch выполняет запрос, возвращающий пары (ID, число комментариев)
+// ID без комментариев получают 0
+func (s *Service) countCommentsBatch(ctx context.Context, query string, ids []string) (map[string]int, error) {
+	counts := make(map[string]int, len(ids))
+	for _, id := range ids {
+		counts[id] = 0
+	}
+
+	rows, err := s.DB.Query(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not count comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("could not read comment count: %w", err)
+		}
+		counts[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not count comments: %w", err)
+	}
+	return counts, nil
+}
+
+// Получение ветки комментариев одним рекурсивным запросом
+// path — номера комментариев среди ответов на родителя на пути от начального
+// уровня; сортировка по path даёт порядок обхода в глубину
+func (s *Service) CommentTree(ctx context.Context, postID string, tree TreeQuery) ([]*Comment, error) {
+	args := []any{postID, tree.LimitPerLevel, tree.MaxDepth}
+	start := `
+			SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason, 0 AS level, ARRAY[n] AS path
+			FROM (
+				SELECT *, ROW_NUMBER() OVER (ORDER BY created_at DESC, id DESC) AS n
+				FROM comments
+				WHERE post_id = $1 AND parent_id IS NULL
+			) AS top
+			WHERE n <= $2`
+	if tree.RootID != nil {
+		args = append(args, *tree.RootID)
+		start = `
+			SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason, 0 AS level, ARRAY[]::bigint[] AS path
+			FROM comments
+			WHERE id = $4 AND post_id = $1 AND ` + visibleComment
+	}
+	query := `
+		WITH RECURSIVE tree AS (` + start + `
+			UNION ALL
+			SELECT r.id, r.post_id, r.parent_id, r.content, r.author, r.created_at, r.depth, r.root_id, r.reply_count, r.descendant_count, r.edited_at, r.deleted_at, r.delete_reason, t.level + 1, t.path || r.n
+			FROM tree AS t
+			CROSS JOIN LATERAL (
+				SELECT *, ROW_NUMBER() OVER (ORDER BY created_at DESC, id DESC) AS n
+				FROM comments
+				WHERE parent_id = t.id
+				ORDER BY created_at DESC, id DESC
+				LIMIT $2
+			) AS r
+			WHERE t.level < $3
+		)
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM tree
+		ORDER BY path`
+	// Выполнение запроса
+	rows, err := s.DB.Query(ctx, query, args...)
+	if isInvalidID(err) {
+		// ID не может принадлежать ни одному посту или комментарию
+		if tree.RootID != nil {
+			return nil, ErrCommentNotFound
+		}
+		return []*Comment{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get comment tree: %w", err)
+	}
+	defer rows.Close()
+
 	// Обработка результатов запроса
 	comments := make([]*Comment, 0)
 	for rows.Next() {
 		comment := &Comment{}
-		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt); err != nil {
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
 			return nil, fmt.Errorf("could not read comment: %w", err)
 		}
 		comments = append(comments, comment)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comment tree: %w", err)
+	}
+	if tree.RootID != nil && len(comments) == 0 {
+		return nil, ErrCommentNotFound
+	}
 
 	return comments, nil
 }
 
-// Subscribe — добавляет подписчика на новые комментарии к посту
-func (s *Service) Subscribe(postID string) (<-chan *Comment, func()) {
-	ch := make(chan *Comment)
-	// Создание контекста для отмены подписки
-	ctx, cancel := context.WithCancel(context.Background())
-	var once sync.Once
+// commentsFilter возвращает условия выборки комментариев верхнего уровня
+// или ответов на parentID и их аргументы
+func commentsFilter(postID string, parentID *string) ([]string, []any) {
+	if parentID == nil {
+		return []string{"post_id = $1", "parent_id IS NULL"}, []any{postID}
+	}
+	return []string{"post_id = $1", "parent_id = $2"}, []any{postID, *parentID}
+}
 
-	// Подключение к базе данных
-	conn, err := s.DB.Acquire(ctx)
-	if err != nil {
-		log.Printf("could not acquire connection: %v", err)
-		cancel()
-		return nil, nil
+// Сохранение разрешённых операций
+// Операции с уже известным хешем заменяются
+func (s *Service) SavePersistedQueries(ctx context.Context, queries []*PersistedQuery) error {
+	if len(queries) == 0 {
+		return nil
+	}
+	hashes := make([]string, 0, len(queries))
+	names := make([]string, 0, len(queries))
+	texts := make([]string, 0, len(queries))
+	for _, q := range queries {
+		hashes = append(hashes, q.Hash)
+		names = append(names, q.Name)
+		texts = append(texts, q.Query)
 	}
 
-	// Подписка на канал уведомлений
-	_, err = conn.Exec(ctx, fmt.Sprintf(`LISTEN "comments_%s"`, postID))
-	if err != nil {
-		log.Printf("could not listen to channel: %v", err)
-		cancel()
-		conn.Release()
-		return nil, nil
+	query := `
+		INSERT INTO persisted_queries (hash, name, query)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::text[])
+		ON CONFLICT (hash) DO UPDATE SET name = EXCLUDED.name, query = EXCLUDED.query
+		`
+	// Выполнение запроса
+	if _, err := s.DB.Exec(ctx, query, hashes, names, texts); err != nil {
+		return fmt.Errorf("could not save persisted queries: %w", err)
 	}
+	return nil
+}
 
-	// Получение уведомлений
+// Получение разрешённой операции по хешу
+func (s *Service) GetPersistedQuery(ctx context.Context, hash string) (*PersistedQuery, error) {
+	query := `
+		SELECT hash, name, query
+		FROM persisted_queries
+		WHERE hash = $1
+		`
+	// Выполнение запроса
+	row := s.DB.QueryRow(ctx, query, hash)
+
+	// Обработка результата запроса
+	persisted := &PersistedQuery{}
+	if err := row.Scan(&persisted.Hash, &persisted.Name, &persisted.Query); err != nil {
+		return nil, pgError("could not get persisted query", err, ErrPersistedQueryNotFound)
+	}
+	return persisted, nil
+}
+
+// maxNotifyPayload — максимальный размер уведомления в байтах:
+// PostgreSQL отклоняет payload длиной 8000 байт и больше
+const maxNotifyPayload = 7999
+
+// Subscribe — добавляет подписчика на события комментариев к посту
+// Подписка завершается при отмене ctx или вызове unsubscribe
+func (s *Service) Subscribe(ctx context.Context, postID string) (<-chan *CommentEvent, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	notifications, unlisten := s.listener.Subscribe(ctx, commentsChannel(postID))
+	ch := make(chan *CommentEvent)
+
+	// Разбор уведомлений и передача подписчику
 	go func() {
-		defer func() {
-			once.Do(func() {
-				close(ch)      // Закрытие канала
-				conn.Release() // Освобождение соединения
-			})
-		}()
+		defer close(ch)
+		defer unlisten()
 
 		for {
-			// Ожидание уведомления
-			notification, err := conn.Conn().WaitForNotification(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
+			select {
+			case payload, ok := <-notifications:
+				if !ok {
 					return
 				}
-				log.Printf("error while waiting for notification: %v", err)
-				return
-			}
 
-			// Разбор уведомления
-			comment := &Comment{}
-			if err := json.Unmarshal([]byte(notification.Payload), comment); err != nil {
-				log.Printf("could not unmarshal comment: %v", err)
-				continue
-			}
+				event, err := s.decodeNotification(ctx, payload)
+				if err != nil {
+					log.Printf("could not decode comment notification: %v", err)
+					continue
+				}
 
-			// Отправляем уведомление с учетом возможности отмены
-			select {
-			case ch <- comment: // Отправка уведомления
+				// Отправляем уведомление с учетом возможности отмены
+				select {
+				case ch <- event: // Отправка уведомления
+				case <-ctx.Done(): // Отмена подписки
+					return
+				}
 			case <-ctx.Done(): // Отмена подписки
 				return
 			}
@@ -260,29 +1039,138 @@ func (s *Service) Subscribe(postID string) (<-chan *Comment, func()) {
 	}()
 
 	unsubscribe := func() {
-		// Отмена контекста прервет WaitForNotification
+		// Отмена контекста завершит горутину, она закроет канал
 		cancel()
-		once.Do(func() {
-			close(ch)      // Закрытие канала
-			conn.Release() // Освобождение соединения
-		})
 	}
 
 	return ch, unsubscribe
 }
 
-// Publish — публикация комментария
-func (s *Service) Publish(comment *Comment) {
-	// Размещение комментария в канале уведомлений
-	payload, err := json.Marshal(comment)
+// decodeNotification разбирает уведомление и при необходимости
+// загружает комментарий, не поместившийся в payload
+func (s *Service) decodeNotification(ctx context.Context, payload string) (*CommentEvent, error) {
+	event := &CommentEvent{}
+	if err := json.Unmarshal([]byte(payload), event); err != nil {
+		return nil, fmt.Errorf("could not unmarshal event: %w", err)
+	}
+	if event.Comment == nil {
+		comment, err := s.GetCommentByID(ctx, event.CommentID)
+		if err != nil {
+			return nil, err
+		}
+		event.Comment = comment
+	}
+	return event, nil
+}
+
+// Получение комментария по ID
+func (s *Service) GetCommentByID(ctx context.Context, id string) (*Comment, error) {
+	query := `
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments
+		WHERE id = $1 AND ` + visibleComment
+	// Выполнение запроса
+	row := s.DB.QueryRow(ctx, query, id)
+
+	// Обработка результата запроса
+	comment := &Comment{}
+	if err := row.Scan(&comment.ID, &comment.PostID, &comment.ParentID, &comment.Content, &comment.Author, &comment.CreatedAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &comment.EditedAt, &comment.DeletedAt, &comment.DeleteReason); err != nil {
+		return nil, pgError("could not get comment", err, ErrCommentNotFound)
+	}
+
+	return comment, nil
+}
+
+// Коды ошибок PostgreSQL, которые переводятся в ошибки хранилища
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgInvalidTextRepresentation = "22P02" // Например, ID не в формате UUID
+)
+
+// pgError переводит ошибку PostgreSQL в ошибку хранилища
+// notFound возвращается, если запись не найдена или её ID некорректен.
+// Остальные ошибки оборачиваются с описанием операции op
+func pgError(op string, err error, notFound error) error {
+	if errors.Is(err, pgx.ErrNoRows) || isInvalidID(err) {
+		return notFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == pgUniqueViolation && pgErr.TableName == "posts":
+			return ErrPostExists
+		case pgErr.Code == pgUniqueViolation && pgErr.TableName == "comments":
+			return ErrCommentExists
+		case pgErr.Code == pgForeignKeyViolation && pgErr.ConstraintName == "comments_post_id_fkey":
+			return ErrPostNotFound
+		case pgErr.Code == pgForeignKeyViolation && pgErr.ConstraintName == "comments_parent_id_fkey":
+			return ErrInvalidParent
+		case pgErr.Code == pgForeignKeyViolation && pgErr.TableName == "related_posts":
+			return ErrPostNotFound
+		}
+	}
+
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// isInvalidID сообщает, что запрос не выполнен из-за ID в неверном формате
+func isInvalidID(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgInvalidTextRepresentation
+}
+
+// validUUIDs оставляет из ids только UUID: остальные ID не могут
+// принадлежать ни одной записи, а в запросе с uuid[] дали бы ошибку
+func validUUIDs(ids []string) []string {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if uuid.Validate(id) == nil {
+			valid = append(valid, id)
+		}
+	}
+	return valid
+}
+
+// commentsChannel возвращает имя канала уведомлений для поста
+func commentsChannel(postID string) string {
+	return "comments_" + postID
+}
+
+// Publish — публикация события о комментарии
+// Это единственный источник уведомлений: триггеров на таблице comments нет
+func (s *Service) Publish(ctx context.Context, event *CommentEvent) {
+	payload, err := encodeNotification(event)
 	if err != nil {
-		log.Printf("could not marshal comment: %v", err)
+		log.Printf("could not marshal event: %v", err)
 		return
 	}
 
-	// Отправка уведомления
-	_, err = s.DB.Exec(context.Background(), fmt.Sprintf(`NOTIFY "comments_%s", '%s'`, comment.PostID, payload))
+	// Имя канала и payload передаются параметрами, а не подставляются в SQL
+	_, err = s.DB.Exec(ctx, `SELECT pg_notify($1, $2)`, commentsChannel(event.PostID), payload)
 	if err != nil {
 		log.Printf("could not notify channel: %v", err)
 	}
 }
+
+// encodeNotification формирует payload уведомления
+// Если событие не помещается, комментарий из него убирается
+// и подписчик загрузит его по CommentID
+func encodeNotification(event *CommentEvent) (string, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+	if len(payload) <= maxNotifyPayload {
+		return string(payload), nil
+	}
+
+	compact := *event
+	compact.Comment = nil
+	payload, err = json.Marshal(compact)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
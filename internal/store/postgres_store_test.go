@@ -2,19 +2,22 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/SobolevTim/t-graphql/internal/migrate"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var testStore *Service
 
 // TestMain выполняет инициализацию подключения к тестовой базе,
-// создаёт необходимые таблицы и по завершении тестов их удаляет.
+// применяет миграции схемы и по завершении тестов откатывает их.
 func TestMain(m *testing.M) {
 	// Получаем URL подключения к тестовой БД из переменной окружения
 	dbURL := os.Getenv("TEST_DATABASE_URL")
@@ -30,76 +33,39 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
-	// Создаём таблицы для тестов
-	if err := setupTables(testStore.DB); err != nil {
-		fmt.Printf("Failed to setup tables: %v\n", err)
+	// Создаём таблицы теми же миграциями, что и в рабочей базе
+	migrator, err := migrate.NewPostgres(testStore.DB)
+	if err != nil {
+		fmt.Printf("Failed to load migrations: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		fmt.Printf("Failed to apply migrations: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Запускаем тесты
 	code := m.Run()
 
-	// Удаляем таблицы после тестирования
-	if err := teardownTables(testStore.DB); err != nil {
-		fmt.Printf("Failed to teardown tables: %v\n", err)
+	// Откатываем все миграции после тестирования
+	if _, err := migrator.Down(context.Background(), 0); err != nil {
+		fmt.Printf("Failed to revert migrations: %v\n", err)
 	}
 	testStore.Close()
 	os.Exit(code)
 }
 
-// setupTables создаёт таблицы posts и comments, если их ещё нет.
-func setupTables(db *pgxpool.Pool) error {
-	ctx := context.Background()
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS posts (
-			id TEXT PRIMARY KEY,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			author TEXT NOT NULL,
-			allow_comments BOOLEAN NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		);`,
-		`CREATE TABLE IF NOT EXISTS comments (
-			id TEXT PRIMARY KEY,
-			post_id TEXT NOT NULL,
-			parent_id TEXT,
-			content TEXT NOT NULL,
-			author TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		);`,
-	}
-	for _, q := range queries {
-		if _, err := db.Exec(ctx, q); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// teardownTables удаляет таблицы posts и comments.
-func teardownTables(db *pgxpool.Pool) error {
-	ctx := context.Background()
-	queries := []string{
-		`DROP TABLE IF EXISTS comments;`,
-		`DROP TABLE IF EXISTS posts;`,
-	}
-	for _, q := range queries {
-		if _, err := db.Exec(ctx, q); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-// cleanTables очищает данные из таблиц posts и comments.
+// cleanTables очищает данные из таблиц постов и комментариев.
+// Таблицы со ссылками на них очищаются тем же запросом, иначе TRUNCATE не выполнится
 func cleanTables(s *Service) error {
 	ctx := context.Background()
-	_, err := s.DB.Exec(ctx, "TRUNCATE TABLE comments, posts;")
+	_, err := s.DB.Exec(ctx, "TRUNCATE TABLE comment_edits, post_revisions, related_posts, comments, posts;")
 	return err
 }
 
 // TestCreatePost проверяет создание поста.
 func TestCreatePost(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
@@ -110,7 +76,7 @@ func TestCreatePost(t *testing.T) {
 	author := "tester"
 	allowComments := true
 
-	post, err := testStore.CreatePost(postID, title, content, author, allowComments)
+	post, err := testStore.CreatePost(ctx, postID, title, content, author, allowComments)
 	if err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
@@ -121,8 +87,9 @@ func TestCreatePost(t *testing.T) {
 	}
 }
 
-// TestGetPosts проверяет выборку постов с пагинацией.
-func TestGetPosts(t *testing.T) {
+// TestListPosts проверяет выборку страниц постов.
+func TestListPosts(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
@@ -141,7 +108,7 @@ func TestGetPosts(t *testing.T) {
 		{post3ID, "Post 3"},
 	}
 	for _, pd := range postsData {
-		if _, err := testStore.CreatePost(pd.id, pd.title, "Content", "Author", true); err != nil {
+		if _, err := testStore.CreatePost(ctx, pd.id, pd.title, "Content", "Author", true); err != nil {
 			t.Fatalf("failed to create post %s: %v", pd.id, err)
 		}
 		// Небольшая задержка для гарантии различного времени создания
@@ -149,21 +116,95 @@ func TestGetPosts(t *testing.T) {
 	}
 
 	// Запрашиваем первую страницу с 2 записями (сортировка по created_at DESC)
-	posts, err := testStore.GetPosts(1, 2)
+	page, err := testStore.FindPosts(ctx, PostFilter{}, PostsNewest, PageQuery{First: 2})
 	if err != nil {
-		t.Fatalf("GetPosts failed: %v", err)
+		t.Fatalf("FindPosts failed: %v", err)
 	}
+	posts := page.Items
 	if len(posts) != 2 {
-		t.Errorf("expected 2 posts, got %d", len(posts))
+		t.Fatalf("expected 2 posts, got %d", len(posts))
 	}
 	// При сортировке DESC первым должен идти последний созданный пост ("3")
 	if posts[0].ID != post3ID || posts[1].ID != post2ID {
 		t.Errorf("unexpected order of posts: got %v", []string{posts[0].ID, posts[1].ID})
 	}
+	if !page.HasNextPage {
+		t.Errorf("expected next page")
+	}
+
+	// Следующая страница начинается сразу после курсора последнего поста
+	after := posts[1].Cursor()
+	page, err = testStore.FindPosts(ctx, PostFilter{}, PostsNewest, PageQuery{First: 2, After: &after})
+	if err != nil {
+		t.Fatalf("FindPosts failed: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != post1ID {
+		t.Errorf("unexpected second page: %+v", page.Items)
+	}
+	if page.HasNextPage {
+		t.Errorf("expected no next page")
+	}
+}
+
+// TestPaginationIndexes проверяет, что миграции создают индексы для выборки страниц.
+func TestPaginationIndexes(t *testing.T) {
+	ctx := context.Background()
+	for _, name := range []string{"posts_created_at_id_idx", "comments_post_parent_created_at_id_idx"} {
+		var exists bool
+		err := testStore.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = $1)`, name).Scan(&exists)
+		if err != nil {
+			t.Fatalf("failed to query indexes: %v", err)
+		}
+		if !exists {
+			t.Errorf("index %s does not exist", name)
+		}
+	}
+}
+
+// TestSearchVectors проверяет, что триггеры заполняют столбцы search
+func TestSearchVectors(t *testing.T) {
+	ctx := context.Background()
+	if err := cleanTables(testStore); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+
+	postID := uuid.NewString()
+	if _, err := testStore.CreatePost(ctx, postID, "Full Text", "Search body", "tester", true); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+	commentID := uuid.NewString()
+	if _, err := testStore.CreateComment(ctx, commentID, postID, nil, "Comment body", "tester"); err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+
+	var postMatches, commentMatches bool
+	err := testStore.DB.QueryRow(ctx, `
+		SELECT
+			(SELECT search @@ plainto_tsquery('simple', 'full body') FROM posts WHERE id = $1),
+			(SELECT search @@ plainto_tsquery('simple', 'comment') FROM comments WHERE id = $2)
+		`, postID, commentID).Scan(&postMatches, &commentMatches)
+	if err != nil {
+		t.Fatalf("failed to query search vectors: %v", err)
+	}
+	if !postMatches || !commentMatches {
+		t.Errorf("search vectors are not filled: post %v, comment %v", postMatches, commentMatches)
+	}
+
+	for _, name := range []string{"posts_search_idx", "comments_search_idx"} {
+		var exists bool
+		err := testStore.DB.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM pg_indexes WHERE indexname = $1)`, name).Scan(&exists)
+		if err != nil {
+			t.Fatalf("failed to query indexes: %v", err)
+		}
+		if !exists {
+			t.Errorf("index %s does not exist", name)
+		}
+	}
 }
 
 // TestGetPostByID проверяет получение поста по его идентификатору.
 func TestGetPostByID(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
@@ -173,12 +214,12 @@ func TestGetPostByID(t *testing.T) {
 	content := "This is a test post."
 	author := "tester"
 
-	createdPost, err := testStore.CreatePost(postID, title, content, author, true)
+	createdPost, err := testStore.CreatePost(ctx, postID, title, content, author, true)
 	if err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
-	fetchedPost, err := testStore.GetPostByID(postID)
+	fetchedPost, err := testStore.GetPostByID(ctx, postID)
 	if err != nil {
 		t.Fatalf("GetPostByID failed: %v", err)
 	}
@@ -189,17 +230,18 @@ func TestGetPostByID(t *testing.T) {
 
 // TestUpdatePostCommentsPermission проверяет обновление разрешения на комментарии.
 func TestUpdatePostCommentsPermission(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
 
 	postID := uuid.NewString()
-	_, err := testStore.CreatePost(postID, "Test Post", "This is a test post.", "tester", false)
+	_, err := testStore.CreatePost(ctx, postID, "Test Post", "This is a test post.", "tester", false)
 	if err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
-	updatedPost, err := testStore.UpdatePostCommentsPermission(postID, true)
+	updatedPost, err := testStore.UpdatePostCommentsPermission(ctx, postID, true)
 	if err != nil {
 		t.Fatalf("UpdatePostCommentsPermission failed: %v", err)
 	}
@@ -210,13 +252,14 @@ func TestUpdatePostCommentsPermission(t *testing.T) {
 
 // TestCreateComment проверяет создание комментария.
 func TestCreateComment(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
 
 	// Создаём пост для комментария
 	postID := uuid.NewString()
-	if _, err := testStore.CreatePost(postID, "Test Post", "Content", "tester", true); err != nil {
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
@@ -224,7 +267,7 @@ func TestCreateComment(t *testing.T) {
 	content := "Nice post!"
 	author := "commenter"
 	// parentID == nil означает верхний уровень
-	comment, err := testStore.CreateComment(commentID, postID, nil, content, author)
+	comment, err := testStore.CreateComment(ctx, commentID, postID, nil, content, author)
 	if err != nil {
 		t.Fatalf("CreateComment failed: %v", err)
 	}
@@ -233,15 +276,52 @@ func TestCreateComment(t *testing.T) {
 	}
 }
 
-// TestGetCommentsByPostID проверяет выборку верхнеуровневых комментариев для поста.
-func TestGetCommentsByPostID(t *testing.T) {
+// TestCreateCommentErrors проверяет перевод нарушений внешних ключей в ошибки хранилища.
+func TestCreateCommentErrors(t *testing.T) {
+	ctx := context.Background()
+	if err := cleanTables(testStore); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+
+	postID := uuid.NewString()
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+
+	// Комментарий к несуществующему посту
+	_, err := testStore.CreateComment(ctx, uuid.NewString(), uuid.NewString(), nil, "Comment", "tester")
+	if !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("expected ErrPostNotFound, got %v", err)
+	}
+
+	// Ответ на несуществующий комментарий
+	parentID := uuid.NewString()
+	_, err = testStore.CreateComment(ctx, uuid.NewString(), postID, &parentID, "Reply", "tester")
+	if !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("expected ErrInvalidParent, got %v", err)
+	}
+
+	// Повторный ID комментария
+	commentID := uuid.NewString()
+	if _, err := testStore.CreateComment(ctx, commentID, postID, nil, "Comment", "tester"); err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+	_, err = testStore.CreateComment(ctx, commentID, postID, nil, "Comment", "tester")
+	if !errors.Is(err, ErrCommentExists) {
+		t.Errorf("expected ErrCommentExists, got %v", err)
+	}
+}
+
+// TestListComments проверяет выборку верхнеуровневых комментариев для поста.
+func TestListComments(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
 
 	// Создаём пост
 	postID := uuid.NewString()
-	if _, err := testStore.CreatePost(postID, "Test Post", "Content", "tester", true); err != nil {
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
@@ -251,45 +331,44 @@ func TestGetCommentsByPostID(t *testing.T) {
 
 	commentIDs := []string{comment1ID, comment2ID}
 	for _, cid := range commentIDs {
-		if _, err := testStore.CreateComment(cid, postID, nil, "Comment "+cid, "commenter"); err != nil {
+		if _, err := testStore.CreateComment(ctx, cid, postID, nil, "Comment "+cid, "commenter"); err != nil {
 			t.Fatalf("CreateComment %s failed: %v", cid, err)
 		}
 		time.Sleep(10 * time.Millisecond)
 	}
 	// Создаём ответ на комментарий c1 (не должен попадать в выборку верхнеуровневых)
-	parentID := uuid.NewString()
+	parentID := comment1ID
 	comment3ID := uuid.NewString()
-	if _, err := testStore.CreateComment(comment3ID, postID, &parentID, "Reply to c1", "replyer"); err != nil {
+	if _, err := testStore.CreateComment(ctx, comment3ID, postID, &parentID, "Reply to c1", "replyer"); err != nil {
 		t.Fatalf("CreateComment reply failed: %v", err)
 	}
 
-	page := 1
-	pageSize := 10
-	comments, err := testStore.GetCommentsByPostID(postID, page, pageSize)
+	page, err := testStore.ListComments(ctx, postID, nil, PageQuery{First: 10})
 	if err != nil {
-		t.Fatalf("GetCommentsByPostID failed: %v", err)
+		t.Fatalf("ListComments failed: %v", err)
 	}
 	// Ожидаем получить только два верхнеуровневых комментария
-	if len(comments) != 2 {
-		t.Errorf("expected 2 top-level comments, got %d", len(comments))
+	if len(page.Items) != 2 {
+		t.Errorf("expected 2 top-level comments, got %d", len(page.Items))
 	}
 }
 
-// TestGetCommentsByPostIDAndParentID проверяет выборку ответов (reply) на конкретный комментарий.
-func TestGetCommentsByPostIDAndParentID(t *testing.T) {
+// TestListReplies проверяет выборку ответов (reply) на конкретный комментарий.
+func TestListReplies(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
 
 	// Создаём пост
 	postID := uuid.NewString()
-	if _, err := testStore.CreatePost(postID, "Test Post", "Content", "tester", true); err != nil {
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
 	// Создаём верхнеуровневый комментарий
 	parentCommentID := uuid.NewString()
-	if _, err := testStore.CreateComment(parentCommentID, postID, nil, "Top-level comment", "commenter"); err != nil {
+	if _, err := testStore.CreateComment(ctx, parentCommentID, postID, nil, "Top-level comment", "commenter"); err != nil {
 		t.Fatalf("CreateComment failed: %v", err)
 	}
 	// Создаём два ответа на комментарий c1
@@ -297,32 +376,31 @@ func TestGetCommentsByPostIDAndParentID(t *testing.T) {
 	commentC2ID := uuid.NewString()
 	replyIDs := []string{commentC1ID, commentC2ID}
 	for _, rid := range replyIDs {
-		if _, err := testStore.CreateComment(rid, postID, &parentCommentID, "Reply "+rid, "replyer"); err != nil {
+		if _, err := testStore.CreateComment(ctx, rid, postID, &parentCommentID, "Reply "+rid, "replyer"); err != nil {
 			t.Fatalf("CreateComment reply %s failed: %v", rid, err)
 		}
 		time.Sleep(10 * time.Millisecond)
 	}
 
-	page := 1
-	pageSize := 10
-	replies, err := testStore.GetCommentsByPostIDAndParentID(postID, &parentCommentID, page, pageSize)
+	page, err := testStore.ListComments(ctx, postID, &parentCommentID, PageQuery{First: 10})
 	if err != nil {
-		t.Fatalf("GetCommentsByPostIDAndParentID failed: %v", err)
+		t.Fatalf("ListComments failed: %v", err)
 	}
-	if len(replies) != 2 {
-		t.Errorf("expected 2 replies, got %d", len(replies))
+	if len(page.Items) != 2 {
+		t.Errorf("expected 2 replies, got %d", len(page.Items))
 	}
 }
 
 // TestSubscribeAndPublish проверяет работу подписки на уведомления о новых комментариях и их публикацию.
 func TestSubscribeAndPublish(t *testing.T) {
+	ctx := context.Background()
 	if err := cleanTables(testStore); err != nil {
 		t.Fatalf("failed to clean tables: %v", err)
 	}
 
 	// Создаём пост
 	postID := uuid.NewString()
-	if _, err := testStore.CreatePost(postID, "Test Post", "Content", "tester", true); err != nil {
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
 		t.Fatalf("CreatePost failed: %v", err)
 	}
 
@@ -339,7 +417,7 @@ func TestSubscribeAndPublish(t *testing.T) {
 	}
 
 	// Подписываемся на уведомления для поста
-	ch, unsubscribe := testStore.Subscribe(postID)
+	ch, unsubscribe := testStore.Subscribe(ctx, postID)
 	if ch == nil || unsubscribe == nil {
 		t.Fatalf("Subscribe returned nil channel or unsubscribe function")
 	}
@@ -349,14 +427,19 @@ func TestSubscribeAndPublish(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 
 	// Публикуем комментарий
-	testStore.Publish(commentToPublish)
+	eventID := uuid.NewString()
+	testStore.Publish(ctx, NewCommentEvent(eventID, EventCommentAdded, commentToPublish))
 
 	// Ждём уведомления с таймаутом
 	select {
-	case receivedComment := <-ch:
+	case receivedEvent := <-ch:
 		// Если уведомление получено, проверяем его содержимое.
 		// Обратите внимание: Publish использует JSON, поэтому могут быть неточности с формированием времени.
 		// Здесь проверяем только основные поля.
+		if receivedEvent.ID != eventID || receivedEvent.Type != EventCommentAdded || receivedEvent.Version != EventVersion {
+			t.Errorf("received event envelope does not match published event: %+v", receivedEvent)
+		}
+		receivedComment := receivedEvent.Comment
 		if receivedComment.ID != commentID || receivedComment.Content != content || receivedComment.Author != author {
 			t.Errorf("received comment does not match published comment: %+v", receivedComment)
 		}
@@ -364,3 +447,136 @@ func TestSubscribeAndPublish(t *testing.T) {
 		t.Errorf("timed out waiting for published comment")
 	}
 }
+
+// TestSubscriptionsShareListener проверяет, что подписки не занимают соединения пула
+// и LISTEN/UNLISTEN выполняются по числу подписчиков канала.
+func TestSubscriptionsShareListener(t *testing.T) {
+	ctx := context.Background()
+	if err := cleanTables(testStore); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+
+	postID := uuid.NewString()
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+
+	acquiredBefore := testStore.DB.Stat().AcquiredConns()
+
+	_, unsubscribe1 := testStore.Subscribe(ctx, postID)
+	_, unsubscribe2 := testStore.Subscribe(ctx, postID)
+
+	stats := testStore.ListenerStats()
+	if !stats.Connected {
+		t.Fatalf("listener is not connected: %+v", stats)
+	}
+	if got := stats.PerChannel[commentsChannel(postID)]; got != 2 {
+		t.Errorf("expected 2 subscribers, got %d", got)
+	}
+	if stats.Channels != 1 {
+		t.Errorf("expected 1 listened channel, got %d", stats.Channels)
+	}
+	if acquired := testStore.DB.Stat().AcquiredConns(); acquired != acquiredBefore {
+		t.Errorf("subscriptions must not hold pool connections: %d acquired, %d before", acquired, acquiredBefore)
+	}
+
+	unsubscribe1()
+	unsubscribe2()
+
+	// UNLISTEN выполняется асинхронно циклом listener
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		stats = testStore.ListenerStats()
+		if stats.Channels == 0 && stats.Subscribers == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("channel was not unlistened: %+v", stats)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestPublishQuotedContent проверяет, что кавычки в комментарии не ломают уведомление.
+func TestPublishQuotedContent(t *testing.T) {
+	ctx := context.Background()
+	if err := cleanTables(testStore); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+
+	postID := uuid.NewString()
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+
+	ch, unsubscribe := testStore.Subscribe(ctx, postID)
+	defer unsubscribe()
+
+	content := `It's "quoted"'); NOTIFY "x", 'injected`
+	comment := &Comment{ID: uuid.NewString(), PostID: postID, Content: content, Author: "o'brien", CreatedAt: time.Now()}
+	testStore.Publish(ctx, NewCommentEvent(uuid.NewString(), EventCommentAdded, comment))
+
+	select {
+	case event := <-ch:
+		received := event.Comment
+		if received.Content != content || received.Author != "o'brien" {
+			t.Errorf("received comment does not match published comment: %+v", received)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("timed out waiting for published comment")
+	}
+}
+
+// TestPublishLargeComment проверяет, что комментарий больше лимита NOTIFY
+// доставляется через загрузку из базы по ID.
+func TestPublishLargeComment(t *testing.T) {
+	ctx := context.Background()
+	if err := cleanTables(testStore); err != nil {
+		t.Fatalf("failed to clean tables: %v", err)
+	}
+
+	postID := uuid.NewString()
+	if _, err := testStore.CreatePost(ctx, postID, "Test Post", "Content", "tester", true); err != nil {
+		t.Fatalf("CreatePost failed: %v", err)
+	}
+
+	// Кириллица с кавычками: после экранирования в JSON больше 8000 байт
+	content := strings.Repeat("ж\"", 2000)
+	comment, err := testStore.CreateComment(ctx, uuid.NewString(), postID, nil, content, "commenter")
+	if err != nil {
+		t.Fatalf("CreateComment failed: %v", err)
+	}
+
+	event := NewCommentEvent(uuid.NewString(), EventCommentAdded, comment)
+	payload, err := encodeNotification(event)
+	if err != nil {
+		t.Fatalf("encodeNotification failed: %v", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		t.Fatalf("payload is too large: %d bytes", len(payload))
+	}
+	var notification CommentEvent
+	if err := json.Unmarshal([]byte(payload), &notification); err != nil {
+		t.Fatalf("could not unmarshal payload: %v", err)
+	}
+	if notification.Comment != nil || notification.CommentID != comment.ID || notification.ID != event.ID {
+		t.Fatalf("expected payload with comment ID only, got %s", payload)
+	}
+
+	ch, unsubscribe := testStore.Subscribe(ctx, postID)
+	defer unsubscribe()
+
+	testStore.Publish(ctx, event)
+
+	select {
+	case received := <-ch:
+		if received.ID != event.ID || received.Comment == nil {
+			t.Fatalf("received event does not match published event: %+v", received)
+		}
+		if received.Comment.ID != comment.ID || received.Comment.Content != content {
+			t.Errorf("received comment does not match published comment: %+v", received.Comment.ID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("timed out waiting for published comment")
+	}
+}
@@ -0,0 +1,33 @@
+package store
+
+import "slices"
+
+// RelatedScore — сходство поста с другим постом
+type RelatedScore struct {
+	PostID string  `json:"post_id"` // ID другого поста
+	Score  float64 `json:"score"`   // Сходство от 0 до 1
+}
+
+// RelatedPost — похожий пост
+type RelatedPost struct {
+	Post  *Post
+	Score float64 // Сходство от 0 до 1
+}
+
+// sortRelated упорядочивает похожие посты от более похожих к менее,
+// при равном сходстве — от новых к старым
+func sortRelated(related []*RelatedPost) {
+	slices.SortFunc(related, func(a, b *RelatedPost) int {
+		switch {
+		case a.Score != b.Score:
+			if a.Score > b.Score {
+				return -1
+			}
+			return 1
+		case newestFirst.less(b.Post.Cursor(), a.Post.Cursor()):
+			return -1
+		default:
+			return 1
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package store
+
+import "time"
+
+// PostUpdate — новые заголовок и текст поста
+type PostUpdate struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Editor  string `json:"editor"` // Кто изменяет пост
+}
+
+// PostRevision — версия поста, сохранённая при его изменении
+// Number — номер версии: у исходного текста 1, у текста после
+// n-го изменения — n+1. Текущая версия поста ревизией не хранится
+type PostRevision struct {
+	PostID   string    `json:"post_id"`
+	Number   int       `json:"number"`
+	Title    string    `json:"title"`     // Заголовок до изменения
+	Content  string    `json:"content"`   // Текст до изменения
+	Editor   string    `json:"editor"`    // Кто изменил пост
+	EditedAt time.Time `json:"edited_at"` // Когда пост изменён
+}
@@ -0,0 +1,226 @@
+package store
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// SearchKind — вид записей, среди которых идёт поиск
+type SearchKind int
+
+const (
+	SearchPosts SearchKind = iota
+	SearchComments
+)
+
+// SearchQuery — поисковый запрос
+// Текст разбивается на слова, найденная запись содержит их все.
+// Пустой Kinds означает поиск среди всех видов записей
+type SearchQuery struct {
+	Text  string
+	Kinds []SearchKind
+}
+
+// includes сообщает, ищутся ли записи вида kind
+func (q SearchQuery) includes(kind SearchKind) bool {
+	return len(q.Kinds) == 0 || slices.Contains(q.Kinds, kind)
+}
+
+// SearchHit — найденный пост или комментарий
+// Score имеет смысл только для сравнения результатов одного запроса
+type SearchHit struct {
+	Post    *Post    // Найденный пост; nil, если найден комментарий
+	Comment *Comment // Найденный комментарий; nil, если найден пост
+	Score   float64  // Релевантность: чем больше, тем выше в выдаче
+	Snippet string   // Фрагмент текста, найденные слова выделены <b>…</b>
+}
+
+// Cursor возвращает позицию результата в выдаче
+func (h *SearchHit) Cursor() Cursor {
+	if h.Post != nil {
+		return Cursor{CreatedAt: h.Post.CreatedAt, ID: h.Post.ID, Score: h.Score}
+	}
+	return Cursor{CreatedAt: h.Comment.CreatedAt, ID: h.Comment.ID, Score: h.Score}
+}
+
+// searchOrder — порядок выдачи поиска: от более релевантных результатов к менее
+var searchOrder = sortKey{byScore: true, desc: true}
+
+// Оформление фрагментов найденного текста
+const (
+	snippetWords   = 16 // Число слов во фрагменте
+	highlightStart = "<b>"
+	highlightStop  = "</b>"
+	ellipsis       = "…" // Отмечает обрезанный текст
+
+	// Метки, которыми базы данных отмечают найденные слова во фрагменте.
+	// Управляющие символы не встречаются в обычном тексте
+	matchStart = "\x02"
+	matchStop  = "\x03"
+)
+
+// highlighter экранирует HTML во фрагменте из базы данных, как html.EscapeString,
+// и заменяет метки найденных слов выделением
+var highlighter = strings.NewReplacer(
+	matchStart, highlightStart,
+	matchStop, highlightStop,
+	"&", "&amp;",
+	"<", "&lt;",
+	">", "&gt;",
+	`"`, "&#34;",
+	"'", "&#39;",
+)
+
+// splitWords разбивает текст на слова в нижнем регистре
+// Словом считается последовательность букв и цифр
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchTerms разбивает поисковый запрос на слова без повторов
+func searchTerms(text string) []string {
+	words := splitWords(text)
+	slices.Sort(words)
+	return slices.Compact(words)
+}
+
+// snippet вырезает из текста фрагмент вокруг первого найденного слова
+// и выделяет в нём найденные слова. Текст экранируется, выделение — нет
+func snippet(text string, terms []string) string {
+	words := strings.Fields(text)
+	found := func(word string) bool {
+		for _, term := range splitWords(word) {
+			if slices.Contains(terms, term) {
+				return true
+			}
+		}
+		return false
+	}
+
+	first := slices.IndexFunc(words, found)
+	start := max(first-snippetWords/4, 0)
+	end := min(start+snippetWords, len(words))
+	start = max(end-snippetWords, 0)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(ellipsis)
+	}
+	for i, word := range words[start:end] {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if found(word) {
+			b.WriteString(highlightStart + html.EscapeString(word) + highlightStop)
+		} else {
+			b.WriteString(html.EscapeString(word))
+		}
+	}
+	if end < len(words) {
+		b.WriteString(ellipsis)
+	}
+	return b.String()
+}
+
+// searchDoc — запись в обратном индексе
+type searchDoc struct {
+	kind SearchKind
+	id   string
+}
+
+// searchIndex — обратный индекс MemoryStore: слово → записи, где оно встречается
+// Релевантность записи — сумма весов слов запроса в записи. Она не зависит
+// от других записей, поэтому курсоры не сдвигаются при добавлении новых
+type searchIndex struct {
+	terms map[string]map[searchDoc]float64 // Вес слова в записях
+}
+
+// Вес вхождения слова: слова заголовка поста важнее слов текста
+const (
+	titleWeight   = 2
+	contentWeight = 1
+)
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{terms: make(map[string]map[searchDoc]float64)}
+}
+
+// addPost добавляет пост в индекс
+func (x *searchIndex) addPost(post *Post) {
+	doc := searchDoc{kind: SearchPosts, id: post.ID}
+	x.add(doc, post.Title, titleWeight)
+	x.add(doc, post.Content, contentWeight)
+}
+
+// removePost удаляет пост из индекса, например перед изменением его текста
+func (x *searchIndex) removePost(post *Post) {
+	doc := searchDoc{kind: SearchPosts, id: post.ID}
+	x.remove(doc, post.Title)
+	x.remove(doc, post.Content)
+}
+
+// addComment добавляет комментарий в индекс
+// Удалённые комментарии, оставленные в ветке, не индексируются
+func (x *searchIndex) addComment(comment *Comment) {
+	if comment.DeletedAt != nil {
+		return
+	}
+	x.add(searchDoc{kind: SearchComments, id: comment.ID}, comment.Content, contentWeight)
+}
+
+// removeComment удаляет комментарий из индекса
+func (x *searchIndex) removeComment(comment *Comment) {
+	x.remove(searchDoc{kind: SearchComments, id: comment.ID}, comment.Content)
+}
+
+func (x *searchIndex) add(doc searchDoc, text string, weight float64) {
+	for _, word := range splitWords(text) {
+		docs, ok := x.terms[word]
+		if !ok {
+			docs = make(map[searchDoc]float64)
+			x.terms[word] = docs
+		}
+		docs[doc] += weight
+	}
+}
+
+func (x *searchIndex) remove(doc searchDoc, text string) {
+	for _, word := range splitWords(text) {
+		docs := x.terms[word]
+		delete(docs, doc)
+		if len(docs) == 0 {
+			delete(x.terms, word)
+		}
+	}
+}
+
+// match возвращает записи, содержащие все слова terms, с их релевантностью
+func (x *searchIndex) match(terms []string) map[searchDoc]float64 {
+	if len(terms) == 0 {
+		return nil
+	}
+	// Перебор начинается с самого редкого слова: у него меньше всего записей
+	terms = slices.Clone(terms)
+	slices.SortFunc(terms, func(a, b string) int { return len(x.terms[a]) - len(x.terms[b]) })
+
+	scores := make(map[searchDoc]float64, len(x.terms[terms[0]]))
+	for doc := range x.terms[terms[0]] {
+		scores[doc] = 0
+	}
+	for _, term := range terms {
+		docs := x.terms[term]
+		for doc := range scores {
+			weight, ok := docs[doc]
+			if !ok {
+				delete(scores, doc)
+				continue
+			}
+			scores[doc] += weight
+		}
+	}
+	return scores
+}
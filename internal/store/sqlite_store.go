@@ -0,0 +1,1092 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/migrate"
+	"modernc.org/sqlite" // Драйвер SQLite на чистом Go
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteStore — файловое хранилище на SQLite
+// События о комментариях рассылаются внутри процесса
+type SQLiteStore struct {
+	DB     *sql.DB
+	events *broker // Подписчики на события комментариев
+}
+
+// NewSQLiteStore открывает (или создаёт) базу SQLite по пути path
+// и применяет к ней встроенные миграции
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	// WAL позволяет читать во время записи, busy_timeout — ждать
+	// освобождения блокировки, а _txlock=immediate — сразу брать
+	// блокировку на запись в транзакциях
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite db: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not ping sqlite db: %w", err)
+	}
+
+	// Схема небольшой локальной базы всегда обновляется при запуске
+	migrator, err := migrate.NewSQLite(db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not load migrations: %w", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not migrate sqlite db: %w", err)
+	}
+
+	return &SQLiteStore{DB: db, events: newBroker()}, nil
+}
+
+// Close закрывает базу данных
+func (s *SQLiteStore) Close() error {
+	return s.DB.Close()
+}
+
+// Создание поста
+// allowComments — разрешены ли комментарии к посту
+func (s *SQLiteStore) CreatePost(ctx context.Context, id, title, content, author string, allowComments bool) (*Post, error) {
+	post := &Post{
+		ID:            id,
+		Title:         title,
+		Content:       content,
+		Author:        author,
+		CreatedAt:     time.Now(),
+		AllowComments: allowComments,
+	}
+
+	query := `
+		INSERT INTO posts (id, title, content, author, allow_comments, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		`
+	// Выполнение запроса
+	if _, err := s.DB.ExecContext(ctx, query, id, title, content, author, allowComments, post.CreatedAt.UnixNano()); err != nil {
+		return nil, sqliteError("could not create post", err, ErrPostNotFound, ErrPostExists)
+	}
+
+	return post, nil
+}
+
+// Получение страницы постов по фильтру в порядке order
+func (s *SQLiteStore) FindPosts(ctx context.Context, filter PostFilter, order PostOrder, page PageQuery) (*Page[*Post], error) {
+	conds, args := filter.conditions(sqliteDialect, nil)
+	clause, args := sqliteDialect.pageClause(order.sortKey(), page, conds, args)
+	query := `
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		FROM posts` + clause
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	posts := make([]*Post, 0)
+	for rows.Next() {
+		post, err := scanSQLitePost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not read post: %w", err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+
+	return newPage(posts, page), nil
+}
+
+// Количество постов по фильтру
+func (s *SQLiteStore) CountPosts(ctx context.Context, filter PostFilter) (int, error) {
+	conds, args := filter.conditions(sqliteDialect, nil)
+	query := `SELECT COUNT(*) FROM posts` + whereClause(conds)
+
+	var count int
+	if err := s.DB.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not count posts: %w", err)
+	}
+	return count, nil
+}
+
+// Получение поста по ID
+func (s *SQLiteStore) GetPostByID(ctx context.Context, id string) (*Post, error) {
+	query := `
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		FROM posts
+		WHERE id = ? AND deleted_at IS NULL
+		`
+	// Выполнение запроса
+	post, err := scanSQLitePost(s.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, sqliteError("could not get post", err, ErrPostNotFound, ErrPostExists)
+	}
+
+	return post, nil
+}
+
+// Обновление разрешения на комментарии к посту
+func (s *SQLiteStore) UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*Post, error) {
+	query := `
+		UPDATE posts
+		SET allow_comments = ?
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	post, err := scanSQLitePost(s.DB.QueryRowContext(ctx, query, allowComments, postID))
+	if err != nil {
+		return nil, sqliteError("could not update post", err, ErrPostNotFound, ErrPostExists)
+	}
+
+	return post, nil
+}
+
+// Изменение заголовка и текста поста
+// Прежние заголовок и текст сохраняются ревизией в той же транзакции
+func (s *SQLiteStore) UpdatePost(ctx context.Context, postID string, update PostUpdate) (*Post, error) {
+	editedAt := time.Now()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not update post: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Запись ревизии блокирует базу на запись до конца транзакции,
+	// поэтому номер следующей ревизии считается без гонок
+	revision := `
+		INSERT INTO post_revisions (post_id, number, title, content, editor, edited_at)
+		SELECT id, (SELECT COALESCE(MAX(number), 0) + 1 FROM post_revisions WHERE post_id = ?1), title, content, ?2, ?3
+		FROM posts
+		WHERE id = ?1 AND deleted_at IS NULL
+		`
+	result, err := tx.ExecContext(ctx, revision, postID, update.Editor, editedAt.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("could not save post revision: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, ErrPostNotFound
+	}
+
+	query := `
+		UPDATE posts
+		SET title = ?, content = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	post, err := scanSQLitePost(tx.QueryRowContext(ctx, query, update.Title, update.Content, editedAt.UnixNano(), postID))
+	if err != nil {
+		return nil, sqliteError("could not update post", err, ErrPostNotFound, ErrPostExists)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not update post: %w", err)
+	}
+
+	return post, nil
+}
+
+// Получение ревизий поста
+func (s *SQLiteStore) ListPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error) {
+	query := `
+		SELECT post_id, number, title, content, editor, edited_at
+		FROM post_revisions
+		WHERE post_id = ?
+		ORDER BY number
+		`
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, postID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get post revisions: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	revisions := make([]*PostRevision, 0)
+	for rows.Next() {
+		r := &PostRevision{}
+		var editedAt int64
+		if err := rows.Scan(&r.PostID, &r.Number, &r.Title, &r.Content, &r.Editor, &editedAt); err != nil {
+			return nil, fmt.Errorf("could not read post revision: %w", err)
+		}
+		r.EditedAt = time.Unix(0, editedAt)
+		revisions = append(revisions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get post revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// Удаление поста в корзину
+func (s *SQLiteStore) DeletePost(ctx context.Context, postID string) (*Post, error) {
+	query := `
+		UPDATE posts
+		SET deleted_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	post, err := scanSQLitePost(s.DB.QueryRowContext(ctx, query, time.Now().UnixNano(), postID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not delete post: %w", err)
+	}
+
+	return post, nil
+}
+
+// Восстановление поста из корзины
+func (s *SQLiteStore) RestorePost(ctx context.Context, postID string) (*Post, error) {
+	query := `
+		UPDATE posts
+		SET deleted_at = NULL
+		WHERE id = ? AND deleted_at IS NOT NULL
+		RETURNING id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		`
+	// Выполнение запроса
+	post, err := scanSQLitePost(s.DB.QueryRowContext(ctx, query, postID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPostNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not restore post: %w", err)
+	}
+
+	return post, nil
+}
+
+// Окончательное удаление постов, удалённых в корзину раньше deletedBefore
+// Комментарии, ревизии и сходство постов удаляются каскадно
+func (s *SQLiteStore) PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int, error) {
+	query := `DELETE FROM posts WHERE deleted_at < ?`
+	// Выполнение запроса
+	result, err := s.DB.ExecContext(ctx, query, deletedBefore.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("could not purge posts: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("could not purge posts: %w", err)
+	}
+	return int(count), nil
+}
+
+// Сохранение сходства поста с другими постами
+// Пары записываются в обе стороны, повторная запись обновляет сходство
+func (s *SQLiteStore) AddRelatedPosts(ctx context.Context, postID string, related []RelatedScore) error {
+	if len(related) == 0 {
+		return nil
+	}
+	rows := make([]string, 0, 2*len(related))
+	args := make([]any, 0, 6*len(related))
+	for _, r := range related {
+		rows = append(rows, "(?, ?, ?)", "(?, ?, ?)")
+		args = append(args, postID, r.PostID, r.Score, r.PostID, postID, r.Score)
+	}
+
+	query := `
+		INSERT INTO related_posts (post_id, related_id, score)
+		VALUES ` + strings.Join(rows, ", ") + `
+		ON CONFLICT (post_id, related_id) DO UPDATE SET score = excluded.score
+		`
+	// Выполнение запроса
+	if _, err := s.DB.ExecContext(ctx, query, args...); err != nil {
+		if sqliteCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return ErrPostNotFound
+		}
+		return fmt.Errorf("could not add related posts: %w", err)
+	}
+	return nil
+}
+
+// Получение похожих постов
+func (s *SQLiteStore) ListRelatedPosts(ctx context.Context, postID string, limit int) ([]*RelatedPost, error) {
+	query := `
+		SELECT p.id, p.title, p.content, p.author, p.allow_comments, p.created_at, p.comment_count, p.top_level_comment_count, p.updated_at, r.score
+		FROM related_posts r
+		JOIN posts p ON p.id = r.related_id
+		WHERE r.post_id = ? AND p.deleted_at IS NULL
+		ORDER BY r.score DESC, p.created_at DESC, p.id DESC
+		LIMIT ?
+		`
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, postID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get related posts: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	related := make([]*RelatedPost, 0)
+	for rows.Next() {
+		post := &Post{}
+		r := &RelatedPost{Post: post}
+		var createdAt int64
+		var updatedAt sql.NullInt64
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &createdAt, &post.CommentCount, &post.TopLevelCommentCount, &updatedAt, &r.Score); err != nil {
+			return nil, fmt.Errorf("could not read related post: %w", err)
+		}
+		post.CreatedAt = time.Unix(0, createdAt)
+		post.UpdatedAt = sqliteTime(updatedAt)
+		related = append(related, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get related posts: %w", err)
+	}
+	return related, nil
+}
+
+// Создание комментария
+// parentID — ID родительского комментария
+func (s *SQLiteStore) CreateComment(ctx context.Context, id, postID string, parentID *string, content, author string) (*Comment, error) {
+	comment := &Comment{
+		ID:        id,
+		PostID:    postID,
+		ParentID:  parentID,
+		Content:   content,
+		Author:    author,
+		CreatedAt: time.Now(),
+	}
+
+	// Глубина и корень ветки берутся у родителя. Родитель ищется
+	// только среди комментариев того же поста: если его нет,
+	// запрос ничего не вставляет. Пост в корзине тоже не принимает комментарии
+	query := `
+		INSERT INTO comments (id, post_id, parent_id, content, author, depth, root_id, created_at)
+		SELECT ?1, ?2, ?3, ?4, ?5, COALESCE(parent.depth + 1, 0), COALESCE(parent.root_id, ?1), ?6
+		FROM posts post
+		LEFT JOIN comments parent ON parent.id = ?3 AND parent.post_id = post.id
+		WHERE post.id = ?2 AND post.deleted_at IS NULL
+			AND (?3 IS NULL OR parent.id IS NOT NULL)
+		RETURNING depth, root_id
+		`
+	// Комментарий и счётчики комментариев записываются в одной транзакции
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create comment: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Выполнение запроса
+	row := tx.QueryRowContext(ctx, query, id, postID, parentID, content, author, comment.CreatedAt.UnixNano())
+	if err := row.Scan(&comment.Depth, &comment.RootID); err != nil {
+		// Родитель проверен запросом, значит нарушен внешний ключ на пост
+		if sqliteCode(err) == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY {
+			return nil, ErrPostNotFound
+		}
+		// Запрос ничего не вставил: нет поста или родителя
+		if errors.Is(err, sql.ErrNoRows) {
+			var visible bool
+			check := `SELECT count(*) > 0 FROM posts WHERE id = ? AND deleted_at IS NULL`
+			if err := tx.QueryRowContext(ctx, check, postID).Scan(&visible); err != nil {
+				return nil, fmt.Errorf("could not create comment: %w", err)
+			}
+			if !visible {
+				return nil, ErrPostNotFound
+			}
+		}
+		return nil, sqliteError("could not create comment", err, ErrInvalidParent, ErrCommentExists)
+	}
+	counted := `
+		UPDATE posts
+		SET comment_count = comment_count + 1,
+			top_level_comment_count = top_level_comment_count + (?2 IS NULL)
+		WHERE id = ?1
+		`
+	if _, err := tx.ExecContext(ctx, counted, postID, parentID); err != nil {
+		return nil, fmt.Errorf("could not update comment count: %w", err)
+	}
+	// Ответ увеличивает счётчики родителя и всех комментариев выше по ветке
+	replied := `
+		WITH RECURSIVE ancestors(id, parent_id) AS (
+			SELECT id, parent_id FROM comments WHERE id = ?1
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		)
+		UPDATE comments
+		SET reply_count = reply_count + (id = ?1),
+			descendant_count = descendant_count + 1
+		WHERE id IN (SELECT id FROM ancestors)
+		`
+	if parentID != nil {
+		if _, err := tx.ExecContext(ctx, replied, *parentID); err != nil {
+			return nil, fmt.Errorf("could not update reply count: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not create comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// Получение комментария по ID
+func (s *SQLiteStore) GetCommentByID(ctx context.Context, id string) (*Comment, error) {
+	query := `
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments
+		WHERE id = ? AND ` + visibleComment
+	// Выполнение запроса
+	comment, err := scanSQLiteComment(s.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, sqliteError("could not get comment", err, ErrCommentNotFound, ErrCommentExists)
+	}
+
+	return comment, nil
+}
+
+// Получение страницы комментариев верхнего уровня или ответов на parentID
+func (s *SQLiteStore) ListComments(ctx context.Context, postID string, parentID *string, page PageQuery) (*Page[*Comment], error) {
+	conds, args := sqliteCommentsFilter(postID, parentID)
+	clause, args := sqliteDialect.pageClause(newestFirst, page, conds, args)
+	query := `
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments` + clause
+
+	comments, err := s.queryComments(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(comments, page), nil
+}
+
+// Количество комментариев верхнего уровня или ответов на parentID
+func (s *SQLiteStore) CountComments(ctx context.Context, postID string, parentID *string) (int, error) {
+	conds, args := sqliteCommentsFilter(postID, parentID)
+	query := `SELECT COUNT(*) FROM comments WHERE ` + strings.Join(conds, " AND ")
+
+	var count int
+	if err := s.DB.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("could not count comments: %w", err)
+	}
+	return count, nil
+}
+
+// Поиск постов и комментариев по словам запроса
+// Релевантность считает bm25 по индексам FTS5, их поддерживают триггеры
+func (s *SQLiteStore) Search(ctx context.Context, search SearchQuery, page PageQuery) (*Page[*SearchHit], error) {
+	terms := searchTerms(search.Text)
+	if len(terms) == 0 {
+		return newPage([]*SearchHit{}, page), nil
+	}
+
+	// Слова берутся в кавычки, чтобы не разбирались как операторы FTS5.
+	// Все слова запроса должны встретиться в записи
+	match := `"` + strings.Join(terms, `" "`) + `"`
+	// bm25 тем меньше, чем запись релевантнее, поэтому берётся со знаком минус.
+	// Заголовок поста весит вдвое больше текста, столбец id не индексируется
+	var sources []string
+	var args []any
+	if search.includes(SearchPosts) {
+		sources = append(sources, `
+			SELECT 'post' AS kind, p.id, p.created_at, -bm25(posts_fts, 0, 2, 1) AS score,
+				snippet(posts_fts, -1, ?, ?, ?, ?) AS snippet
+			FROM posts_fts JOIN posts p ON p.id = posts_fts.id
+			WHERE posts_fts MATCH ? AND p.deleted_at IS NULL`)
+		args = append(args, matchStart, matchStop, ellipsis, snippetWords, match)
+	}
+	if search.includes(SearchComments) {
+		sources = append(sources, `
+			SELECT 'comment' AS kind, c.id, c.created_at, -bm25(comments_fts) AS score,
+				snippet(comments_fts, -1, ?, ?, ?, ?) AS snippet
+			FROM comments_fts JOIN comments c ON c.id = comments_fts.id
+			JOIN posts p ON p.id = c.post_id AND p.deleted_at IS NULL
+			WHERE comments_fts MATCH ? AND c.deleted_at IS NULL`)
+		args = append(args, matchStart, matchStop, ellipsis, snippetWords, match)
+	}
+	clause, args := sqliteDialect.pageClause(searchOrder, page, nil, args)
+	query := `
+		WITH hits AS (` + strings.Join(sources, " UNION ALL ") + `)
+		SELECT kind, id, score, snippet FROM hits` + clause
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not search: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	hits := make([]*SearchHit, 0)
+	var postIDs, commentIDs []string
+	for rows.Next() {
+		var kind, id string
+		hit := &SearchHit{}
+		if err := rows.Scan(&kind, &id, &hit.Score, &hit.Snippet); err != nil {
+			return nil, fmt.Errorf("could not read search result: %w", err)
+		}
+		hit.Snippet = highlighter.Replace(hit.Snippet)
+		if kind == "post" {
+			hit.Post = &Post{ID: id}
+			postIDs = append(postIDs, id)
+		} else {
+			hit.Comment = &Comment{ID: id}
+			commentIDs = append(commentIDs, id)
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not search: %w", err)
+	}
+	rows.Close()
+
+	// Найденные записи загружаются отдельными запросами по ID
+	posts, err := s.GetPostsByIDs(ctx, postIDs)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.GetCommentsByIDs(ctx, commentIDs)
+	if err != nil {
+		return nil, err
+	}
+	found := hits[:0]
+	for _, hit := range hits {
+		// Запись могла быть удалена между запросами
+		if hit.Post != nil {
+			hit.Post = posts[hit.Post.ID]
+		} else {
+			hit.Comment = comments[hit.Comment.ID]
+		}
+		if hit.Post != nil || hit.Comment != nil {
+			found = append(found, hit)
+		}
+	}
+
+	return newPage(found, page), nil
+}
+
+// Получение постов по ID, ненайденных постов в результате нет
+func (s *SQLiteStore) GetPostsByIDs(ctx context.Context, ids []string) (map[string]*Post, error) {
+	posts := make(map[string]*Post, len(ids))
+	if len(ids) == 0 {
+		return posts, nil
+	}
+	query := `
+		SELECT id, title, content, author, allow_comments, created_at, comment_count, top_level_comment_count, updated_at, deleted_at
+		FROM posts
+		WHERE id IN (` + sqlitePlaceholders(len(ids)) + `) AND deleted_at IS NULL`
+	rows, err := s.DB.QueryContext(ctx, query, sqliteArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		post, err := scanSQLitePost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not read post: %w", err)
+		}
+		posts[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get posts: %w", err)
+	}
+	return posts, nil
+}
+
+// Получение комментариев по ID, ненайденных комментариев в результате нет
+func (s *SQLiteStore) GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*Comment, error) {
+	comments := make(map[string]*Comment, len(ids))
+	if len(ids) == 0 {
+		return comments, nil
+	}
+	query := `
+		SELECT id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		FROM comments
+		WHERE id IN (` + sqlitePlaceholders(len(ids)) + `) AND ` + visibleComment
+	list, err := s.queryComments(ctx, query, sqliteArgs(ids)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range list {
+		comments[comment.ID] = comment
+	}
+	return comments, nil
+}
+
+// Изменение текста комментария
+// Прежний текст сохраняется в истории правок в той же транзакции
+func (s *SQLiteStore) UpdateComment(ctx context.Context, id, content string) (*Comment, error) {
+	editedAt := time.Now()
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not update comment: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Запись правки блокирует базу на запись до конца транзакции,
+	// поэтому номер следующей правки считается без гонок
+	edit := `
+		INSERT INTO comment_edits (comment_id, number, content, edited_at)
+		SELECT id, (SELECT COALESCE(MAX(number), 0) + 1 FROM comment_edits WHERE comment_id = ?1), content, ?2
+		FROM comments
+		WHERE id = ?1 AND deleted_at IS NULL AND ` + visibleComment
+	result, err := tx.ExecContext(ctx, edit, id, editedAt.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("could not save comment edit: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, ErrCommentNotFound
+	}
+
+	query := `
+		UPDATE comments
+		SET content = ?, edited_at = ?
+		WHERE id = ?
+		RETURNING id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+		`
+	// Выполнение запроса
+	comment, err := scanSQLiteComment(tx.QueryRowContext(ctx, query, content, editedAt.UnixNano(), id))
+	if err != nil {
+		return nil, sqliteError("could not update comment", err, ErrCommentNotFound, ErrCommentExists)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not update comment: %w", err)
+	}
+
+	return comment, nil
+}
+
+// Получение правок нескольких комментариев
+func (s *SQLiteStore) ListCommentEdits(ctx context.Context, commentIDs []string) (map[string][]*CommentEdit, error) {
+	edits := make(map[string][]*CommentEdit, len(commentIDs))
+	if len(commentIDs) == 0 {
+		return edits, nil
+	}
+	query := `
+		SELECT comment_id, number, content, edited_at
+		FROM comment_edits
+		WHERE comment_id IN (` + sqlitePlaceholders(len(commentIDs)) + `)
+		ORDER BY comment_id, number`
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, sqliteArgs(commentIDs)...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get comment edits: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	for rows.Next() {
+		e := &CommentEdit{}
+		var editedAt int64
+		if err := rows.Scan(&e.CommentID, &e.Number, &e.Content, &editedAt); err != nil {
+			return nil, fmt.Errorf("could not read comment edit: %w", err)
+		}
+		e.EditedAt = time.Unix(0, editedAt)
+		edits[e.CommentID] = append(edits[e.CommentID], e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get comment edits: %w", err)
+	}
+	return edits, nil
+}
+
+// Удаление комментария
+// Комментарий с ответами остаётся в ветке без текста и автора,
+// комментарий без ответов удаляется вместе с ненужными удалёнными предками
+func (s *SQLiteStore) DeleteComment(ctx context.Context, id, reason string) (*Comment, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+	defer tx.Rollback()
+
+	var replies int
+	selected := `SELECT reply_count FROM comments WHERE id = ? AND deleted_at IS NULL AND ` + visibleComment
+	if err := tx.QueryRowContext(ctx, selected, id).Scan(&replies); err != nil {
+		return nil, sqliteError("could not delete comment", err, ErrCommentNotFound, ErrCommentExists)
+	}
+
+	var kept *Comment
+	if replies > 0 {
+		query := `
+			UPDATE comments
+			SET content = ?2, author = ?2, edited_at = NULL, deleted_at = ?3, delete_reason = ?4
+			WHERE id = ?1
+			RETURNING id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason
+			`
+		kept, err = scanSQLiteComment(tx.QueryRowContext(ctx, query, id, DeletedPlaceholder, time.Now().UnixNano(), reason))
+		if err != nil {
+			return nil, sqliteError("could not delete comment", err, ErrCommentNotFound, ErrCommentExists)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comment_edits WHERE comment_id = ?`, id); err != nil {
+			return nil, fmt.Errorf("could not delete comment edits: %w", err)
+		}
+	} else {
+		// Удалённые комментарии без ответов в ветке больше не нужны
+		for next := &id; next != nil; {
+			if next, err = dropSQLiteComment(ctx, tx, *next); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+
+	return kept, nil
+}
+
+// dropSQLiteComment удаляет комментарий без ответов и обновляет счётчики поста
+// и комментариев выше по ветке. Возвращает ID родителя, если он удалён
+// и у него не осталось ответов
+func dropSQLiteComment(ctx context.Context, tx *sql.Tx, id string) (*string, error) {
+	var postID string
+	var parentID sql.NullString
+	removed := `DELETE FROM comments WHERE id = ? RETURNING post_id, parent_id`
+	if err := tx.QueryRowContext(ctx, removed, id).Scan(&postID, &parentID); err != nil {
+		return nil, fmt.Errorf("could not delete comment: %w", err)
+	}
+	counted := `
+		UPDATE posts
+		SET comment_count = comment_count - 1,
+			top_level_comment_count = top_level_comment_count - (?2 IS NULL)
+		WHERE id = ?1
+		`
+	if _, err := tx.ExecContext(ctx, counted, postID, parentID); err != nil {
+		return nil, fmt.Errorf("could not update comment count: %w", err)
+	}
+	if !parentID.Valid {
+		return nil, nil
+	}
+
+	replied := `
+		WITH RECURSIVE ancestors(id, parent_id) AS (
+			SELECT id, parent_id FROM comments WHERE id = ?1
+			UNION ALL
+			SELECT c.id, c.parent_id FROM comments c JOIN ancestors a ON c.id = a.parent_id
+		)
+		UPDATE comments
+		SET reply_count = reply_count - (id = ?1),
+			descendant_count = descendant_count - 1
+		WHERE id IN (SELECT id FROM ancestors)
+		`
+	if _, err := tx.ExecContext(ctx, replied, parentID.String); err != nil {
+		return nil, fmt.Errorf("could not update reply count: %w", err)
+	}
+
+	var unused bool
+	parent := `SELECT reply_count = 0 AND deleted_at IS NOT NULL FROM comments WHERE id = ?`
+	if err := tx.QueryRowContext(ctx, parent, parentID.String).Scan(&unused); err != nil {
+		return nil, fmt.Errorf("could not get parent comment: %w", err)
+	}
+	if !unused {
+		return nil, nil
+	}
+	return &parentID.String, nil
+}
+
+// sqlitePlaceholders возвращает список из n параметров запроса
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// sqliteArgs переводит строки в аргументы запроса
+func sqliteArgs(values []string) []any {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
+// Получение страниц комментариев верхнего уровня к нескольким постам
+func (s *SQLiteStore) ListCommentsByPostIDs(ctx context.Context, postIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	conds := []string{"post_id IN (" + sqlitePlaceholders(len(postIDs)) + ")", "parent_id IS NULL"}
+	return s.listCommentsBatch(ctx, "post_id", conds, postIDs, page)
+}
+
+// Получение страниц ответов на несколько комментариев
+func (s *SQLiteStore) ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	conds := []string{"parent_id IN (" + sqlitePlaceholders(len(parentIDs)) + ")"}
+	return s.listCommentsBatch(ctx, "parent_id", conds, parentIDs, page)
+}
+
+// Получение ветки комментариев по уровням: один запрос на уровень
+// Рекурсивные запросы SQLite не поддерживают оконные функции,
+// поэтому ответы каждого уровня выбираются пакетом, как в ListRepliesByParentIDs
+func (s *SQLiteStore) CommentTree(ctx context.Context, postID string, tree TreeQuery) ([]*Comment, error) {
+	var start []*Comment
+	if tree.RootID != nil {
+		root, err := s.GetCommentByID(ctx, *tree.RootID)
+		if err != nil {
+			return nil, err
+		}
+		if root.PostID != postID {
+			return nil, ErrCommentNotFound
+		}
+		start = []*Comment{root}
+	} else {
+		page, err := s.ListComments(ctx, postID, nil, PageQuery{First: tree.LimitPerLevel})
+		if err != nil {
+			return nil, err
+		}
+		start = page.Items
+	}
+
+	children := make(map[string][]*Comment)
+	level := start
+	for depth := 0; depth < tree.MaxDepth && len(level) > 0; depth++ {
+		ids := make([]string, 0, len(level))
+		for _, comment := range level {
+			ids = append(ids, comment.ID)
+		}
+		pages, err := s.ListRepliesByParentIDs(ctx, ids, PageQuery{First: tree.LimitPerLevel})
+		if err != nil {
+			return nil, err
+		}
+		next := make([]*Comment, 0)
+		for _, id := range ids {
+			children[id] = pages[id].Items
+			next = append(next, pages[id].Items...)
+		}
+		level = next
+	}
+
+	return flattenTree(start, func(id string) []*Comment { return children[id] }), nil
+}
+
+// listCommentsBatch выбирает страницы комментариев, сгруппированных по столбцу
+// partition, для каждого ID из ids одним запросом
+func (s *SQLiteStore) listCommentsBatch(ctx context.Context, partition string, conds []string, ids []string, page PageQuery) (map[string]*Page[*Comment], error) {
+	query, args := sqliteDialect.batchPageQuery("id, post_id, parent_id, content, author, created_at, depth, root_id, reply_count, descendant_count, edited_at, deleted_at, delete_reason",
+		"comments", partition, newestFirst, page, conds, sqliteArgs(ids))
+	comments, err := s.queryComments(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]*Comment, len(ids))
+	for _, comment := range comments {
+		key := comment.PostID
+		if partition == "parent_id" {
+			key = *comment.ParentID
+		}
+		groups[key] = append(groups[key], comment)
+	}
+	return groupPages(ids, groups, page), nil
+}
+
+// Количество комментариев верхнего уровня к нескольким постам
+func (s *SQLiteStore) CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error) {
+	query := `
+		SELECT post_id, COUNT(*)
+		FROM comments
+		WHERE post_id IN (` + sqlitePlaceholders(len(postIDs)) + `) AND parent_id IS NULL
+		GROUP BY post_id
+		`
+	return s.countCommentsBatch(ctx, query, postIDs)
+}
+
+// Количество ответов на несколько комментариев
+func (s *SQLiteStore) CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error) {
+	query := `
+		SELECT parent_id, COUNT(*)
+		FROM comments
+		WHERE parent_id IN (` + sqlitePlaceholders(len(parentIDs)) + `)
+		GROUP BY parent_id
+		`
+	return s.countCommentsBatch(ctx, query, parentIDs)
+}
+
+// countCommentsBatch выполняет запрос, возвращающий пары (ID, число комментариев)
+// ID без комментариев получают 0
+func (s *SQLiteStore) countCommentsBatch(ctx context.Context, query string, ids []string) (map[string]int, error) {
+	counts := make(map[string]int, len(ids))
+	for _, id := range ids {
+		counts[id] = 0
+	}
+
+	rows, err := s.DB.QueryContext(ctx, query, sqliteArgs(ids)...)
+	if err != nil {
+		return nil, fmt.Errorf("could not count comments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("could not read comment count: %w", err)
+		}
+		counts[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not count comments: %w", err)
+	}
+	return counts, nil
+}
+
+// sqliteCommentsFilter возвращает условия выборки комментариев верхнего уровня
+// или ответов на parentID и их аргументы
+func sqliteCommentsFilter(postID string, parentID *string) ([]string, []any) {
+	if parentID == nil {
+		return []string{"post_id = ?", "parent_id IS NULL"}, []any{postID}
+	}
+	return []string{"post_id = ?", "parent_id = ?"}, []any{postID, *parentID}
+}
+
+// Сохранение разрешённых операций
+// Операции с уже известным хешем заменяются
+func (s *SQLiteStore) SavePersistedQueries(ctx context.Context, queries []*PersistedQuery) error {
+	if len(queries) == 0 {
+		return nil
+	}
+	rows := make([]string, 0, len(queries))
+	args := make([]any, 0, 3*len(queries))
+	for _, q := range queries {
+		rows = append(rows, "(?, ?, ?)")
+		args = append(args, q.Hash, q.Name, q.Query)
+	}
+
+	query := `
+		INSERT INTO persisted_queries (hash, name, query)
+		VALUES ` + strings.Join(rows, ", ") + `
+		ON CONFLICT (hash) DO UPDATE SET name = excluded.name, query = excluded.query
+		`
+	// Выполнение запроса
+	if _, err := s.DB.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("could not save persisted queries: %w", err)
+	}
+	return nil
+}
+
+// Получение разрешённой операции по хешу
+func (s *SQLiteStore) GetPersistedQuery(ctx context.Context, hash string) (*PersistedQuery, error) {
+	query := `
+		SELECT hash, name, query
+		FROM persisted_queries
+		WHERE hash = ?
+		`
+	// Выполнение запроса
+	row := s.DB.QueryRowContext(ctx, query, hash)
+
+	// Обработка результата запроса
+	persisted := &PersistedQuery{}
+	err := row.Scan(&persisted.Hash, &persisted.Name, &persisted.Query)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPersistedQueryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get persisted query: %w", err)
+	}
+	return persisted, nil
+}
+
+// Subscribe — добавляет подписчика на события комментариев к посту
+func (s *SQLiteStore) Subscribe(ctx context.Context, postID string) (<-chan *CommentEvent, func()) {
+	return s.events.Subscribe(postID)
+}
+
+// Publish — отправляет событие о комментарии подписчикам
+func (s *SQLiteStore) Publish(ctx context.Context, event *CommentEvent) {
+	s.events.Publish(event)
+}
+
+// queryComments выполняет запрос, возвращающий список комментариев
+func (s *SQLiteStore) queryComments(ctx context.Context, query string, args ...any) ([]*Comment, error) {
+	// Выполнение запроса
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not get comments: %w", err)
+	}
+	defer rows.Close()
+
+	// Обработка результатов запроса
+	comments := make([]*Comment, 0)
+	for rows.Next() {
+		comment, err := scanSQLiteComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not read comment: %w", err)
+		}
+		comments = append(comments, comment)
+	}
+
+	return comments, rows.Err()
+}
+
+// sqliteError переводит ошибку SQLite в ошибку хранилища
+// notFound возвращается, если запись не найдена, exists — при конфликте ID.
+// Остальные ошибки оборачиваются с описанием операции op
+func sqliteError(op string, err error, notFound, exists error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	switch sqliteCode(err) {
+	case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY, sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+		return exists
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// sqliteCode возвращает расширенный код ошибки SQLite или 0
+func sqliteCode(err error) int {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()
+	}
+	return 0
+}
+
+// sqliteRow — общий интерфейс *sql.Row и *sql.Rows
+type sqliteRow interface {
+	Scan(dest ...any) error
+}
+
+// scanSQLitePost читает пост из строки результата
+func scanSQLitePost(row sqliteRow) (*Post, error) {
+	post := &Post{}
+	var createdAt int64
+	var updatedAt, deletedAt sql.NullInt64
+	if err := row.Scan(&post.ID, &post.Title, &post.Content, &post.Author, &post.AllowComments, &createdAt, &post.CommentCount, &post.TopLevelCommentCount, &updatedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	post.CreatedAt = time.Unix(0, createdAt)
+	post.UpdatedAt = sqliteTime(updatedAt)
+	post.DeletedAt = sqliteTime(deletedAt)
+	return post, nil
+}
+
+// sqliteTime переводит необязательное время в наносекундах Unix в time.Time
+func sqliteTime(value sql.NullInt64) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	t := time.Unix(0, value.Int64)
+	return &t
+}
+
+// scanSQLiteComment читает комментарий из строки результата
+func scanSQLiteComment(row sqliteRow) (*Comment, error) {
+	comment := &Comment{}
+	var parentID sql.NullString
+	var createdAt int64
+	var editedAt, deletedAt sql.NullInt64
+	if err := row.Scan(&comment.ID, &comment.PostID, &parentID, &comment.Content, &comment.Author, &createdAt, &comment.Depth, &comment.RootID, &comment.ReplyCount, &comment.DescendantCount, &editedAt, &deletedAt, &comment.DeleteReason); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		comment.ParentID = &parentID.String
+	}
+	comment.CreatedAt = time.Unix(0, createdAt)
+	comment.EditedAt = sqliteTime(editedAt)
+	comment.DeletedAt = sqliteTime(deletedAt)
+	return comment, nil
+}
@@ -0,0 +1,55 @@
+package store_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/SobolevTim/t-graphql/internal/store/storetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLiteStore открывает новую базу во временном каталоге теста
+func newSQLiteStore(t *testing.T, path string) *store.SQLiteStore {
+	s, err := store.NewSQLiteStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStoreConformance(t *testing.T) {
+	storetest.RunConformance(t, func(t *testing.T) store.Store {
+		return newSQLiteStore(t, filepath.Join(t.TempDir(), "test.db"))
+	})
+}
+
+func TestSQLiteStorePersistsAfterReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	s, err := store.NewSQLiteStore(path)
+	require.NoError(t, err)
+	_, err = s.CreatePost(ctx, "1", "Title", "Content", "Author", true)
+	require.NoError(t, err)
+	_, err = s.CreateComment(ctx, "c1", "1", nil, "Comment", "Author")
+	require.NoError(t, err)
+	parentID := "c1"
+	_, err = s.CreateComment(ctx, "c2", "1", &parentID, "Reply", "Author")
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// Повторное открытие не должно заново применять миграции
+	reopened := newSQLiteStore(t, path)
+
+	post, err := reopened.GetPostByID(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, "Title", post.Title)
+
+	replies, err := reopened.ListComments(ctx, "1", &parentID, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, replies.Items, 1)
+	assert.Equal(t, "c2", replies.Items[0].ID)
+	assert.Equal(t, "c1", *replies.Items[0].ParentID)
+}
@@ -1,47 +1,133 @@
 package store
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // Post представляет запись в блоге
 // Если AllowReply == false, комментарии к посту запрещены
 type Post struct {
-	ID            string     // Уникальный идентификатор поста
-	Title         string     // Заголовок поста
-	Content       string     // Содержимое поста
-	Author        string     // Автор поста
-	CreatedAt     time.Time  // Время создания поста
-	AllowComments bool       // Разрешены ли комментарии
-	Comments      []*Comment // Комментарии к посту
+	ID                   string     `json:"id"`                      // Уникальный идентификатор поста
+	Title                string     `json:"title"`                   // Заголовок поста
+	Content              string     `json:"content"`                 // Содержимое поста
+	Author               string     `json:"author"`                  // Автор поста
+	CreatedAt            time.Time  `json:"created_at"`              // Время создания поста
+	AllowComments        bool       `json:"allow_comments"`          // Разрешены ли комментарии
+	CommentCount         int        `json:"comment_count"`           // Число комментариев к посту вместе с ответами
+	TopLevelCommentCount int        `json:"top_level_comment_count"` // Число комментариев верхнего уровня
+	UpdatedAt            *time.Time `json:"updated_at,omitempty"`    // Время последнего изменения; nil, если пост не изменялся
+	DeletedAt            *time.Time `json:"deleted_at,omitempty"`    // Время удаления в корзину; nil, если пост не удалён
+	Comments             []*Comment `json:"-"`                       // Комментарии к посту
 }
 
 // Comment представляет комментарий к посту
 // Если ParentID == nil, значит комментарий верхнего уровня
+// Depth и RootID вычисляет хранилище при создании комментария,
+// счётчики ответов хранилище обновляет при создании каждого ответа
 type Comment struct {
-	ID        string     // Уникальный идентификатор комментария
-	PostID    string     // Уникальный идентификатор поста
-	ParentID  *string    // Уникальный идентификатор родительского комментария
-	Content   string     // Содержимое комментария
-	Author    string     // Автор комментария
-	CreatedAt time.Time  // Время создания комментария
-	Replies   []*Comment // Комментарии к комментарию
+	ID              string     `json:"id"`               // Уникальный идентификатор комментария
+	PostID          string     `json:"post_id"`          // Уникальный идентификатор поста
+	ParentID        *string    `json:"parent_id"`        // Уникальный идентификатор родительского комментария
+	Content         string     `json:"content"`          // Содержимое комментария
+	Author          string     `json:"author"`           // Автор комментария
+	CreatedAt       time.Time  `json:"created_at"`       // Время создания комментария
+	Depth           int        `json:"depth"`            // Глубина в ветке: 0 у комментария верхнего уровня
+	RootID          string     `json:"root_id"`          // ID комментария верхнего уровня, с которого началась ветка
+	ReplyCount      int        `json:"reply_count"`      // Число прямых ответов
+	DescendantCount int        `json:"descendant_count"` // Число ответов на всех уровнях ниже
+	EditedAt        *time.Time `json:"edited_at"`        // Время последнего изменения текста; nil, если не изменялся
+	DeletedAt       *time.Time `json:"deleted_at"`       // Время удаления; у неудалённого комментария nil
+	DeleteReason    string     `json:"delete_reason"`    // Причина удаления, см. DeletedByAuthor
+	Replies         []*Comment `json:"-"`                // Комментарии к комментарию
 }
 
 // Store определяет методы работы с хранилищем
+// Первым аргументом каждый метод принимает контекст запроса,
+// его отмена или дедлайн прерывают обращение к хранилищу
 // При возникновении ошибки возвращается nil и ошибка
 // Если метод возвращает список, а список пустой, возвращается пустой список и nil
 type Store interface {
 	// Методы работы с постами
-	CreatePost(id, title, content, author string, allowComments bool) (*Post, error)
-	GetPosts(page, pageSize int) ([]*Post, error)
-	GetPostByID(id string) (*Post, error)
-	UpdatePostCommentsPermission(postID string, allowComments bool) (*Post, error)
+	CreatePost(ctx context.Context, id, title, content, author string, allowComments bool) (*Post, error)
+	GetPostByID(ctx context.Context, id string) (*Post, error)
+	// GetPostsByIDs загружает посты по списку ID; ненайденных ID в результате нет
+	GetPostsByIDs(ctx context.Context, ids []string) (map[string]*Post, error)
+	UpdatePostCommentsPermission(ctx context.Context, postID string, allowComments bool) (*Post, error)
+	// UpdatePost заменяет заголовок и текст поста, сохраняя прежние в новой ревизии
+	// ListPostRevisions возвращает ревизии поста от старых к новым
+	UpdatePost(ctx context.Context, postID string, update PostUpdate) (*Post, error)
+	ListPostRevisions(ctx context.Context, postID string) ([]*PostRevision, error)
+	// Удалённый пост и его комментарии остаются в корзине, но методы выше
+	// и поиск их не находят; удалённые посты выбирает FindPosts с PostFilter.Deleted
+	// DeletePost и RestorePost возвращают ErrPostNotFound, если поста нет
+	// среди неудалённых или удалённых соответственно
+	// PurgeDeletedPosts окончательно удаляет посты, удалённые раньше deletedBefore,
+	// вместе с их комментариями и возвращает число удалённых постов
+	DeletePost(ctx context.Context, postID string) (*Post, error)
+	RestorePost(ctx context.Context, postID string) (*Post, error)
+	PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int, error)
+	// Списки выдаются страницами, см. PageQuery
+	// FindPosts выбирает посты по фильтру в порядке order, CountPosts считает их
+	FindPosts(ctx context.Context, filter PostFilter, order PostOrder, page PageQuery) (*Page[*Post], error)
+	CountPosts(ctx context.Context, filter PostFilter) (int, error)
+	// AddRelatedPosts сохраняет сходство поста с другими постами в обе стороны
+	// ListRelatedPosts возвращает до limit похожих постов, от более похожих к менее
+	AddRelatedPosts(ctx context.Context, postID string, related []RelatedScore) error
+	ListRelatedPosts(ctx context.Context, postID string, limit int) ([]*RelatedPost, error)
 
 	// Методы работы с комментариями
-	CreateComment(id, postID string, parentID *string, content string, author string) (*Comment, error)
-	GetCommentsByPostID(postID string, page, pageSize int) ([]*Comment, error)                              // Только комментарии верхнего уровня
-	GetCommentsByPostIDAndParentID(postID string, parentID *string, page, pageSize int) ([]*Comment, error) // Ответы на комментарий
+	// CreateComment возвращает ErrPostNotFound, если поста нет, и ErrInvalidParent,
+	// если родительского комментария нет или он относится к другому посту
+	CreateComment(ctx context.Context, id, postID string, parentID *string, content string, author string) (*Comment, error)
+	GetCommentByID(ctx context.Context, id string) (*Comment, error)
+	// GetCommentsByIDs загружает комментарии по списку ID; ненайденных ID в результате нет
+	GetCommentsByIDs(ctx context.Context, ids []string) (map[string]*Comment, error)
+	// UpdateComment заменяет текст комментария, сохраняя прежний в истории правок
+	// Возвращает ErrCommentNotFound, если комментария нет или он удалён
+	// ListCommentEdits загружает правки комментариев commentIDs от старых к новым;
+	// комментариев без правок в результате нет
+	UpdateComment(ctx context.Context, id, content string) (*Comment, error)
+	ListCommentEdits(ctx context.Context, commentIDs []string) (map[string][]*CommentEdit, error)
+	// DeleteComment удаляет комментарий с причиной reason. Комментарий с ответами
+	// остаётся в ветке: его текст и автор заменяются на DeletedPlaceholder,
+	// а правки удаляются. Комментарий без ответов удаляется целиком вместе
+	// с удалёнными комментариями выше по ветке, у которых не осталось ответов.
+	// Возвращает оставленный в ветке комментарий или nil, если он удалён целиком
+	// Возвращает ErrCommentNotFound, если комментария нет или он уже удалён
+	DeleteComment(ctx context.Context, id, reason string) (*Comment, error)
+	// Комментарии выдаются от новых к старым
+	// ListComments и CountComments работают с ответами на parentID
+	// или, если parentID == nil, с комментариями верхнего уровня
+	ListComments(ctx context.Context, postID string, parentID *string, page PageQuery) (*Page[*Comment], error)
+	CountComments(ctx context.Context, postID string, parentID *string) (int, error)
+	// Пакетные варианты ListComments и CountComments для загрузчиков:
+	// одна выборка для комментариев верхнего уровня к постам postIDs или
+	// ответов на комментарии parentIDs. Результат есть для каждого ID,
+	// в том числе для несуществующих; страница page одна на все ID
+	ListCommentsByPostIDs(ctx context.Context, postIDs []string, page PageQuery) (map[string]*Page[*Comment], error)
+	ListRepliesByParentIDs(ctx context.Context, parentIDs []string, page PageQuery) (map[string]*Page[*Comment], error)
+	CountCommentsByPostIDs(ctx context.Context, postIDs []string) (map[string]int, error)
+	CountRepliesByParentIDs(ctx context.Context, parentIDs []string) (map[string]int, error)
+	// CommentTree выбирает ветку комментариев поста целиком: начальный уровень
+	// (корень query.RootID или новейшие комментарии верхнего уровня) и до
+	// query.MaxDepth уровней ответов, не больше query.LimitPerLevel новейших
+	// на каждый комментарий. Комментарии идут в порядке обхода в глубину,
+	// ответы одного комментария — от новых к старым.
+	// Возвращает ErrCommentNotFound, если корня нет у этого поста
+	CommentTree(ctx context.Context, postID string, query TreeQuery) ([]*Comment, error)
+
+	// Полнотекстовый поиск
+	// Результаты выдаются от более релевантных к менее, вместе с фрагментами текста
+	Search(ctx context.Context, query SearchQuery, page PageQuery) (*Page[*SearchHit], error)
+
+	// Список разрешённых операций GraphQL, общий для всех экземпляров API
+	// SavePersistedQueries добавляет операции или заменяет операции с теми же хешами
+	// GetPersistedQuery возвращает ErrPersistedQueryNotFound, если операции с хешем hash нет
+	SavePersistedQueries(ctx context.Context, queries []*PersistedQuery) error
+	GetPersistedQuery(ctx context.Context, hash string) (*PersistedQuery, error)
 
 	// Методы работы с подписками
-	Subscribe(postID string) (<-chan *Comment, func())
-	Publish(comment *Comment)
+	Subscribe(ctx context.Context, postID string) (<-chan *CommentEvent, func())
+	Publish(ctx context.Context, event *CommentEvent)
 }
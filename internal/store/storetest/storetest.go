@@ -0,0 +1,1595 @@
+// Package storetest содержит общий набор тестов, которому должна
+// соответствовать любая реализация store.Store
+package storetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SobolevTim/t-graphql/internal/store"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory возвращает новое пустое хранилище для одного теста
+type Factory func(t *testing.T) store.Store
+
+// creationGap — пауза между созданием записей,
+// чтобы у них гарантированно различалось время создания
+const creationGap = 5 * time.Millisecond
+
+// deliveryTimeout — сколько ждать доставки комментария подписчику
+const deliveryTimeout = 2 * time.Second
+
+// RunConformance проверяет поведение хранилища: порядок выдачи,
+// пагинацию, обработку отсутствующих записей и доставку подписок
+func RunConformance(t *testing.T, newStore Factory) {
+	t.Run("Posts", func(t *testing.T) { testPosts(t, newStore) })
+	t.Run("Comments", func(t *testing.T) { testComments(t, newStore) })
+	t.Run("Pages", func(t *testing.T) { testPages(t, newStore) })
+	t.Run("Batches", func(t *testing.T) { testBatches(t, newStore) })
+	t.Run("CommentTree", func(t *testing.T) { testCommentTree(t, newStore) })
+	t.Run("Counters", func(t *testing.T) { testCounters(t, newStore) })
+	t.Run("PostQueries", func(t *testing.T) { testPostQueries(t, newStore) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStore) })
+	t.Run("RelatedPosts", func(t *testing.T) { testRelatedPosts(t, newStore) })
+	t.Run("PersistedQueries", func(t *testing.T) { testPersistedQueries(t, newStore) })
+	t.Run("Revisions", func(t *testing.T) { testRevisions(t, newStore) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, newStore) })
+	t.Run("CommentEdits", func(t *testing.T) { testCommentEdits(t, newStore) })
+	t.Run("CommentDeletion", func(t *testing.T) { testCommentDeletion(t, newStore) })
+	t.Run("Subscriptions", func(t *testing.T) { testSubscriptions(t, newStore) })
+}
+
+func testPosts(t *testing.T, newStore Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		id := uuid.NewString()
+		created, err := s.CreatePost(ctx, id, "Title", "Content", "Author", true)
+		require.NoError(t, err)
+		assert.Equal(t, id, created.ID)
+		assert.False(t, created.CreatedAt.IsZero())
+
+		fetched, err := s.GetPostByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, fetched.ID)
+		assert.Equal(t, "Title", fetched.Title)
+		assert.Equal(t, "Content", fetched.Content)
+		assert.Equal(t, "Author", fetched.Author)
+		assert.True(t, fetched.AllowComments)
+	})
+
+	t.Run("DuplicateID", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		id := uuid.NewString()
+		_, err := s.CreatePost(ctx, id, "Title", "Content", "Author", true)
+		require.NoError(t, err)
+
+		post, err := s.CreatePost(ctx, id, "Other", "Content", "Author", true)
+		assert.ErrorIs(t, err, store.ErrAlreadyExists)
+		assert.Nil(t, post)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		post, err := s.GetPostByID(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, post)
+
+		post, err = s.UpdatePostCommentsPermission(ctx, uuid.NewString(), false)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, post)
+
+		// ID в неверном формате тоже означает, что записи нет
+		post, err = s.GetPostByID(ctx, "not-a-uuid")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, post)
+	})
+
+	t.Run("GetByIDs", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 2)
+
+		posts, err := s.GetPostsByIDs(ctx, []string{ids[1], uuid.NewString(), "not-a-uuid", ids[0]})
+		require.NoError(t, err)
+		require.Len(t, posts, 2)
+		assert.Equal(t, ids[0], posts[ids[0]].ID)
+		assert.Equal(t, ids[1], posts[ids[1]].ID)
+	})
+
+	t.Run("UpdateCommentsPermission", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		id := uuid.NewString()
+		_, err := s.CreatePost(ctx, id, "Title", "Content", "Author", true)
+		require.NoError(t, err)
+
+		updated, err := s.UpdatePostCommentsPermission(ctx, id, false)
+		require.NoError(t, err)
+		assert.False(t, updated.AllowComments)
+
+		fetched, err := s.GetPostByID(ctx, id)
+		require.NoError(t, err)
+		assert.False(t, fetched.AllowComments)
+	})
+
+	t.Run("NewestFirst", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 3)
+
+		posts, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(ids), postIDs(posts.Items))
+	})
+
+	t.Run("EmptyPage", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		createPosts(t, s, 1)
+
+		posts, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, posts.Items, 1)
+
+		// За последним постом страница пустая
+		after := posts.Items[0].Cursor()
+		posts, err = s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10, After: &after})
+		require.NoError(t, err)
+		assert.NotNil(t, posts.Items)
+		assert.Empty(t, posts.Items)
+		assert.False(t, posts.HasNextPage)
+	})
+}
+
+func testComments(t *testing.T, newStore Factory) {
+	t.Run("Create", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		id := uuid.NewString()
+		comment, err := s.CreateComment(ctx, id, postID, nil, "Comment", "Author")
+		require.NoError(t, err)
+		assert.Equal(t, id, comment.ID)
+		assert.Equal(t, postID, comment.PostID)
+		assert.Nil(t, comment.ParentID)
+		assert.Equal(t, "Comment", comment.Content)
+		assert.Equal(t, "Author", comment.Author)
+		assert.False(t, comment.CreatedAt.IsZero())
+	})
+
+	t.Run("TopLevelOnlyNewestFirst", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		ids := createComments(t, s, postID, nil, 3)
+		createComments(t, s, postID, &ids[0], 2)
+
+		comments, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(ids), commentIDs(comments.Items))
+	})
+
+	t.Run("PaginationAfterFiltering", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		// Ответы вперемешку с комментариями верхнего уровня
+		// не должны влиять на размер страницы
+		var topLevel []string
+		for i := 0; i < 3; i++ {
+			id := createComments(t, s, postID, nil, 1)[0]
+			topLevel = append(topLevel, id)
+			createComments(t, s, postID, &id, 2)
+		}
+		topLevel = reversed(topLevel)
+
+		first, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, topLevel[0:2], commentIDs(first.Items))
+		assert.True(t, first.HasNextPage)
+
+		after := first.Items[1].Cursor()
+		second, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, topLevel[2:], commentIDs(second.Items))
+		assert.False(t, second.HasNextPage)
+	})
+
+	t.Run("RepliesNewestFirst", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		parents := createComments(t, s, postID, nil, 2)
+		replies := createComments(t, s, postID, &parents[0], 3)
+		createComments(t, s, postID, &parents[1], 1)
+
+		comments, err := s.ListComments(ctx, postID, &parents[0], store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(replies), commentIDs(comments.Items))
+		for _, c := range comments.Items {
+			require.NotNil(t, c.ParentID)
+			assert.Equal(t, parents[0], *c.ParentID)
+		}
+
+		after := comments.Items[1].Cursor()
+		page, err := s.ListComments(ctx, postID, &parents[0], store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(replies)[2:], commentIDs(page.Items))
+	})
+
+	t.Run("EmptyPage", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		comments, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, comments.Items)
+		assert.Empty(t, comments.Items)
+
+		parentID := createComments(t, s, postID, nil, 1)[0]
+		comments, err = s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, comments.Items, 1)
+
+		after := comments.Items[0].Cursor()
+		comments, err = s.ListComments(ctx, postID, nil, store.PageQuery{First: 10, After: &after})
+		require.NoError(t, err)
+		assert.NotNil(t, comments.Items)
+		assert.Empty(t, comments.Items)
+
+		replies, err := s.ListComments(ctx, postID, &parentID, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, replies.Items)
+		assert.Empty(t, replies.Items)
+	})
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		comments, err := s.ListComments(ctx, uuid.NewString(), nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, comments.Items)
+		assert.Empty(t, comments.Items)
+
+		comment, err := s.CreateComment(ctx, uuid.NewString(), uuid.NewString(), nil, "Comment", "Author")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, comment)
+	})
+
+	t.Run("DuplicateID", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		id := createComments(t, s, postID, nil, 1)[0]
+
+		comment, err := s.CreateComment(ctx, id, postID, nil, "Comment", "Author")
+		assert.ErrorIs(t, err, store.ErrAlreadyExists)
+		assert.Nil(t, comment)
+	})
+
+	t.Run("GetByID", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		id := createComments(t, s, postID, nil, 1)[0]
+
+		comment, err := s.GetCommentByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, comment.ID)
+		assert.Equal(t, postID, comment.PostID)
+
+		comment, err = s.GetCommentByID(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, store.ErrNotFound)
+		assert.Nil(t, comment)
+	})
+
+	t.Run("GetByIDs", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		ids := createComments(t, s, postID, nil, 2)
+
+		comments, err := s.GetCommentsByIDs(ctx, []string{ids[0], uuid.NewString(), "not-a-uuid", ids[1]})
+		require.NoError(t, err)
+		require.Len(t, comments, 2)
+		assert.Equal(t, postID, comments[ids[0]].PostID)
+		assert.Equal(t, ids[1], comments[ids[1]].ID)
+	})
+
+	t.Run("ThreadDepthAndRoot", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		root := createComments(t, s, postID, nil, 1)[0]
+		reply := createComments(t, s, postID, &root, 1)[0]
+		nested := uuid.NewString()
+
+		created, err := s.CreateComment(ctx, nested, postID, &reply, "Comment", "Author")
+		require.NoError(t, err)
+		assert.Equal(t, 2, created.Depth)
+		assert.Equal(t, root, created.RootID)
+
+		for id, depth := range map[string]int{root: 0, reply: 1, nested: 2} {
+			comment, err := s.GetCommentByID(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, depth, comment.Depth)
+			assert.Equal(t, root, comment.RootID)
+		}
+
+		replies, err := s.ListComments(ctx, postID, &root, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, replies.Items, 1)
+		assert.Equal(t, 1, replies.Items[0].Depth)
+		assert.Equal(t, root, replies.Items[0].RootID)
+	})
+
+	t.Run("InvalidParent", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postIDs := createPosts(t, s, 2)
+		otherParent := createComments(t, s, postIDs[1], nil, 1)[0]
+
+		// Родителя не существует
+		missing := uuid.NewString()
+		comment, err := s.CreateComment(ctx, uuid.NewString(), postIDs[0], &missing, "Comment", "Author")
+		assert.ErrorIs(t, err, store.ErrInvalidParent)
+		assert.Nil(t, comment)
+
+		// Родитель относится к другому посту
+		comment, err = s.CreateComment(ctx, uuid.NewString(), postIDs[0], &otherParent, "Comment", "Author")
+		assert.ErrorIs(t, err, store.ErrInvalidParent)
+		assert.Nil(t, comment)
+	})
+}
+
+func testPages(t *testing.T, newStore Factory) {
+	t.Run("PostsForward", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := reversed(createPosts(t, s, 5))
+
+		first, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, ids[0:2], postIDs(first.Items))
+		assert.True(t, first.HasNextPage)
+		assert.False(t, first.HasPreviousPage)
+
+		after := first.Items[1].Cursor()
+		second, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, ids[2:4], postIDs(second.Items))
+		assert.True(t, second.HasNextPage)
+		assert.True(t, second.HasPreviousPage)
+
+		after = second.Items[1].Cursor()
+		last, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, ids[4:], postIDs(last.Items))
+		assert.False(t, last.HasNextPage)
+	})
+
+	t.Run("PostsBackward", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := reversed(createPosts(t, s, 5))
+
+		last, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{Last: 2})
+		require.NoError(t, err)
+		assert.Equal(t, ids[3:5], postIDs(last.Items))
+		assert.True(t, last.HasPreviousPage)
+		assert.False(t, last.HasNextPage)
+
+		before := last.Items[0].Cursor()
+		previous, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{Last: 2, Before: &before})
+		require.NoError(t, err)
+		assert.Equal(t, ids[1:3], postIDs(previous.Items))
+		assert.True(t, previous.HasPreviousPage)
+		assert.True(t, previous.HasNextPage)
+
+		before = previous.Items[0].Cursor()
+		first, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{Last: 2, Before: &before})
+		require.NoError(t, err)
+		assert.Equal(t, ids[0:1], postIDs(first.Items))
+		assert.False(t, first.HasPreviousPage)
+	})
+
+	t.Run("AfterAndBefore", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		ids := reversed(createComments(t, s, postID, nil, 5))
+
+		all, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 5})
+		require.NoError(t, err)
+		require.Len(t, all.Items, 5)
+
+		after, before := all.Items[0].Cursor(), all.Items[4].Cursor()
+		between, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10, After: &after, Before: &before})
+		require.NoError(t, err)
+		assert.Equal(t, ids[1:4], commentIDs(between.Items))
+		assert.False(t, between.HasNextPage)
+	})
+
+	t.Run("StableWhileInserting", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		ids := reversed(createComments(t, s, postID, nil, 4))
+
+		first, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, ids[0:2], commentIDs(first.Items))
+
+		// Новые комментарии не сдвигают следующую страницу
+		createComments(t, s, postID, nil, 3)
+
+		after := first.Items[1].Cursor()
+		second, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, ids[2:4], commentIDs(second.Items))
+		assert.False(t, second.HasNextPage)
+	})
+
+	t.Run("Replies", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		parents := createComments(t, s, postID, nil, 2)
+		replies := reversed(createComments(t, s, postID, &parents[0], 3))
+		createComments(t, s, postID, &parents[1], 1)
+
+		page, err := s.ListComments(ctx, postID, &parents[0], store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, replies[0:2], commentIDs(page.Items))
+		assert.True(t, page.HasNextPage)
+
+		top, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(parents), commentIDs(top.Items))
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postIDs := createPosts(t, s, 2)
+		parents := createComments(t, s, postIDs[0], nil, 2)
+		createComments(t, s, postIDs[0], &parents[0], 3)
+
+		posts, err := s.CountPosts(ctx, store.PostFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, posts)
+
+		topLevel, err := s.CountComments(ctx, postIDs[0], nil)
+		require.NoError(t, err)
+		assert.Equal(t, 2, topLevel)
+
+		replies, err := s.CountComments(ctx, postIDs[0], &parents[0])
+		require.NoError(t, err)
+		assert.Equal(t, 3, replies)
+
+		empty, err := s.CountComments(ctx, postIDs[1], nil)
+		require.NoError(t, err)
+		assert.Zero(t, empty)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		posts, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, posts.Items)
+		assert.Empty(t, posts.Items)
+		assert.False(t, posts.HasNextPage)
+		assert.False(t, posts.HasPreviousPage)
+
+		comments, err := s.ListComments(ctx, uuid.NewString(), nil, store.PageQuery{Last: 10})
+		require.NoError(t, err)
+		assert.NotNil(t, comments.Items)
+		assert.Empty(t, comments.Items)
+	})
+}
+
+func testBatches(t *testing.T, newStore Factory) {
+	// setup создаёт два поста: у первого три комментария и два ответа
+	// на первый из них, у второго — один комментарий
+	setup := func(t *testing.T) (store.Store, []string, []string, []string, []string) {
+		s := newStore(t)
+		posts := createPosts(t, s, 2)
+		first := createComments(t, s, posts[0], nil, 3)
+		replies := createComments(t, s, posts[0], &first[0], 2)
+		second := createComments(t, s, posts[1], nil, 1)
+		return s, posts, first, replies, second
+	}
+
+	t.Run("CommentsByPostIDs", func(t *testing.T) {
+		ctx := context.Background()
+		s, posts, first, _, second := setup(t)
+		unknown := uuid.NewString()
+
+		pages, err := s.ListCommentsByPostIDs(ctx, []string{posts[0], posts[1], unknown}, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		require.Len(t, pages, 3)
+		assert.Equal(t, []string{first[2], first[1]}, commentIDs(pages[posts[0]].Items))
+		assert.True(t, pages[posts[0]].HasNextPage)
+		assert.Equal(t, second, commentIDs(pages[posts[1]].Items))
+		assert.False(t, pages[posts[1]].HasNextPage)
+		assert.Empty(t, pages[unknown].Items)
+
+		after := pages[posts[0]].Items[1].Cursor()
+		pages, err = s.ListCommentsByPostIDs(ctx, []string{posts[0]}, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, []string{first[0]}, commentIDs(pages[posts[0]].Items))
+		assert.True(t, pages[posts[0]].HasPreviousPage)
+
+		pages, err = s.ListCommentsByPostIDs(ctx, []string{posts[0], posts[1]}, store.PageQuery{Last: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{first[0]}, commentIDs(pages[posts[0]].Items))
+		assert.True(t, pages[posts[0]].HasPreviousPage)
+		assert.Equal(t, second, commentIDs(pages[posts[1]].Items))
+		assert.False(t, pages[posts[1]].HasPreviousPage)
+	})
+
+	t.Run("RepliesByParentIDs", func(t *testing.T) {
+		ctx := context.Background()
+		s, _, first, replies, second := setup(t)
+
+		pages, err := s.ListRepliesByParentIDs(ctx, []string{first[0], first[1], second[0]}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, pages, 3)
+		assert.Equal(t, reversed(replies), commentIDs(pages[first[0]].Items))
+		assert.Empty(t, pages[first[1]].Items)
+		assert.Empty(t, pages[second[0]].Items)
+	})
+
+	t.Run("Counts", func(t *testing.T) {
+		ctx := context.Background()
+		s, posts, first, _, _ := setup(t)
+		unknown := uuid.NewString()
+
+		counts, err := s.CountCommentsByPostIDs(ctx, []string{posts[0], posts[1], unknown})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{posts[0]: 3, posts[1]: 1, unknown: 0}, counts)
+
+		counts, err = s.CountRepliesByParentIDs(ctx, []string{first[0], first[1]})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{first[0]: 2, first[1]: 0}, counts)
+	})
+}
+
+func testCommentTree(t *testing.T, newStore Factory) {
+	// Пост с ветками a (a1 с ответом a11, a2) и b (b1) и пост с одним комментарием
+	setup := func(t *testing.T) (store.Store, string, map[string]string, string) {
+		s := newStore(t)
+		posts := createPosts(t, s, 2)
+		top := createComments(t, s, posts[0], nil, 2)
+		a, b := top[0], top[1]
+		replies := createComments(t, s, posts[0], &a, 2)
+		ids := map[string]string{
+			"a": a, "b": b, "a1": replies[0], "a2": replies[1],
+			"a11": createComments(t, s, posts[0], &replies[0], 1)[0],
+			"b1":  createComments(t, s, posts[0], &b, 1)[0],
+		}
+		other := createComments(t, s, posts[1], nil, 1)[0]
+		return s, posts[0], ids, other
+	}
+	names := func(ids map[string]string, comments []*store.Comment) []string {
+		byID := make(map[string]string, len(ids))
+		for name, id := range ids {
+			byID[id] = name
+		}
+		out := make([]string, 0, len(comments))
+		for _, c := range comments {
+			out = append(out, byID[c.ID])
+		}
+		return out
+	}
+
+	t.Run("WholePost", func(t *testing.T) {
+		ctx := context.Background()
+		s, postID, ids, _ := setup(t)
+
+		tree, err := s.CommentTree(ctx, postID, store.TreeQuery{MaxDepth: 10, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "b1", "a", "a2", "a1", "a11"}, names(ids, tree))
+
+		tree, err = s.CommentTree(ctx, postID, store.TreeQuery{MaxDepth: 1, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "b1", "a", "a2", "a1"}, names(ids, tree))
+
+		tree, err = s.CommentTree(ctx, postID, store.TreeQuery{MaxDepth: 10, LimitPerLevel: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b", "b1"}, names(ids, tree))
+
+		tree, err = s.CommentTree(ctx, uuid.NewString(), store.TreeQuery{MaxDepth: 10, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Empty(t, tree)
+	})
+
+	t.Run("FromRoot", func(t *testing.T) {
+		ctx := context.Background()
+		s, postID, ids, _ := setup(t)
+		root := ids["a"]
+
+		tree, err := s.CommentTree(ctx, postID, store.TreeQuery{RootID: &root, MaxDepth: 10, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "a2", "a1", "a11"}, names(ids, tree))
+		assert.Equal(t, 2, tree[3].Depth)
+
+		tree, err = s.CommentTree(ctx, postID, store.TreeQuery{RootID: &root, MaxDepth: 10, LimitPerLevel: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "a2"}, names(ids, tree))
+
+		tree, err = s.CommentTree(ctx, postID, store.TreeQuery{RootID: &root, MaxDepth: 0, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, names(ids, tree))
+
+		nested := ids["a1"]
+		tree, err = s.CommentTree(ctx, postID, store.TreeQuery{RootID: &nested, MaxDepth: 1, LimitPerLevel: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a11"}, names(ids, tree))
+	})
+
+	t.Run("RootNotFound", func(t *testing.T) {
+		ctx := context.Background()
+		s, postID, _, other := setup(t)
+
+		unknown := uuid.NewString()
+		_, err := s.CommentTree(ctx, postID, store.TreeQuery{RootID: &unknown, MaxDepth: 10, LimitPerLevel: 10})
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+
+		// Корень относится к другому посту
+		_, err = s.CommentTree(ctx, postID, store.TreeQuery{RootID: &other, MaxDepth: 10, LimitPerLevel: 10})
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+	})
+}
+
+func testCounters(t *testing.T, newStore Factory) {
+	ctx := context.Background()
+	s := newStore(t)
+	postID := createPosts(t, s, 1)[0]
+	top := createComments(t, s, postID, nil, 2)
+	replies := createComments(t, s, postID, &top[0], 2)
+	createComments(t, s, postID, &replies[0], 3)
+
+	post, err := s.GetPostByID(ctx, postID)
+	require.NoError(t, err)
+	assert.Equal(t, 7, post.CommentCount)
+	assert.Equal(t, 2, post.TopLevelCommentCount)
+
+	counts := func(id string) [2]int {
+		comment, err := s.GetCommentByID(ctx, id)
+		require.NoError(t, err)
+		return [2]int{comment.ReplyCount, comment.DescendantCount}
+	}
+	assert.Equal(t, [2]int{2, 5}, counts(top[0]))
+	assert.Equal(t, [2]int{0, 0}, counts(top[1]))
+	assert.Equal(t, [2]int{3, 3}, counts(replies[0]))
+	assert.Equal(t, [2]int{0, 0}, counts(replies[1]))
+
+	// Счётчики выдаются и в страницах комментариев
+	page, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, 2, page.Items[1].ReplyCount)
+	assert.Equal(t, 5, page.Items[1].DescendantCount)
+
+	posts, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+	require.NoError(t, err)
+	require.Len(t, posts.Items, 1)
+	assert.Equal(t, 2, posts.Items[0].TopLevelCommentCount)
+}
+
+func testPostQueries(t *testing.T, newStore Factory) {
+	newest := store.PostsNewest
+
+	t.Run("FilterByAuthorAndComments", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		alice := createPostsBy(t, s, "alice", 3)
+		createPostsBy(t, s, "bob", 2)
+		_, err := s.UpdatePostCommentsPermission(ctx, alice[1], false)
+		require.NoError(t, err)
+
+		author := "alice"
+		posts, err := s.FindPosts(ctx, store.PostFilter{Author: &author}, newest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, reversed(alice), postIDs(posts.Items))
+
+		open := true
+		posts, err = s.FindPosts(ctx, store.PostFilter{Author: &author, AllowComments: &open}, newest, store.PageQuery{First: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{alice[2]}, postIDs(posts.Items))
+		assert.True(t, posts.HasNextPage)
+
+		after := posts.Items[0].Cursor()
+		posts, err = s.FindPosts(ctx, store.PostFilter{Author: &author, AllowComments: &open}, newest, store.PageQuery{First: 1, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, []string{alice[0]}, postIDs(posts.Items))
+		assert.False(t, posts.HasNextPage)
+
+		count, err := s.CountPosts(ctx, store.PostFilter{Author: &author})
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+
+		count, err = s.CountPosts(ctx, store.PostFilter{AllowComments: &open})
+		require.NoError(t, err)
+		assert.Equal(t, 4, count)
+
+		nobody := "nobody"
+		count, err = s.CountPosts(ctx, store.PostFilter{Author: &nobody})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+
+	t.Run("FilterByCreatedAt", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 4)
+
+		second, err := s.GetPostByID(ctx, ids[1])
+		require.NoError(t, err)
+		fourth, err := s.GetPostByID(ctx, ids[3])
+		require.NoError(t, err)
+
+		// Начало диапазона включается, конец — нет
+		filter := store.PostFilter{CreatedAfter: &second.CreatedAt, CreatedBefore: &fourth.CreatedAt}
+		posts, err := s.FindPosts(ctx, filter, newest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Equal(t, []string{ids[2], ids[1]}, postIDs(posts.Items))
+
+		count, err := s.CountPosts(ctx, filter)
+		require.NoError(t, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("OldestFirst", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 5)
+
+		first, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsOldest, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, ids[0:2], postIDs(first.Items))
+		assert.True(t, first.HasNextPage)
+
+		after := first.Items[1].Cursor()
+		second, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsOldest, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, ids[2:4], postIDs(second.Items))
+
+		last, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsOldest, store.PageQuery{Last: 2})
+		require.NoError(t, err)
+		assert.Equal(t, ids[3:5], postIDs(last.Items))
+		assert.True(t, last.HasPreviousPage)
+	})
+
+	t.Run("MostCommented", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 4)
+		parent := createComments(t, s, ids[1], nil, 1)[0]
+		createComments(t, s, ids[1], &parent, 2)
+		createComments(t, s, ids[2], nil, 1)
+		createComments(t, s, ids[0], nil, 1)
+
+		post, err := s.GetPostByID(ctx, ids[1])
+		require.NoError(t, err)
+		assert.Equal(t, 3, post.CommentCount)
+
+		// При равном числе комментариев посты идут от новых к старым
+		want := []string{ids[1], ids[2], ids[0], ids[3]}
+		first, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsMostCommented, store.PageQuery{First: 2})
+		require.NoError(t, err)
+		assert.Equal(t, want[0:2], postIDs(first.Items))
+		assert.Equal(t, 3, first.Items[0].CommentCount)
+
+		after := first.Items[1].Cursor()
+		second, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsMostCommented, store.PageQuery{First: 2, After: &after})
+		require.NoError(t, err)
+		assert.Equal(t, want[2:4], postIDs(second.Items))
+		assert.False(t, second.HasNextPage)
+
+		before := second.Items[0].Cursor()
+		previous, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsMostCommented, store.PageQuery{Last: 10, Before: &before})
+		require.NoError(t, err)
+		assert.Equal(t, want[0:2], postIDs(previous.Items))
+	})
+}
+
+func testSearch(t *testing.T, newStore Factory) {
+	// setup создаёт пост со словами в заголовке, пост со словом в тексте
+	// и комментарий к нему
+	setup := func(t *testing.T) (store.Store, string, string, string) {
+		ctx := context.Background()
+		s := newStore(t)
+		titled, mentioned, comment := uuid.NewString(), uuid.NewString(), uuid.NewString()
+		_, err := s.CreatePost(ctx, titled, "Go generics", "Type parameters explained", "Author", true)
+		require.NoError(t, err)
+		time.Sleep(creationGap)
+		_, err = s.CreatePost(ctx, mentioned, "Weekly notes", "A few words about Go tooling", "Author", true)
+		require.NoError(t, err)
+		time.Sleep(creationGap)
+		_, err = s.CreateComment(ctx, comment, mentioned, nil, "I like Go generics too", "Reader")
+		require.NoError(t, err)
+		return s, titled, mentioned, comment
+	}
+	hitIDs := func(hits []*store.SearchHit) []string {
+		ids := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			if hit.Post != nil {
+				ids = append(ids, hit.Post.ID)
+			} else {
+				ids = append(ids, hit.Comment.ID)
+			}
+		}
+		return ids
+	}
+
+	t.Run("RankedWithSnippets", func(t *testing.T) {
+		ctx := context.Background()
+		s, titled, mentioned, comment := setup(t)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "GO"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 3)
+		// Слово в заголовке весит больше слова в тексте
+		assert.Equal(t, titled, hits.Items[0].Post.ID)
+		assert.ElementsMatch(t, []string{titled, mentioned, comment}, hitIDs(hits.Items))
+		for i, hit := range hits.Items {
+			assert.Contains(t, hit.Snippet, "<b>Go</b>")
+			if i > 0 {
+				assert.GreaterOrEqual(t, hits.Items[i-1].Score, hit.Score)
+			}
+		}
+	})
+
+	t.Run("EscapedSnippets", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		_, err := s.CreatePost(ctx, uuid.NewString(), "Markup", `Go <script>alert("x")</script> & more`, "Author", true)
+		require.NoError(t, err)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "go"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 1)
+		snippet := hits.Items[0].Snippet
+		assert.Contains(t, snippet, "<b>Go</b>")
+		assert.Contains(t, snippet, "&lt;script&gt;")
+		assert.Contains(t, snippet, "&amp;")
+		assert.NotContains(t, snippet, "<script>")
+	})
+
+	t.Run("AllWordsAndKinds", func(t *testing.T) {
+		ctx := context.Background()
+		s, titled, _, comment := setup(t)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "go, generics!"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{titled, comment}, hitIDs(hits.Items))
+
+		kinds := []store.SearchKind{store.SearchComments}
+		hits, err = s.Search(ctx, store.SearchQuery{Text: "go", Kinds: kinds}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 1)
+		assert.Equal(t, comment, hits.Items[0].Comment.ID)
+		assert.Nil(t, hits.Items[0].Post)
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		ctx := context.Background()
+		s, _, _, _ := setup(t)
+
+		all, err := s.Search(ctx, store.SearchQuery{Text: "go"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+
+		var ids []string
+		page := store.PageQuery{First: 1}
+		for {
+			hits, err := s.Search(ctx, store.SearchQuery{Text: "go"}, page)
+			require.NoError(t, err)
+			ids = append(ids, hitIDs(hits.Items)...)
+			if !hits.HasNextPage {
+				break
+			}
+			after := hits.Items[len(hits.Items)-1].Cursor()
+			page.After = &after
+		}
+		assert.Equal(t, hitIDs(all.Items), ids)
+	})
+
+	t.Run("NoMatches", func(t *testing.T) {
+		ctx := context.Background()
+		s, _, _, _ := setup(t)
+
+		for _, text := range []string{"rust", "go rust", "", "?!"} {
+			hits, err := s.Search(ctx, store.SearchQuery{Text: text}, store.PageQuery{First: 10})
+			require.NoError(t, err)
+			assert.Empty(t, hits.Items, text)
+			assert.False(t, hits.HasNextPage, text)
+		}
+	})
+}
+
+func testRelatedPosts(t *testing.T, newStore Factory) {
+	relatedIDs := func(related []*store.RelatedPost) []string {
+		ids := make([]string, 0, len(related))
+		for _, r := range related {
+			ids = append(ids, r.Post.ID)
+		}
+		return ids
+	}
+
+	t.Run("BothDirections", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 4)
+
+		err := s.AddRelatedPosts(ctx, ids[3], []store.RelatedScore{
+			{PostID: ids[0], Score: 0.5},
+			{PostID: ids[1], Score: 0.8},
+			{PostID: ids[2], Score: 0.5},
+		})
+		require.NoError(t, err)
+
+		// При равном сходстве посты идут от новых к старым
+		related, err := s.ListRelatedPosts(ctx, ids[3], 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{ids[1], ids[2], ids[0]}, relatedIDs(related))
+		assert.InDelta(t, 0.8, related[0].Score, 1e-9)
+		assert.Equal(t, "Title", related[0].Post.Title)
+
+		related, err = s.ListRelatedPosts(ctx, ids[3], 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{ids[1]}, relatedIDs(related))
+
+		related, err = s.ListRelatedPosts(ctx, ids[0], 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{ids[3]}, relatedIDs(related))
+		assert.InDelta(t, 0.5, related[0].Score, 1e-9)
+	})
+
+	t.Run("UpdateScore", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 2)
+
+		require.NoError(t, s.AddRelatedPosts(ctx, ids[0], []store.RelatedScore{{PostID: ids[1], Score: 0.3}}))
+		require.NoError(t, s.AddRelatedPosts(ctx, ids[1], []store.RelatedScore{{PostID: ids[0], Score: 0.6}}))
+
+		for _, id := range ids {
+			related, err := s.ListRelatedPosts(ctx, id, 10)
+			require.NoError(t, err)
+			require.Len(t, related, 1)
+			assert.InDelta(t, 0.6, related[0].Score, 1e-9)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 1)
+
+		require.NoError(t, s.AddRelatedPosts(ctx, ids[0], nil))
+		related, err := s.ListRelatedPosts(ctx, ids[0], 10)
+		require.NoError(t, err)
+		assert.NotNil(t, related)
+		assert.Empty(t, related)
+	})
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		ids := createPosts(t, s, 1)
+
+		err := s.AddRelatedPosts(ctx, ids[0], []store.RelatedScore{{PostID: uuid.NewString(), Score: 0.5}})
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+	})
+}
+
+func testPersistedQueries(t *testing.T, newStore Factory) {
+	t.Run("SaveAndGet", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		err := s.SavePersistedQueries(ctx, []*store.PersistedQuery{
+			{Hash: "h1", Name: "Posts", Query: "{ posts { totalCount } }"},
+			{Hash: "h2", Name: "Post", Query: "query Post($id: ID!) { post(id: $id) { id } }"},
+		})
+		require.NoError(t, err)
+
+		query, err := s.GetPersistedQuery(ctx, "h2")
+		require.NoError(t, err)
+		assert.Equal(t, &store.PersistedQuery{Hash: "h2", Name: "Post", Query: "query Post($id: ID!) { post(id: $id) { id } }"}, query)
+
+		_, err = s.GetPersistedQuery(ctx, "missing")
+		assert.ErrorIs(t, err, store.ErrPersistedQueryNotFound)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		require.NoError(t, s.SavePersistedQueries(ctx, []*store.PersistedQuery{{Hash: "h1", Name: "Old", Query: "{ a }"}}))
+		require.NoError(t, s.SavePersistedQueries(ctx, []*store.PersistedQuery{{Hash: "h1", Name: "New", Query: "{ b }"}}))
+		require.NoError(t, s.SavePersistedQueries(ctx, nil))
+
+		query, err := s.GetPersistedQuery(ctx, "h1")
+		require.NoError(t, err)
+		assert.Equal(t, "New", query.Name)
+		assert.Equal(t, "{ b }", query.Query)
+	})
+}
+
+func testRevisions(t *testing.T, newStore Factory) {
+	t.Run("UpdateKeepsHistory", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := uuid.NewString()
+		created, err := s.CreatePost(ctx, postID, "Draft", "First line", "Author", true)
+		require.NoError(t, err)
+		assert.Nil(t, created.UpdatedAt)
+
+		revisions, err := s.ListPostRevisions(ctx, postID)
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		updated, err := s.UpdatePost(ctx, postID, store.PostUpdate{Title: "Final", Content: "First line", Editor: "Editor"})
+		require.NoError(t, err)
+		assert.Equal(t, "Final", updated.Title)
+		assert.Equal(t, "Author", updated.Author)
+		require.NotNil(t, updated.UpdatedAt)
+		assert.WithinDuration(t, time.Now(), *updated.UpdatedAt, time.Minute)
+
+		_, err = s.UpdatePost(ctx, postID, store.PostUpdate{Title: "Final", Content: "Second line", Editor: "Author"})
+		require.NoError(t, err)
+
+		post, err := s.GetPostByID(ctx, postID)
+		require.NoError(t, err)
+		assert.Equal(t, "Final", post.Title)
+		assert.Equal(t, "Second line", post.Content)
+		assert.True(t, post.CreatedAt.Equal(created.CreatedAt))
+
+		revisions, err = s.ListPostRevisions(ctx, postID)
+		require.NoError(t, err)
+		require.Len(t, revisions, 2)
+		assert.Equal(t, 1, revisions[0].Number)
+		assert.Equal(t, "Draft", revisions[0].Title)
+		assert.Equal(t, "First line", revisions[0].Content)
+		assert.Equal(t, "Editor", revisions[0].Editor)
+		assert.Equal(t, 2, revisions[1].Number)
+		assert.Equal(t, "Final", revisions[1].Title)
+		assert.Equal(t, "First line", revisions[1].Content)
+		assert.Equal(t, "Author", revisions[1].Editor)
+		assert.Equal(t, postID, revisions[1].PostID)
+		assert.False(t, revisions[1].EditedAt.Before(revisions[0].EditedAt))
+	})
+
+	t.Run("SearchUsesNewText", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := uuid.NewString()
+		_, err := s.CreatePost(ctx, postID, "Rust notes", "Borrow checker", "Author", true)
+		require.NoError(t, err)
+		_, err = s.UpdatePost(ctx, postID, store.PostUpdate{Title: "Go notes", Content: "Garbage collector", Editor: "Author"})
+		require.NoError(t, err)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "rust"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, hits.Items)
+
+		hits, err = s.Search(ctx, store.SearchQuery{Text: "garbage"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 1)
+		assert.Equal(t, postID, hits.Items[0].Post.ID)
+	})
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		_, err := s.UpdatePost(ctx, uuid.NewString(), store.PostUpdate{Title: "Title", Content: "Content", Editor: "Editor"})
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+
+		revisions, err := s.ListPostRevisions(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+}
+
+func testTrash(t *testing.T, newStore Factory) {
+	t.Run("DeleteHidesPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postIDs := createPosts(t, s, 2)
+		commentID := uuid.NewString()
+		_, err := s.CreateComment(ctx, commentID, postIDs[0], nil, "Hidden comment", "Author")
+		require.NoError(t, err)
+
+		deleted, err := s.DeletePost(ctx, postIDs[0])
+		require.NoError(t, err)
+		require.NotNil(t, deleted.DeletedAt)
+		assert.WithinDuration(t, time.Now(), *deleted.DeletedAt, time.Minute)
+
+		_, err = s.GetPostByID(ctx, postIDs[0])
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+		_, err = s.GetCommentByID(ctx, commentID)
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+
+		posts, err := s.GetPostsByIDs(ctx, postIDs)
+		require.NoError(t, err)
+		require.Len(t, posts, 1)
+		assert.Contains(t, posts, postIDs[1])
+
+		page, err := s.FindPosts(ctx, store.PostFilter{}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, postIDs[1], page.Items[0].ID)
+		count, err := s.CountPosts(ctx, store.PostFilter{})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "hidden"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, hits.Items)
+
+		trash, err := s.FindPosts(ctx, store.PostFilter{Deleted: true}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, trash.Items, 1)
+		assert.Equal(t, postIDs[0], trash.Items[0].ID)
+		assert.NotNil(t, trash.Items[0].DeletedAt)
+		count, err = s.CountPosts(ctx, store.PostFilter{Deleted: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("CommentOnDeletedPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		parentID := uuid.NewString()
+		_, err := s.CreateComment(ctx, parentID, postID, nil, "Parent", "Author")
+		require.NoError(t, err)
+		_, err = s.DeletePost(ctx, postID)
+		require.NoError(t, err)
+
+		// Пост в корзине не принимает ни комментарии, ни ответы
+		_, err = s.CreateComment(ctx, uuid.NewString(), postID, nil, "Comment", "Author")
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+		_, err = s.CreateComment(ctx, uuid.NewString(), postID, &parentID, "Reply", "Author")
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+
+		restored, err := s.RestorePost(ctx, postID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, restored.CommentCount)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		commentID := uuid.NewString()
+		_, err := s.CreateComment(ctx, commentID, postID, nil, "Comment", "Author")
+		require.NoError(t, err)
+		_, err = s.DeletePost(ctx, postID)
+		require.NoError(t, err)
+
+		restored, err := s.RestorePost(ctx, postID)
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, 1, restored.CommentCount)
+
+		post, err := s.GetPostByID(ctx, postID)
+		require.NoError(t, err)
+		assert.Nil(t, post.DeletedAt)
+		_, err = s.GetCommentByID(ctx, commentID)
+		require.NoError(t, err)
+
+		page, err := s.FindPosts(ctx, store.PostFilter{Deleted: true}, store.PostsNewest, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postIDs := createPosts(t, s, 2)
+		_, err := s.CreateComment(ctx, uuid.NewString(), postIDs[0], nil, "Comment", "Author")
+		require.NoError(t, err)
+		_, err = s.DeletePost(ctx, postIDs[0])
+		require.NoError(t, err)
+
+		purged, err := s.PurgeDeletedPosts(ctx, time.Now().Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		purged, err = s.PurgeDeletedPosts(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		_, err = s.RestorePost(ctx, postIDs[0])
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+		count, err := s.CountPosts(ctx, store.PostFilter{Deleted: true})
+		require.NoError(t, err)
+		assert.Zero(t, count)
+		_, err = s.GetPostByID(ctx, postIDs[1])
+		require.NoError(t, err)
+	})
+
+	t.Run("UnknownPost", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		_, err := s.DeletePost(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+		_, err = s.RestorePost(ctx, postID)
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+
+		_, err = s.DeletePost(ctx, postID)
+		require.NoError(t, err)
+		_, err = s.DeletePost(ctx, postID)
+		assert.ErrorIs(t, err, store.ErrPostNotFound)
+	})
+}
+
+func testCommentEdits(t *testing.T, newStore Factory) {
+	t.Run("UpdateKeepsHistory", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		commentIDs := createComments(t, s, postID, nil, 2)
+		id := commentIDs[0]
+
+		updated, err := s.UpdateComment(ctx, id, "Second")
+		require.NoError(t, err)
+		assert.Equal(t, "Second", updated.Content)
+		assert.Equal(t, "Author", updated.Author)
+		require.NotNil(t, updated.EditedAt)
+		assert.WithinDuration(t, time.Now(), *updated.EditedAt, time.Minute)
+		_, err = s.UpdateComment(ctx, id, "Third")
+		require.NoError(t, err)
+
+		comment, err := s.GetCommentByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Third", comment.Content)
+		assert.NotNil(t, comment.EditedAt)
+
+		page, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 2)
+		assert.Nil(t, page.Items[0].EditedAt)
+		assert.NotNil(t, page.Items[1].EditedAt)
+
+		edits, err := s.ListCommentEdits(ctx, commentIDs)
+		require.NoError(t, err)
+		assert.NotContains(t, edits, commentIDs[1])
+		require.Len(t, edits[id], 2)
+		assert.Equal(t, 1, edits[id][0].Number)
+		assert.Equal(t, "Comment", edits[id][0].Content)
+		assert.Equal(t, 2, edits[id][1].Number)
+		assert.Equal(t, "Second", edits[id][1].Content)
+		assert.Equal(t, id, edits[id][1].CommentID)
+		assert.False(t, edits[id][1].EditedAt.Before(edits[id][0].EditedAt))
+	})
+
+	t.Run("SearchUsesNewText", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		id := uuid.NewString()
+		_, err := s.CreateComment(ctx, id, postID, nil, "Typo here", "Author")
+		require.NoError(t, err)
+		_, err = s.UpdateComment(ctx, id, "Fixed wording")
+		require.NoError(t, err)
+
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "typo"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, hits.Items)
+
+		hits, err = s.Search(ctx, store.SearchQuery{Text: "wording"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, hits.Items, 1)
+		assert.Equal(t, id, hits.Items[0].Comment.ID)
+	})
+
+	t.Run("UnknownComment", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		id := createComments(t, s, postID, nil, 1)[0]
+
+		_, err := s.UpdateComment(ctx, uuid.NewString(), "Content")
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+
+		// Комментарии удалённого поста не изменяются
+		_, err = s.DeletePost(ctx, postID)
+		require.NoError(t, err)
+		_, err = s.UpdateComment(ctx, id, "Content")
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+
+		edits, err := s.ListCommentEdits(ctx, []string{id, uuid.NewString()})
+		require.NoError(t, err)
+		assert.Empty(t, edits)
+	})
+}
+
+func testCommentDeletion(t *testing.T, newStore Factory) {
+	t.Run("TombstoneWithReplies", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		id := uuid.NewString()
+		_, err := s.CreateComment(ctx, id, postID, nil, "Secret words", "Author")
+		require.NoError(t, err)
+		_, err = s.UpdateComment(ctx, id, "Secret phrase")
+		require.NoError(t, err)
+		replyID := createComments(t, s, postID, &id, 1)[0]
+
+		kept, err := s.DeleteComment(ctx, id, store.DeletedAsSpam)
+		require.NoError(t, err)
+		require.NotNil(t, kept)
+		assert.Equal(t, store.DeletedPlaceholder, kept.Content)
+		assert.Equal(t, store.DeletedPlaceholder, kept.Author)
+		assert.Equal(t, store.DeletedAsSpam, kept.DeleteReason)
+		assert.Nil(t, kept.EditedAt)
+		require.NotNil(t, kept.DeletedAt)
+		assert.WithinDuration(t, time.Now(), *kept.DeletedAt, time.Minute)
+
+		// Ветка не меняется: ответ и счётчики на месте
+		comment, err := s.GetCommentByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, store.DeletedPlaceholder, comment.Content)
+		assert.NotNil(t, comment.DeletedAt)
+		assert.Equal(t, 1, comment.ReplyCount)
+		reply, err := s.GetCommentByID(ctx, replyID)
+		require.NoError(t, err)
+		assert.Equal(t, id, *reply.ParentID)
+		post, err := s.GetPostByID(ctx, postID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, post.CommentCount)
+		page, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 1)
+		assert.Equal(t, store.DeletedAsSpam, page.Items[0].DeleteReason)
+
+		edits, err := s.ListCommentEdits(ctx, []string{id})
+		require.NoError(t, err)
+		assert.Empty(t, edits)
+		hits, err := s.Search(ctx, store.SearchQuery{Text: "secret"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, hits.Items)
+		hits, err = s.Search(ctx, store.SearchQuery{Text: "deleted"}, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, hits.Items)
+
+		_, err = s.DeleteComment(ctx, id, store.DeletedByAuthor)
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+		_, err = s.UpdateComment(ctx, id, "Content")
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+	})
+
+	t.Run("LeafRemoved", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		parentID := createComments(t, s, postID, nil, 1)[0]
+		replyIDs := createComments(t, s, postID, &parentID, 2)
+
+		kept, err := s.DeleteComment(ctx, replyIDs[0], store.DeletedByAuthor)
+		require.NoError(t, err)
+		assert.Nil(t, kept)
+
+		_, err = s.GetCommentByID(ctx, replyIDs[0])
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+		parent, err := s.GetCommentByID(ctx, parentID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, parent.ReplyCount)
+		assert.Equal(t, 1, parent.DescendantCount)
+		post, err := s.GetPostByID(ctx, postID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, post.CommentCount)
+		assert.Equal(t, 1, post.TopLevelCommentCount)
+
+		replies, err := s.ListComments(ctx, postID, &parentID, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		require.Len(t, replies.Items, 1)
+		assert.Equal(t, replyIDs[1], replies.Items[0].ID)
+	})
+
+	t.Run("UnusedTombstonesRemoved", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+		rootID := createComments(t, s, postID, nil, 1)[0]
+		middleID := createComments(t, s, postID, &rootID, 1)[0]
+		leafID := createComments(t, s, postID, &middleID, 1)[0]
+
+		kept, err := s.DeleteComment(ctx, middleID, store.DeletedByAuthor)
+		require.NoError(t, err)
+		require.NotNil(t, kept)
+		kept, err = s.DeleteComment(ctx, rootID, store.DeletedAsAbuse)
+		require.NoError(t, err)
+		require.NotNil(t, kept)
+
+		// Последний ответ уносит удалённые комментарии, которые держались на нём
+		kept, err = s.DeleteComment(ctx, leafID, store.DeletedByAuthor)
+		require.NoError(t, err)
+		assert.Nil(t, kept)
+
+		for _, id := range []string{rootID, middleID, leafID} {
+			_, err := s.GetCommentByID(ctx, id)
+			assert.ErrorIs(t, err, store.ErrCommentNotFound)
+		}
+		post, err := s.GetPostByID(ctx, postID)
+		require.NoError(t, err)
+		assert.Zero(t, post.CommentCount)
+		assert.Zero(t, post.TopLevelCommentCount)
+		page, err := s.ListComments(ctx, postID, nil, store.PageQuery{First: 10})
+		require.NoError(t, err)
+		assert.Empty(t, page.Items)
+	})
+
+	t.Run("UnknownComment", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+
+		_, err := s.DeleteComment(ctx, uuid.NewString(), store.DeletedByAuthor)
+		assert.ErrorIs(t, err, store.ErrCommentNotFound)
+	})
+}
+
+func testSubscriptions(t *testing.T, newStore Factory) {
+	t.Run("Delivery", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		ch, unsubscribe := s.Subscribe(ctx, postID)
+		require.NotNil(t, ch)
+		require.NotNil(t, unsubscribe)
+		defer unsubscribe()
+
+		comment := &store.Comment{
+			ID:        uuid.NewString(),
+			PostID:    postID,
+			Content:   "Subscription comment",
+			Author:    "Author",
+			CreatedAt: time.Now(),
+		}
+		event := store.NewCommentEvent(uuid.NewString(), store.EventCommentAdded, comment)
+		s.Publish(ctx, event)
+
+		select {
+		case received := <-ch:
+			require.NotNil(t, received)
+			assert.Equal(t, event.ID, received.ID)
+			assert.Equal(t, store.EventCommentAdded, received.Type)
+			assert.Equal(t, store.EventVersion, received.Version)
+			assert.Equal(t, postID, received.PostID)
+			assert.Equal(t, comment.ID, received.CommentID)
+			require.NotNil(t, received.Comment)
+			assert.Equal(t, comment.ID, received.Comment.ID)
+			assert.Equal(t, comment.PostID, received.Comment.PostID)
+			assert.Equal(t, comment.Content, received.Comment.Content)
+			assert.Equal(t, comment.Author, received.Comment.Author)
+		case <-time.After(deliveryTimeout):
+			t.Fatal("timed out waiting for published comment")
+		}
+	})
+
+	t.Run("OtherPostIsolated", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postIDs := createPosts(t, s, 2)
+
+		ch, unsubscribe := s.Subscribe(ctx, postIDs[0])
+		require.NotNil(t, ch)
+		defer unsubscribe()
+
+		s.Publish(ctx, store.NewCommentEvent(uuid.NewString(), store.EventCommentAdded, &store.Comment{
+			ID:        uuid.NewString(),
+			PostID:    postIDs[1],
+			Content:   "Comment",
+			Author:    "Author",
+			CreatedAt: time.Now(),
+		}))
+
+		select {
+		case received := <-ch:
+			t.Fatalf("received event for another post: %+v", received)
+		case <-time.After(200 * time.Millisecond):
+		}
+	})
+
+	t.Run("UnsubscribeClosesChannel", func(t *testing.T) {
+		ctx := context.Background()
+		s := newStore(t)
+		postID := createPosts(t, s, 1)[0]
+
+		ch, unsubscribe := s.Subscribe(ctx, postID)
+		require.NotNil(t, ch)
+		unsubscribe()
+
+		select {
+		case _, ok := <-ch:
+			assert.False(t, ok, "channel must be closed after unsubscribe")
+		case <-time.After(deliveryTimeout):
+			t.Fatal("channel was not closed after unsubscribe")
+		}
+	})
+}
+
+// createPosts создаёт n постов и возвращает их ID в порядке создания
+func createPosts(t *testing.T, s store.Store, n int) []string {
+	t.Helper()
+	return createPostsBy(t, s, "Author", n)
+}
+
+// createPostsBy создаёт n постов автора author и возвращает их ID в порядке создания
+func createPostsBy(t *testing.T, s store.Store, author string, n int) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id := uuid.NewString()
+		_, err := s.CreatePost(context.Background(), id, "Title", "Content", author, true)
+		require.NoError(t, err)
+		ids = append(ids, id)
+		time.Sleep(creationGap)
+	}
+	return ids
+}
+
+// createComments создаёт n комментариев и возвращает их ID в порядке создания
+func createComments(t *testing.T, s store.Store, postID string, parentID *string, n int) []string {
+	t.Helper()
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id := uuid.NewString()
+		_, err := s.CreateComment(context.Background(), id, postID, parentID, "Comment", "Author")
+		require.NoError(t, err)
+		ids = append(ids, id)
+		time.Sleep(creationGap)
+	}
+	return ids
+}
+
+func postIDs(posts []*store.Post) []string {
+	ids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func commentIDs(comments []*store.Comment) []string {
+	ids := make([]string, 0, len(comments))
+	for _, c := range comments {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func reversed(ids []string) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[len(ids)-1-i] = id
+	}
+	return out
+}
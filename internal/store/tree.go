@@ -0,0 +1,24 @@
+package store
+
+// TreeQuery — какую часть дерева комментариев поста выбрать
+type TreeQuery struct {
+	RootID        *string // Корень ветки; nil — комментарии верхнего уровня поста
+	MaxDepth      int     // Сколько уровней ответов выбрать ниже начального
+	LimitPerLevel int     // Сколько новейших ответов выбрать на каждый комментарий
+}
+
+// flattenTree обходит дерево в глубину, начиная с комментариев start,
+// и возвращает комментарии в порядке обхода: каждый комментарий идёт
+// перед своими ответами. children возвращает выбранные ответы на комментарий
+func flattenTree(start []*Comment, children func(id string) []*Comment) []*Comment {
+	tree := make([]*Comment, 0, len(start))
+	var walk func(comments []*Comment)
+	walk = func(comments []*Comment) {
+		for _, comment := range comments {
+			tree = append(tree, comment)
+			walk(children(comment.ID))
+		}
+	}
+	walk(start)
+	return tree
+}
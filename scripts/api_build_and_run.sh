@@ -11,4 +11,5 @@ docker run -d --restart=unless-stopped -p 8080:8080 --name graphql-api \
   -e DB_NAME=${DB_NAME} \
   -e STORAGE_TYPE=${STORAGE_TYPE} \
   -e DATABASE_URL=postgres://${DB_USER}:${DB_PASSWORD}@db:5432/${DB_NAME} \
+  -e MIGRATE_ON_START=true \
   graphql-api:latest
\ No newline at end of file